@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"sync"
 
 	"github.com/gorilla/sessions"
@@ -15,6 +16,10 @@ var (
 	Store       *sessions.CookieStore
 	storeOnce   sync.Once
 	SessionName = "langhelperCopy-session" // Сделал переменной для гибкости
+
+	// TrashRetentionDays - сколько дней удалённые слова, языки и колоды
+	// хранятся в корзине до автоматической очистки (TRASH_RETENTION_DAYS)
+	TrashRetentionDays = 30
 )
 
 func Init() {
@@ -31,6 +36,14 @@ func Init() {
 			Secure:   false, // В production должно быть true
 			SameSite: http.SameSiteLaxMode,
 		}
+
+		if raw := os.Getenv("TRASH_RETENTION_DAYS"); raw != "" {
+			days, err := strconv.Atoi(raw)
+			if err != nil || days < 1 {
+				log.Fatalf("Invalid TRASH_RETENTION_DAYS %q: need a positive number of days", raw)
+			}
+			TrashRetentionDays = days
+		}
 	})
 }
 
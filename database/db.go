@@ -21,10 +21,33 @@ func Connect() {
 
 	db = db.Set("gorm:table_options", "WITH (OIDS=FALSE)")
 
-	if err := db.AutoMigrate(&models.User{}, &models.UserLang{}, &models.UserWord{}, &models.Deck{}, &models.DeckWord{}, &models.DeckLang{}); err != nil {
+	// Метки времени и версии добавлены в модели позже: старые записи
+	// заполняются один раз, когда колонок ещё нет
+	needsTimestampBackfill := !db.Migrator().HasColumn(&models.Deck{}, "Version")
+	// Выполненные дни раньше вычислялись из повторений - при создании
+	// таблицы они переносятся из истории
+	needsGoalDayBackfill := !db.Migrator().HasTable(&models.GoalDay{})
+
+	if err := db.AutoMigrate(&models.User{}, &models.Language{}, &models.UserLang{}, &models.UserWord{}, &models.Deck{}, &models.DeckWord{}, &models.DeckLang{}, &models.Word{}, &models.WordExample{}, &models.Tag{}, &models.WordTag{}, &models.Review{}, &models.QuizPreference{}, &models.StudySession{}, &models.StudyCard{}, &models.CardProgress{}, &models.DeckWordState{}, &models.StreakFreeze{}, &models.GoalDay{}, &models.WordEditBatch{}, &models.HistoryEntry{}); err != nil {
 		log.Fatal("failed to migrate database:", err)
 	}
 
+	if err := runMigrations(db); err != nil {
+		log.Fatal("failed to migrate database:", err)
+	}
+
+	if needsTimestampBackfill {
+		if err := backfillTimestamps(db); err != nil {
+			log.Fatal("failed to migrate database:", err)
+		}
+	}
+
+	if needsGoalDayBackfill {
+		if err := db.Exec(goalDayBackfill).Error; err != nil {
+			log.Fatal("failed to migrate database:", err)
+		}
+	}
+
 	log.Println("Connected to database and migrated!")
 }
 
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"fmt"
+
+	"langhelperCopy/models"
+
+	"gorm.io/gorm"
+)
+
+// searchMigrations подключает pg_trgm/unaccent и создаёт индекс для
+// нечёткого поиска по переводам. unaccent() не помечена как IMMUTABLE,
+// поэтому для индекса используется обёртка langhelpercopy.unaccent_lower.
+var searchMigrations = []string{
+	`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+	`CREATE EXTENSION IF NOT EXISTS unaccent`,
+	`CREATE OR REPLACE FUNCTION langhelpercopy.unaccent_lower(text) RETURNS text
+		LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT
+		SET search_path = langhelpercopy, public
+		AS $$ SELECT lower(unaccent($1)) $$`,
+	`CREATE INDEX IF NOT EXISTS idx_user_words_translation_trgm
+		ON langhelpercopy.user_words
+		USING gin (langhelpercopy.unaccent_lower(translation) gin_trgm_ops)`,
+}
+
+// langCodeBackfill связывает языки пользователей с каталогом по названию
+// или коду без учёта регистра. Если под один код подходят несколько языков
+// пользователя, связывается только первый - остальные можно объединить вручную.
+const langCodeBackfill = `
+	UPDATE langhelpercopy.user_langs ul SET lang_code = m.code
+	FROM (
+		SELECT DISTINCT ON (u.user_id, l.code) u.id, l.code
+		FROM langhelpercopy.user_langs u
+		JOIN langhelpercopy.languages l
+			ON LOWER(TRIM(u.lang_title)) IN (LOWER(l.english_name), LOWER(l.native_name), LOWER(l.code))
+		WHERE u.lang_code IS NULL AND NOT EXISTS (
+			SELECT 1 FROM langhelpercopy.user_langs o
+			WHERE o.user_id = u.user_id AND o.lang_code = l.code
+		)
+		ORDER BY u.user_id, l.code, u.id
+	) m
+	WHERE ul.id = m.id`
+
+// langPositionBackfill задаёт порядок языков пользователям и колодам, у которых
+// он ещё не настроен: языки пользователя - по названию, как они выводились
+// раньше, языки колоды - в порядке языков пользователя.
+var langPositionBackfill = []string{
+	`UPDATE langhelpercopy.user_langs ul SET position = o.n
+	FROM (
+		SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY lang_title, id) AS n
+		FROM langhelpercopy.user_langs
+		WHERE user_id NOT IN (SELECT user_id FROM langhelpercopy.user_langs WHERE position <> 0)
+	) o
+	WHERE ul.id = o.id`,
+	`UPDATE langhelpercopy.deck_langs dl SET position = o.n
+	FROM (
+		SELECT d.id, ROW_NUMBER() OVER (PARTITION BY d.deck_id ORDER BY l.position, l.id) AS n
+		FROM langhelpercopy.deck_langs d
+		JOIN langhelpercopy.user_langs l ON l.id = d.lang_id
+		WHERE d.deck_id NOT IN (SELECT deck_id FROM langhelpercopy.deck_langs WHERE position <> 0)
+	) o
+	WHERE dl.id = o.id`,
+}
+
+// timestampBackfill заполняет created_at и updated_at записей, созданных до
+// появления этих колонок (после добавления в них стоит время миграции).
+// Точное время создания есть только в журнале изменений, в остальных случаях
+// берётся самая ранняя достоверная отметка: создание слова, первый перевод или
+// первое повторение. Связи колод не старше самой колоды и того, что они
+// связывают, поэтому заполняются после колод и языков.
+var timestampBackfill = []string{
+	`UPDATE langhelpercopy.user_words uw SET created_at = w.created_at
+	FROM langhelpercopy.words w
+	WHERE w.id = uw.word_id AND w.created_at < uw.created_at`,
+	`UPDATE langhelpercopy.user_langs ul SET created_at = f.first
+	FROM (
+		SELECT l.id, LEAST(
+			(SELECT MIN(h.created_at) FROM langhelpercopy.history_entries h
+				WHERE h.entity_type = 'language' AND h.action = 'create' AND h.entity_id = l.id),
+			(SELECT MIN(uw.created_at) FROM langhelpercopy.user_words uw WHERE uw.lang_id = l.id)
+		) AS first
+		FROM langhelpercopy.user_langs l
+	) f
+	WHERE ul.id = f.id AND f.first < ul.created_at`,
+	`UPDATE langhelpercopy.decks d SET created_at = f.first
+	FROM (
+		SELECT x.id, LEAST(
+			(SELECT MIN(h.created_at) FROM langhelpercopy.history_entries h
+				WHERE h.entity_type = 'deck' AND h.action = 'create' AND h.entity_id = x.id),
+			(SELECT MIN(r.created_at) FROM langhelpercopy.reviews r WHERE r.deck_id = x.id)
+		) AS first
+		FROM langhelpercopy.decks x
+	) f
+	WHERE d.id = f.id AND f.first < d.created_at`,
+	`UPDATE langhelpercopy.users u SET created_at = f.first
+	FROM (
+		SELECT x.id, LEAST(
+			(SELECT MIN(l.created_at) FROM langhelpercopy.user_langs l WHERE l.user_id = x.id),
+			(SELECT MIN(r.created_at) FROM langhelpercopy.reviews r WHERE r.user_id = x.id)
+		) AS first
+		FROM langhelpercopy.users x
+	) f
+	WHERE u.id = f.id AND f.first < u.created_at`,
+	`UPDATE langhelpercopy.deck_langs dl SET created_at = GREATEST(d.created_at, l.created_at)
+	FROM langhelpercopy.decks d, langhelpercopy.user_langs l
+	WHERE d.id = dl.deck_id AND l.id = dl.lang_id AND GREATEST(d.created_at, l.created_at) < dl.created_at`,
+	`UPDATE langhelpercopy.deck_words dw SET created_at = GREATEST(d.created_at, w.created_at)
+	FROM langhelpercopy.decks d, langhelpercopy.words w
+	WHERE d.id = dw.deck_id AND w.id = dw.word_id AND GREATEST(d.created_at, w.created_at) < dw.created_at`,
+
+	// Без сведений об изменениях запись считается не менявшейся после создания
+	`UPDATE langhelpercopy.users SET updated_at = created_at`,
+	`UPDATE langhelpercopy.user_langs SET updated_at = created_at`,
+	`UPDATE langhelpercopy.user_words SET updated_at = created_at`,
+	`UPDATE langhelpercopy.decks SET updated_at = created_at`,
+	`UPDATE langhelpercopy.deck_langs SET updated_at = created_at`,
+	`UPDATE langhelpercopy.deck_words SET updated_at = created_at`,
+	`UPDATE langhelpercopy.user_langs l SET updated_at = h.last
+	FROM (
+		SELECT entity_id, MAX(created_at) AS last FROM langhelpercopy.history_entries
+		WHERE entity_type = 'language' GROUP BY entity_id
+	) h
+	WHERE l.id = h.entity_id AND h.last > l.updated_at`,
+	`UPDATE langhelpercopy.decks d SET updated_at = h.last
+	FROM (
+		SELECT entity_id, MAX(created_at) AS last FROM langhelpercopy.history_entries
+		WHERE entity_type = 'deck' GROUP BY entity_id
+	) h
+	WHERE d.id = h.entity_id AND h.last > d.updated_at`,
+	`UPDATE langhelpercopy.user_words uw SET updated_at = h.last
+	FROM (
+		SELECT entity_id, lang_id, MAX(created_at) AS last FROM langhelpercopy.history_entries
+		WHERE entity_type = 'translation' GROUP BY entity_id, lang_id
+	) h
+	WHERE uw.word_id = h.entity_id AND uw.lang_id = h.lang_id AND uw.is_primary AND h.last > uw.updated_at`,
+}
+
+// backfillTimestamps выполняет timestampBackfill одной транзакцией. Вызывается
+// один раз - сразу после того, как AutoMigrate добавил колонки.
+func backfillTimestamps(db *gorm.DB) error {
+	return UnitOfWork(db, func(tx *gorm.DB) error {
+		for _, stmt := range timestampBackfill {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("timestamp backfill failed: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// goalDayBackfill отмечает выполненными прошлые дни, в которые хватило
+// ответов или минут на текущую цель пользователя: какой цель была раньше,
+// не сохранилось. Время занятий считается так же, как в routes/goals.go.
+const goalDayBackfill = `
+	INSERT INTO langhelpercopy.goal_days (user_id, day)
+	SELECT d.user_id, d.day
+	FROM (
+		SELECT user_id, day, COUNT(*) AS reviews, SUM(seconds) AS seconds
+		FROM (
+			SELECT a.user_id, DATE(a.created_at AT TIME ZONE u.timezone) AS day,
+				CASE WHEN a.gap IS NULL OR a.gap > 300 THEN 20 ELSE a.gap END AS seconds
+			FROM (
+				SELECT user_id, created_at,
+					EXTRACT(EPOCH FROM created_at - LAG(created_at) OVER (PARTITION BY user_id ORDER BY created_at)) AS gap
+				FROM langhelpercopy.reviews
+			) a
+			JOIN langhelpercopy.users u ON u.id = a.user_id
+		) timed
+		GROUP BY user_id, day
+	) d
+	JOIN langhelpercopy.users u ON u.id = d.user_id
+	WHERE CASE WHEN u.daily_goal_type = 'minutes' THEN FLOOR(d.seconds / 60) ELSE d.reviews END >= u.daily_goal
+	ON CONFLICT (user_id, day) DO NOTHING`
+
+func runMigrations(db *gorm.DB) error {
+	for _, stmt := range searchMigrations {
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("search migration failed: %w", err)
+		}
+	}
+	if err := seedLanguages(db); err != nil {
+		return fmt.Errorf("language catalogue seed failed: %w", err)
+	}
+	if err := db.Exec(langCodeBackfill).Error; err != nil {
+		return fmt.Errorf("language code backfill failed: %w", err)
+	}
+	for _, stmt := range langPositionBackfill {
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("language order backfill failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// seedLanguages копирует встроенный каталог в таблицу languages
+func seedLanguages(db *gorm.DB) error {
+	for _, l := range models.LanguageCatalogue {
+		err := db.Exec(`
+			INSERT INTO langhelpercopy.languages (code, english_name, native_name, script, rtl)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (code) DO UPDATE SET
+				english_name = EXCLUDED.english_name,
+				native_name = EXCLUDED.native_name,
+				script = EXCLUDED.script,
+				rtl = EXCLUDED.rtl
+		`, l.Code, l.EnglishName, l.NativeName, l.Script, l.RTL).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
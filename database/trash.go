@@ -0,0 +1,62 @@
+package database
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// trashPurgeInterval - как часто проверяется корзина
+const trashPurgeInterval = time.Hour
+
+// PurgeTrash окончательно удаляет слова, языки и колоды, перенесённые в
+// корзину раньше cutoff. Переводы, связи с колодами и статистика удаляются
+// каскадно; ссылка на основной язык пользователя внешним ключом не защищена,
+// поэтому сбрасывается отдельно, а слова, оставшиеся без переводов после
+// удаления языков, удаляются следом.
+func PurgeTrash(db *gorm.DB, cutoff time.Time) error {
+	return UnitOfWork(db, func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM langhelpercopy.words WHERE deleted_at < ?", cutoff).Error; err != nil {
+			return err
+		}
+		err := tx.Exec(`
+			UPDATE langhelpercopy.users SET main_lang_id = NULL, updated_at = NOW(), version = version + 1
+			WHERE main_lang_id IN (SELECT id FROM langhelpercopy.user_langs WHERE deleted_at < ?)
+		`, cutoff).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM langhelpercopy.user_langs WHERE deleted_at < ?", cutoff).Error; err != nil {
+			return err
+		}
+		if err := DeleteOrphanWords(tx); err != nil {
+			return err
+		}
+		return tx.Exec("DELETE FROM langhelpercopy.decks WHERE deleted_at < ?", cutoff).Error
+	})
+}
+
+// DeleteOrphanWords удаляет слова, у которых не осталось ни одного перевода.
+// Каскад от языка удаляет только user_words, сами слова остаются висеть.
+func DeleteOrphanWords(tx *gorm.DB) error {
+	return tx.Exec(`
+		DELETE FROM langhelpercopy.words w
+		WHERE NOT EXISTS (SELECT 1 FROM langhelpercopy.user_words uw WHERE uw.word_id = w.id)
+	`).Error
+}
+
+// StartTrashPurge запускает фоновую очистку корзины: сразу после старта и
+// затем раз в час удаляются записи старше retention
+func StartTrashPurge(retention time.Duration) {
+	go func() {
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+		for {
+			if err := PurgeTrash(db, time.Now().Add(-retention)); err != nil {
+				log.Printf("Failed to purge trash: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,14 @@
+package database
+
+import "gorm.io/gorm"
+
+// UnitOfWork выполняет fn как одну единицу работы: все запросы через tx
+// фиксируются вместе, а если fn вернула ошибку или запаниковала, не
+// сохраняется ничего. Ошибка fn возвращается вызывающему без изменений,
+// поэтому её можно проверить через errors.Is/errors.As.
+//
+// Если db уже транзакция, fn выполняется во вложенной точке сохранения:
+// функции, использующие UnitOfWork, можно вызывать друг из друга.
+func UnitOfWork(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	return db.Transaction(fn)
+}
@@ -9,6 +9,7 @@ import (
 	"log"
 	"net/http"
 	"sync"
+	"time"
 )
 
 var (
@@ -23,6 +24,7 @@ func main() {
 
 	config.Init()
 	database.Connect()
+	database.StartTrashPurge(time.Duration(config.TrashRetentionDays) * 24 * time.Hour)
 	router := routes.InitializeRoutes()
 
 	router.Use(SessionCleanupMiddleware)
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// CardProgress - статистика ответов пользователя по слову на одном языке
+type CardProgress struct {
+	ID             uint `gorm:"primaryKey"`
+	UserID         uint `gorm:"not null;uniqueIndex:idx_card_progress_user_word_lang"`
+	WordID         uint `gorm:"not null;uniqueIndex:idx_card_progress_user_word_lang"`
+	LangID         uint `gorm:"not null;uniqueIndex:idx_card_progress_user_word_lang"`
+	Successes      int  `gorm:"not null;default:0"`
+	Failures       int  `gorm:"not null;default:0"`
+	IsLeech        bool `gorm:"not null;default:false;index"` // слово не запоминается, ошибок не меньше порога
+	LeechAt        *time.Time
+	LastReviewedAt *time.Time
+
+	Streak       int        `gorm:"not null;default:0"` // правильных ответов подряд
+	IntervalDays int        `gorm:"not null;default:0"` // текущий интервал повторения
+	DueAt        *time.Time `gorm:"index"`              // когда слово пора повторить
+
+	User     User     `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Word     Word     `gorm:"foreignKey:WordID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	UserLang UserLang `gorm:"foreignKey:LangID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// reviewIntervals - интервалы повторения в днях по числу правильных ответов подряд
+var reviewIntervals = []int{1, 3, 7, 14, 30, 60, 120}
+
+// ReviewIntervalDays возвращает интервал до следующего повторения:
+// после ошибки слово повторяется сразу, затем интервалы растут.
+func ReviewIntervalDays(streak int) int {
+	if streak <= 0 {
+		return 0
+	}
+	if streak > len(reviewIntervals) {
+		return reviewIntervals[len(reviewIntervals)-1]
+	}
+	return reviewIntervals[streak-1]
+}
@@ -1,9 +1,43 @@
 package models
 
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
 type Deck struct {
 	ID        uint   `gorm:"primaryKey"`
 	UserID    uint   `gorm:"not null;index"`
 	DeckTitle string `gorm:"size:50"`
+	SmartRule string `gorm:"type:text"` // JSON SmartRule; пустое значение - обычная колода
+	// Язык вопросов по умолчанию в тренировках по колоде, nil - родной язык пользователя
+	PromptLangID *uint
+	CreatedAt    time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt    time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	// Версия растёт при переименовании и изменении настроек колоды
+	Version int `gorm:"not null;default:1"`
+	// Время переноса в корзину, nil - колода не удалена
+	DeletedAt *time.Time `gorm:"index"`
+
+	User       User      `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	PromptLang *UserLang `gorm:"foreignKey:PromptLangID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+// IsSmart сообщает, вычисляется ли состав колоды по правилу
+func (d Deck) IsSmart() bool {
+	return d.SmartRule != ""
+}
 
-	User User `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+// ValidateDeckTitle проверяет название колоды (колонка deck_title - size:50)
+func ValidateDeckTitle(title string) error {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return errors.New("deck title is required")
+	}
+	if utf8.RuneCountInString(title) > 50 {
+		return errors.New("deck title must be at most 50 characters")
+	}
+	return nil
 }
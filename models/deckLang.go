@@ -1,9 +1,17 @@
 package models
 
+import "time"
+
 type DeckLang struct {
 	ID     uint `gorm:"primaryKey"`
 	DeckID uint `gorm:"not null;index"`
 	LangID uint `gorm:"not null;index"`
+	// Порядок колонок в колоде; при равных значениях действует порядок языков пользователя
+	Position int `gorm:"not null;default:0"`
+
+	CreatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	Version   int       `gorm:"not null;default:1"`
 
 	Deck     Deck     `gorm:"foreignKey:DeckID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	UserLang UserLang `gorm:"foreignKey:LangID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
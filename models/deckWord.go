@@ -1,10 +1,16 @@
 package models
 
+import "time"
+
 type DeckWord struct {
 	ID     uint `gorm:"primaryKey"`
 	DeckID uint `gorm:"not null;index"`
 	WordID uint `gorm:"not null;index"`
 
+	CreatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"` // когда слово добавили в колоду
+	UpdatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	Version   int       `gorm:"not null;default:1"`
+
 	Deck Deck `gorm:"foreignKey:DeckID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	Word Word `gorm:"foreignKey:WordID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 }
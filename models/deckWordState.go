@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// DeckWordState - слово отключено в колоде навсегда (Suspended) или до
+// указанного времени (BuriedUntil). Хранится отдельно от deck_words,
+// потому что у умных колод нет списка слов.
+type DeckWordState struct {
+	ID          uint `gorm:"primaryKey"`
+	DeckID      uint `gorm:"not null;uniqueIndex:idx_deck_word_states_deck_word"`
+	WordID      uint `gorm:"not null;uniqueIndex:idx_deck_word_states_deck_word"`
+	Suspended   bool `gorm:"not null;default:false"`
+	BuriedUntil *time.Time
+
+	Deck Deck `gorm:"foreignKey:DeckID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Word Word `gorm:"foreignKey:WordID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// IsBuried сообщает, отложено ли слово на момент now
+func (s DeckWordState) IsBuried(now time.Time) bool {
+	return s.BuriedUntil != nil && s.BuriedUntil.After(now)
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// GoalDay - день, когда пользователь выполнил дневную цель. Записывается в
+// момент выполнения, поэтому смена цели или часового пояса прошлые дни не меняет.
+type GoalDay struct {
+	ID     uint      `gorm:"primaryKey"`
+	UserID uint      `gorm:"not null;uniqueIndex:idx_goal_days_user_day"`
+	Day    time.Time `gorm:"type:date;not null;uniqueIndex:idx_goal_days_user_day"`
+
+	User User `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
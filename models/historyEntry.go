@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Виды записей журнала изменений (HistoryEntry.EntityType)
+const (
+	HistoryWord        = "word"
+	HistoryTranslation = "translation"
+	HistoryLanguage    = "language"
+	HistoryDeck        = "deck"
+)
+
+// Действия журнала изменений (HistoryEntry.Action)
+const (
+	HistoryCreate  = "create"
+	HistoryUpdate  = "update"
+	HistoryDelete  = "delete"
+	HistoryRestore = "restore"
+)
+
+// HistoryEntry - запись журнала изменений слов, переводов, языков и колод.
+// Для перевода EntityID - ID слова, а язык хранится в LangID: строки
+// user_words пересоздаются, поэтому их ID для истории не подходит.
+// Записи не ссылаются на сущности внешним ключом и переживают их удаление.
+type HistoryEntry struct {
+	ID         uint      `gorm:"primaryKey"`
+	UserID     uint      `gorm:"not null;index"`
+	EntityType string    `gorm:"size:20;not null;index:idx_history_entity"`
+	EntityID   uint      `gorm:"not null;index:idx_history_entity"`
+	LangID     *uint     // язык перевода, только для HistoryTranslation
+	Action     string    `gorm:"size:10;not null"`
+	Field      string    `gorm:"size:30"` // поле, к которому относятся значения
+	OldValue   string    `gorm:"type:text"`
+	NewValue   string    `gorm:"type:text"`
+	CreatedAt  time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+
+	User User `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
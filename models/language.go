@@ -0,0 +1,154 @@
+package models
+
+import "strings"
+
+// Language - язык из встроенного каталога. Code - тег BCP 47
+// (ISO 639-1, если он есть, с уточнением письменности или региона).
+type Language struct {
+	Code        string `gorm:"primaryKey;size:20"`
+	EnglishName string `gorm:"size:50;not null"`
+	NativeName  string `gorm:"size:50;not null"`
+	Script      string `gorm:"size:4;not null"` // ISO 15924
+	RTL         bool   `gorm:"not null;default:false"`
+}
+
+// Dir возвращает направление письма для атрибута dir
+func (l Language) Dir() string {
+	if l.RTL {
+		return "rtl"
+	}
+	return "ltr"
+}
+
+// LanguageCatalogue - встроенный каталог языков, при запуске копируется в таблицу languages
+var LanguageCatalogue = []Language{
+	{Code: "af", EnglishName: "Afrikaans", NativeName: "Afrikaans", Script: "Latn"},
+	{Code: "am", EnglishName: "Amharic", NativeName: "አማርኛ", Script: "Ethi"},
+	{Code: "ar", EnglishName: "Arabic", NativeName: "العربية", Script: "Arab", RTL: true},
+	{Code: "az", EnglishName: "Azerbaijani", NativeName: "Azərbaycan dili", Script: "Latn"},
+	{Code: "be", EnglishName: "Belarusian", NativeName: "Беларуская", Script: "Cyrl"},
+	{Code: "bg", EnglishName: "Bulgarian", NativeName: "Български", Script: "Cyrl"},
+	{Code: "bn", EnglishName: "Bengali", NativeName: "বাংলা", Script: "Beng"},
+	{Code: "ca", EnglishName: "Catalan", NativeName: "Català", Script: "Latn"},
+	{Code: "cs", EnglishName: "Czech", NativeName: "Čeština", Script: "Latn"},
+	{Code: "cy", EnglishName: "Welsh", NativeName: "Cymraeg", Script: "Latn"},
+	{Code: "da", EnglishName: "Danish", NativeName: "Dansk", Script: "Latn"},
+	{Code: "de", EnglishName: "German", NativeName: "Deutsch", Script: "Latn"},
+	{Code: "el", EnglishName: "Greek", NativeName: "Ελληνικά", Script: "Grek"},
+	{Code: "en", EnglishName: "English", NativeName: "English", Script: "Latn"},
+	{Code: "eo", EnglishName: "Esperanto", NativeName: "Esperanto", Script: "Latn"},
+	{Code: "es", EnglishName: "Spanish", NativeName: "Español", Script: "Latn"},
+	{Code: "et", EnglishName: "Estonian", NativeName: "Eesti", Script: "Latn"},
+	{Code: "eu", EnglishName: "Basque", NativeName: "Euskara", Script: "Latn"},
+	{Code: "fa", EnglishName: "Persian", NativeName: "فارسی", Script: "Arab", RTL: true},
+	{Code: "fi", EnglishName: "Finnish", NativeName: "Suomi", Script: "Latn"},
+	{Code: "fr", EnglishName: "French", NativeName: "Français", Script: "Latn"},
+	{Code: "ga", EnglishName: "Irish", NativeName: "Gaeilge", Script: "Latn"},
+	{Code: "gl", EnglishName: "Galician", NativeName: "Galego", Script: "Latn"},
+	{Code: "gu", EnglishName: "Gujarati", NativeName: "ગુજરાતી", Script: "Gujr"},
+	{Code: "he", EnglishName: "Hebrew", NativeName: "עברית", Script: "Hebr", RTL: true},
+	{Code: "hi", EnglishName: "Hindi", NativeName: "हिन्दी", Script: "Deva"},
+	{Code: "hr", EnglishName: "Croatian", NativeName: "Hrvatski", Script: "Latn"},
+	{Code: "hu", EnglishName: "Hungarian", NativeName: "Magyar", Script: "Latn"},
+	{Code: "hy", EnglishName: "Armenian", NativeName: "Հայերեն", Script: "Armn"},
+	{Code: "id", EnglishName: "Indonesian", NativeName: "Bahasa Indonesia", Script: "Latn"},
+	{Code: "is", EnglishName: "Icelandic", NativeName: "Íslenska", Script: "Latn"},
+	{Code: "it", EnglishName: "Italian", NativeName: "Italiano", Script: "Latn"},
+	{Code: "ja", EnglishName: "Japanese", NativeName: "日本語", Script: "Jpan"},
+	{Code: "ka", EnglishName: "Georgian", NativeName: "ქართული", Script: "Geor"},
+	{Code: "kk", EnglishName: "Kazakh", NativeName: "Қазақ тілі", Script: "Cyrl"},
+	{Code: "km", EnglishName: "Khmer", NativeName: "ខ្មែរ", Script: "Khmr"},
+	{Code: "ko", EnglishName: "Korean", NativeName: "한국어", Script: "Kore"},
+	{Code: "ky", EnglishName: "Kyrgyz", NativeName: "Кыргызча", Script: "Cyrl"},
+	{Code: "la", EnglishName: "Latin", NativeName: "Latina", Script: "Latn"},
+	{Code: "lt", EnglishName: "Lithuanian", NativeName: "Lietuvių", Script: "Latn"},
+	{Code: "lv", EnglishName: "Latvian", NativeName: "Latviešu", Script: "Latn"},
+	{Code: "mk", EnglishName: "Macedonian", NativeName: "Македонски", Script: "Cyrl"},
+	{Code: "mn", EnglishName: "Mongolian", NativeName: "Монгол", Script: "Cyrl"},
+	{Code: "ms", EnglishName: "Malay", NativeName: "Bahasa Melayu", Script: "Latn"},
+	{Code: "nb", EnglishName: "Norwegian", NativeName: "Norsk bokmål", Script: "Latn"},
+	{Code: "ne", EnglishName: "Nepali", NativeName: "नेपाली", Script: "Deva"},
+	{Code: "nl", EnglishName: "Dutch", NativeName: "Nederlands", Script: "Latn"},
+	{Code: "pa", EnglishName: "Punjabi", NativeName: "ਪੰਜਾਬੀ", Script: "Guru"},
+	{Code: "pl", EnglishName: "Polish", NativeName: "Polski", Script: "Latn"},
+	{Code: "ps", EnglishName: "Pashto", NativeName: "پښتو", Script: "Arab", RTL: true},
+	{Code: "pt", EnglishName: "Portuguese", NativeName: "Português", Script: "Latn"},
+	{Code: "pt-BR", EnglishName: "Portuguese (Brazil)", NativeName: "Português (Brasil)", Script: "Latn"},
+	{Code: "ro", EnglishName: "Romanian", NativeName: "Română", Script: "Latn"},
+	{Code: "ru", EnglishName: "Russian", NativeName: "Русский", Script: "Cyrl"},
+	{Code: "sk", EnglishName: "Slovak", NativeName: "Slovenčina", Script: "Latn"},
+	{Code: "sl", EnglishName: "Slovenian", NativeName: "Slovenščina", Script: "Latn"},
+	{Code: "sq", EnglishName: "Albanian", NativeName: "Shqip", Script: "Latn"},
+	{Code: "sr", EnglishName: "Serbian", NativeName: "Српски", Script: "Cyrl"},
+	{Code: "sr-Latn", EnglishName: "Serbian (Latin)", NativeName: "Srpski", Script: "Latn"},
+	{Code: "sv", EnglishName: "Swedish", NativeName: "Svenska", Script: "Latn"},
+	{Code: "sw", EnglishName: "Swahili", NativeName: "Kiswahili", Script: "Latn"},
+	{Code: "ta", EnglishName: "Tamil", NativeName: "தமிழ்", Script: "Taml"},
+	{Code: "te", EnglishName: "Telugu", NativeName: "తెలుగు", Script: "Telu"},
+	{Code: "th", EnglishName: "Thai", NativeName: "ไทย", Script: "Thai"},
+	{Code: "tl", EnglishName: "Tagalog", NativeName: "Tagalog", Script: "Latn"},
+	{Code: "tr", EnglishName: "Turkish", NativeName: "Türkçe", Script: "Latn"},
+	{Code: "uk", EnglishName: "Ukrainian", NativeName: "Українська", Script: "Cyrl"},
+	{Code: "ur", EnglishName: "Urdu", NativeName: "اردو", Script: "Arab", RTL: true},
+	{Code: "uz", EnglishName: "Uzbek", NativeName: "Oʻzbekcha", Script: "Latn"},
+	{Code: "vi", EnglishName: "Vietnamese", NativeName: "Tiếng Việt", Script: "Latn"},
+	{Code: "yi", EnglishName: "Yiddish", NativeName: "ייִדיש", Script: "Hebr", RTL: true},
+	{Code: "zh-Hans", EnglishName: "Chinese (Simplified)", NativeName: "简体中文", Script: "Hans"},
+	{Code: "zh-Hant", EnglishName: "Chinese (Traditional)", NativeName: "繁體中文", Script: "Hant"},
+}
+
+var languagesByCode = func() map[string]Language {
+	m := make(map[string]Language, len(LanguageCatalogue))
+	for _, l := range LanguageCatalogue {
+		m[strings.ToLower(l.Code)] = l
+	}
+	return m
+}()
+
+// LanguageByCode ищет язык каталога по коду без учёта регистра
+func LanguageByCode(code string) (Language, bool) {
+	l, ok := languagesByCode[strings.ToLower(strings.TrimSpace(code))]
+	return l, ok
+}
+
+// LangTag - код языка каталога у текста, который выводится в шаблоне
+// с атрибутами lang и dir. Встраивается в структуры с переводами.
+type LangTag struct {
+	LangCode *string
+}
+
+// Catalogue возвращает язык каталога или nil
+func (t LangTag) Catalogue() *Language {
+	if t.LangCode == nil {
+		return nil
+	}
+	if l, ok := LanguageByCode(*t.LangCode); ok {
+		return &l
+	}
+	return nil
+}
+
+// Code возвращает код языка или пустую строку
+func (t LangTag) Code() string {
+	if t.LangCode == nil {
+		return ""
+	}
+	return *t.LangCode
+}
+
+// HTMLLang возвращает значение атрибута lang, "und" - язык не определён
+func (t LangTag) HTMLLang() string {
+	if l := t.Catalogue(); l != nil {
+		return l.Code
+	}
+	return "und"
+}
+
+// Dir возвращает значение атрибута dir; для языков не из каталога
+// направление определяет браузер
+func (t LangTag) Dir() string {
+	if l := t.Catalogue(); l != nil {
+		return l.Dir()
+	}
+	return "auto"
+}
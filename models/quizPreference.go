@@ -0,0 +1,75 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Направления вопросов в тренировке
+const (
+	DirectionForward = "forward" // основной язык -> изучаемый
+	DirectionReverse = "reverse" // изучаемый -> основной
+	DirectionMixed   = "mixed"   // случайно для каждой карточки
+)
+
+// Карточки с пропуском в примере предложения
+const (
+	ClozeOff    = ""       // обычные карточки
+	ClozeChoice = "choice" // выбор из вариантов
+	ClozeTyped  = "typed"  // ответ вводится с клавиатуры
+)
+
+// QuizPreference запоминает настройки тренировки пользователя для колоды
+type QuizPreference struct {
+	ID            uint   `gorm:"primaryKey"`
+	UserID        uint   `gorm:"not null;uniqueIndex:idx_quiz_prefs_user_deck"`
+	DeckID        uint   `gorm:"not null;uniqueIndex:idx_quiz_prefs_user_deck"`
+	MainLangID    uint   `gorm:"not null"`
+	TargetLangIDs string `gorm:"size:255"` // ID через запятую
+	Direction     string `gorm:"size:10;not null;default:forward"`
+	CardCount     int    `gorm:"not null;default:0"` // 0 - все слова колоды
+	ClozeMode     string `gorm:"size:10;not null;default:''"`
+
+	User User `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Deck Deck `gorm:"foreignKey:DeckID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// Targets возвращает выбранные изучаемые языки
+func (p QuizPreference) Targets() []uint {
+	var ids []uint
+	for _, part := range strings.Split(p.TargetLangIDs, ",") {
+		if id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64); err == nil {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
+}
+
+// HasTarget сообщает, выбран ли язык как изучаемый
+func (p QuizPreference) HasTarget(langID uint) bool {
+	for _, id := range p.Targets() {
+		if id == langID {
+			return true
+		}
+	}
+	return false
+}
+
+// SetTargets сохраняет список изучаемых языков
+func (p *QuizPreference) SetTargets(ids []uint) {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	p.TargetLangIDs = strings.Join(parts, ",")
+}
+
+// IsValidDirection проверяет значение направления
+func IsValidDirection(d string) bool {
+	return d == DirectionForward || d == DirectionReverse || d == DirectionMixed
+}
+
+// IsValidClozeMode проверяет значение режима карточек с пропуском
+func IsValidClozeMode(m string) bool {
+	return m == ClozeOff || m == ClozeChoice || m == ClozeTyped
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Review - один ответ пользователя на карточку: слово проверялось на языке LangID
+type Review struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	DeckID    uint      `gorm:"index"`
+	WordID    uint      `gorm:"not null;index"`
+	LangID    uint      `gorm:"not null;index"`
+	Answer    string    `gorm:"size:255"`
+	Correct   bool      `gorm:"not null"`
+	CreatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP;index"`
+
+	User     User     `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Word     Word     `gorm:"foreignKey:WordID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	UserLang UserLang `gorm:"foreignKey:LangID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// SmartRule описывает правило умной колоды: состав колоды вычисляется
+// при просмотре и тренировке, а не хранится в deck_words.
+type SmartRule struct {
+	TagIDs      []uint `json:"tag_ids,omitempty"`
+	LangIDs     []uint `json:"lang_ids"`
+	AddedAfter  string `json:"added_after,omitempty"`  // YYYY-MM-DD
+	MaxAccuracy int    `json:"max_accuracy,omitempty"` // в процентах, 0 - без ограничения
+}
+
+const SmartRuleDateLayout = "2006-01-02"
+
+// Validate проверяет правило перед сохранением
+func (r SmartRule) Validate() error {
+	if len(r.LangIDs) == 0 {
+		return errors.New("smart deck needs at least one language")
+	}
+	if r.AddedAfter != "" {
+		if _, err := time.Parse(SmartRuleDateLayout, r.AddedAfter); err != nil {
+			return errors.New("invalid date")
+		}
+	}
+	if r.MaxAccuracy < 0 || r.MaxAccuracy > 100 {
+		return errors.New("accuracy must be between 0 and 100")
+	}
+	return nil
+}
+
+// Encode сериализует правило для Deck.SmartRule
+func (r SmartRule) Encode() (string, error) {
+	b, err := json.Marshal(r)
+	return string(b), err
+}
+
+// ParseSmartRule разбирает Deck.SmartRule
+func ParseSmartRule(raw string) (SmartRule, error) {
+	var r SmartRule
+	err := json.Unmarshal([]byte(raw), &r)
+	return r, err
+}
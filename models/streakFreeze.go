@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// StreakFreeze - день без занятий, который не прервал серию благодаря заморозке
+type StreakFreeze struct {
+	ID     uint      `gorm:"primaryKey"`
+	UserID uint      `gorm:"not null;uniqueIndex:idx_streak_freezes_user_day"`
+	Day    time.Time `gorm:"type:date;not null;uniqueIndex:idx_streak_freezes_user_day"`
+
+	User User `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
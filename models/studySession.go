@@ -0,0 +1,110 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Состояния учебной сессии
+const (
+	StudySessionActive   = "active"
+	StudySessionFinished = "finished"
+)
+
+// Режимы сессии
+const (
+	StudyModeStudy = "study" // результат показывается после каждой карточки
+	StudyModeExam  = "exam"  // результат только в конце, возможны ограничения времени
+	StudyModeMatch = "match" // все карточки сразу, нужно сопоставить пары
+)
+
+// StudySession - тренировка по одной карточке. Состояние хранится на сервере,
+// поэтому сессию можно продолжить после перезагрузки или с другого устройства.
+type StudySession struct {
+	ID         uint      `gorm:"primaryKey"`
+	UserID     uint      `gorm:"not null;index"`
+	DeckID     uint      `gorm:"not null;index"`
+	MainLangID uint      `gorm:"not null"`
+	Mode       string    `gorm:"size:10;not null;default:study"`
+	Status     string    `gorm:"size:10;not null;default:active"`
+	Position   int       `gorm:"not null;default:0"` // индекс текущей карточки
+	CreatedAt  time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt  time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	FinishedAt *time.Time
+
+	TimeLimitSec     int        `gorm:"not null;default:0"` // на всю сессию, 0 - без ограничения
+	CardTimeLimitSec int        `gorm:"not null;default:0"` // на одну карточку, 0 - без ограничения
+	CardShownAt      *time.Time // когда текущая карточка была показана впервые
+
+	User User `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Deck Deck `gorm:"foreignKey:DeckID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// StudyCard - одна карточка сессии: вопрос, варианты и ответ пользователя
+type StudyCard struct {
+	ID         uint   `gorm:"primaryKey"`
+	SessionID  uint   `gorm:"not null;uniqueIndex:idx_study_cards_session_pos"`
+	Position   int    `gorm:"not null;uniqueIndex:idx_study_cards_session_pos"`
+	WordID     uint   `gorm:"not null"`
+	LangID     uint   `gorm:"not null"` // изучаемый язык карточки
+	Reverse    bool   `gorm:"not null;default:false"`
+	Cloze      bool   `gorm:"not null;default:false"` // вопрос - пример с пропуском
+	Typed      bool   `gorm:"not null;default:false"` // ответ вводится, вариантов нет
+	Prompt     string `gorm:"size:255;not null"`      // слово или предложение с пропуском
+	Options    string `gorm:"type:text;not null"`     // JSON-массив вариантов
+	Expected   string `gorm:"size:255;not null"`      // основной правильный ответ
+	Answer     string `gorm:"size:255"`
+	Answered   bool   `gorm:"not null;default:false"`
+	Correct    bool   `gorm:"not null;default:false"`
+	TimedOut   bool   `gorm:"not null;default:false"` // время на ответ истекло
+	AnsweredAt *time.Time
+
+	Session  StudySession `gorm:"foreignKey:SessionID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Word     Word         `gorm:"foreignKey:WordID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	UserLang UserLang     `gorm:"foreignKey:LangID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// IsExam сообщает, идёт ли сессия в режиме экзамена
+func (s StudySession) IsExam() bool {
+	return s.Mode == StudyModeExam
+}
+
+// IsMatch сообщает, что сессия - игра на сопоставление пар
+func (s StudySession) IsMatch() bool {
+	return s.Mode == StudyModeMatch
+}
+
+// Deadline возвращает время окончания сессии, если оно ограничено
+func (s StudySession) Deadline() (time.Time, bool) {
+	if s.TimeLimitSec <= 0 {
+		return time.Time{}, false
+	}
+	return s.CreatedAt.Add(time.Duration(s.TimeLimitSec) * time.Second), true
+}
+
+// CardDeadline возвращает время, до которого нужно ответить на текущую карточку
+func (s StudySession) CardDeadline() (time.Time, bool) {
+	if s.CardTimeLimitSec <= 0 || s.CardShownAt == nil {
+		return time.Time{}, false
+	}
+	return s.CardShownAt.Add(time.Duration(s.CardTimeLimitSec) * time.Second), true
+}
+
+// OptionList возвращает варианты ответа карточки
+func (c StudyCard) OptionList() []string {
+	var options []string
+	if err := json.Unmarshal([]byte(c.Options), &options); err != nil {
+		return nil
+	}
+	return options
+}
+
+// SetOptions сохраняет варианты ответа карточки
+func (c *StudyCard) SetOptions(options []string) error {
+	raw, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	c.Options = string(raw)
+	return nil
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// Tag - метка пользователя для группировки слов
+type Tag struct {
+	ID     uint   `gorm:"primaryKey"`
+	UserID uint   `gorm:"not null;uniqueIndex:idx_tags_user_name"`
+	Name   string `gorm:"size:30;not null;uniqueIndex:idx_tags_user_name"`
+
+	User User `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// WordTag связывает слово с меткой (многие ко многим)
+type WordTag struct {
+	ID     uint `gorm:"primaryKey"`
+	WordID uint `gorm:"not null;uniqueIndex:idx_word_tags_word_tag"`
+	TagID  uint `gorm:"not null;uniqueIndex:idx_word_tags_word_tag;index"`
+
+	Word Word `gorm:"foreignKey:WordID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Tag  Tag  `gorm:"foreignKey:TagID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// ParseTagNames разбирает строку меток "a, b, c" и проверяет длину каждой
+func ParseTagNames(raw string) ([]string, error) {
+	seen := make(map[string]bool)
+	var names []string
+	for _, part := range strings.Split(raw, ",") {
+		name := strings.ToLower(strings.TrimSpace(part))
+		if name == "" || seen[name] {
+			continue
+		}
+		if utf8.RuneCountInString(name) > 30 {
+			return nil, errors.New("tag names must be at most 30 characters")
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names, nil
+}
@@ -5,6 +5,7 @@ import (
 	"regexp"
 
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -15,6 +16,68 @@ type User struct {
 	ID       uint   `gorm:"primaryKey"`
 	Username string `gorm:"unique;not null"`
 	Password string `gorm:"not null"`
+
+	LeechThreshold   int  `gorm:"not null;default:8"` // ошибок до пометки "leech", 0 - не помечать
+	LeechAutoSuspend bool `gorm:"not null;default:false"`
+
+	Timezone        string     `gorm:"size:64;not null;default:UTC"` // IANA, задаёт границы дня
+	DailyGoalType   string     `gorm:"size:10;not null;default:cards"`
+	DailyGoal       int        `gorm:"not null;default:20"`
+	StreakFreezes   int        `gorm:"not null;default:0"` // доступные "заморозки" серии
+	FreezeAwardedOn *time.Time `gorm:"type:date"`          // день последней выданной заморозки
+
+	// Родной язык пользователя: по умолчанию язык вопросов в тренировках.
+	// Без внешнего ключа, потому что user_langs сама ссылается на users.
+	MainLangID *uint
+
+	CreatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	// Версия растёт при изменении профиля и настроек; формы настроек
+	// отправляют её, чтобы не перезаписать правку из другой вкладки.
+	// Служебные счётчики (заморозки серии) версию не меняют.
+	Version int `gorm:"not null;default:1"`
+}
+
+// Типы дневной цели
+const (
+	GoalCards   = "cards"   // число ответов
+	GoalMinutes = "minutes" // минуты занятий
+)
+
+// Ограничения дневной цели и заморозок серии
+const (
+	MaxDailyGoalCards   = 1000
+	MaxDailyGoalMinutes = 600
+	MaxStreakFreezes    = 2
+	StreakFreezeEvery   = 7 // за каждые 7 дней серии выдаётся заморозка
+)
+
+// ValidateDailyGoal проверяет тип и размер дневной цели
+func ValidateDailyGoal(goalType string, goal int) error {
+	switch goalType {
+	case GoalCards:
+		if goal < 1 || goal > MaxDailyGoalCards {
+			return errors.New("daily goal must be between 1 and 1000 cards")
+		}
+	case GoalMinutes:
+		if goal < 1 || goal > MaxDailyGoalMinutes {
+			return errors.New("daily goal must be between 1 and 600 minutes")
+		}
+	default:
+		return errors.New("unknown daily goal type")
+	}
+	return nil
+}
+
+// ValidateTimezone проверяет название часового пояса (например, Europe/Moscow)
+func ValidateTimezone(name string) error {
+	if name == "" || len(name) > 64 {
+		return errors.New("time zone is required")
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return errors.New("unknown time zone")
+	}
+	return nil
 }
 
 // BeforeSave хеширует пароль перед сохранением пользователя
@@ -80,6 +143,17 @@ func ValidateUsername(username string) error {
 	return nil
 }
 
+// MaxLeechThreshold - наибольший допустимый порог ошибок для пометки "leech"
+const MaxLeechThreshold = 100
+
+// ValidateLeechThreshold проверяет порог ошибок; 0 отключает пометку
+func ValidateLeechThreshold(n int) error {
+	if n < 0 || n > MaxLeechThreshold {
+		return errors.New("leech threshold must be between 0 and 100")
+	}
+	return nil
+}
+
 // ValidatePassword проверяет, соответствует ли пароль заданным требованиям
 func ValidatePassword(password string) error {
 	if len(password) < 8 {
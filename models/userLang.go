@@ -1,9 +1,64 @@
 package models
 
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// UserLang - язык пользователя. LangCode ссылается на каталог языков;
+// LangTitle - отображаемое название: своя подпись или название из каталога.
 type UserLang struct {
-	ID        uint   `gorm:"primaryKey"`
-	UserID    uint   `gorm:"not null;index"`
-	LangTitle string `gorm:"size:50"`
+	ID        uint    `gorm:"primaryKey"`
+	UserID    uint    `gorm:"not null;index;uniqueIndex:idx_user_langs_user_code"`
+	LangTitle string  `gorm:"size:50"`
+	LangCode  *string `gorm:"size:20;uniqueIndex:idx_user_langs_user_code"` // nil - язык не из каталога
+	Position  int     `gorm:"not null;default:0"`                           // порядок колонок в таблицах слов
+
+	CreatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	// Версия растёт при каждом изменении языка, кроме переноса в корзину
+	// и смены порядка
+	Version int `gorm:"not null;default:1"`
+	// Время переноса в корзину, nil - язык не удалён
+	DeletedAt *time.Time `gorm:"index"`
+
+	User     User      `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Language *Language `gorm:"foreignKey:LangCode;references:Code;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+// Catalogue возвращает язык каталога, на который ссылается язык пользователя,
+// или nil для языка не из каталога
+func (l UserLang) Catalogue() *Language {
+	return LangTag{LangCode: l.LangCode}.Catalogue()
+}
+
+// Code возвращает код языка из каталога или пустую строку
+func (l UserLang) Code() string {
+	return LangTag{LangCode: l.LangCode}.Code()
+}
+
+// HTMLLang возвращает значение атрибута lang
+func (l UserLang) HTMLLang() string {
+	return LangTag{LangCode: l.LangCode}.HTMLLang()
+}
+
+// Dir возвращает значение атрибута dir
+func (l UserLang) Dir() string {
+	return LangTag{LangCode: l.LangCode}.Dir()
+}
+
+// HasCustomLabel сообщает, отличается ли название от названия в каталоге
+func (l UserLang) HasCustomLabel() bool {
+	c := l.Catalogue()
+	return c != nil && l.LangTitle != c.EnglishName
+}
 
-	User User `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+// ValidateLangLabel проверяет название языка (колонка lang_title - size:50)
+func ValidateLangLabel(label string) error {
+	if utf8.RuneCountInString(strings.TrimSpace(label)) > 50 {
+		return errors.New("language name must be at most 50 characters")
+	}
+	return nil
 }
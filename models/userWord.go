@@ -1,10 +1,27 @@
 package models
 
+import "time"
+
+// UserWord хранит перевод слова на язык пользователя. У слова может быть
+// несколько переводов на один язык: основной (IsPrimary) используется для
+// отображения и вариантов ответа, остальные принимаются как синонимы.
 type UserWord struct {
 	ID          uint   `gorm:"primaryKey"`
 	LangID      uint   `gorm:"not null;index"`
 	WordID      uint   `gorm:"not null;index"`
 	Translation string `gorm:"size:50"`
+	IsPrimary   bool   `gorm:"not null;default:true"`
+
+	// Метаданные заполняются только у основного перевода
+	Gender        string `gorm:"size:20"`
+	Pronunciation string `gorm:"size:100"`
+	Notes         string `gorm:"size:500"`
+
+	CreatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	// Версия перевода; формы слова сверяют версию самого слова (Word.Version),
+	// которая растёт при любом изменении его переводов
+	Version int `gorm:"not null;default:1"`
 
 	UserLang UserLang `gorm:"foreignKey:LangID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	Word     Word     `gorm:"foreignKey:WordID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
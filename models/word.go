@@ -1,5 +1,42 @@
 package models
 
+import "time"
+
 type Word struct {
-	ID uint `gorm:"primaryKey"`
+	ID           uint      `gorm:"primaryKey"`
+	PartOfSpeech string    `gorm:"size:20"`
+	Notes        string    `gorm:"size:500"`
+	CreatedAt    time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	// Версия растёт при каждом изменении переводов слова; по ней таблица слов
+	// узнаёт, что слово изменили в другом месте
+	Version int `gorm:"not null;default:1"`
+	// Время переноса в корзину, nil - слово не удалено
+	DeletedAt *time.Time `gorm:"index"`
+}
+
+// PartsOfSpeech перечисляет допустимые значения Word.PartOfSpeech
+var PartsOfSpeech = []string{
+	"noun",
+	"verb",
+	"adjective",
+	"adverb",
+	"pronoun",
+	"preposition",
+	"conjunction",
+	"interjection",
+	"numeral",
+	"phrase",
+}
+
+// IsValidPartOfSpeech проверяет значение части речи; пустое значение допустимо
+func IsValidPartOfSpeech(pos string) bool {
+	if pos == "" {
+		return true
+	}
+	for _, p := range PartsOfSpeech {
+		if p == pos {
+			return true
+		}
+	}
+	return false
 }
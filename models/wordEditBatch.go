@@ -0,0 +1,39 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WordCellChange - изменение основного перевода слова на один язык в таблице слов
+type WordCellChange struct {
+	WordID  uint   `json:"word_id"`
+	LangID  uint   `json:"lang_id"`
+	Old     string `json:"old"` // пустое значение - перевода не было
+	New     string `json:"new"`
+	Version int    `json:"version"` // версия слова после изменения
+}
+
+// WordEditBatch - последний сохранённый пакет правок в таблице слов.
+// У пользователя хранится только один пакет: его можно отменить.
+type WordEditBatch struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;uniqueIndex"`
+	Changes   string    `gorm:"type:text;not null"` // JSON []WordCellChange
+	CreatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+
+	User User `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// EncodeWordCellChanges сериализует изменения для WordEditBatch.Changes
+func EncodeWordCellChanges(changes []WordCellChange) (string, error) {
+	b, err := json.Marshal(changes)
+	return string(b), err
+}
+
+// ParseWordCellChanges разбирает WordEditBatch.Changes
+func ParseWordCellChanges(raw string) ([]WordCellChange, error) {
+	var changes []WordCellChange
+	err := json.Unmarshal([]byte(raw), &changes)
+	return changes, err
+}
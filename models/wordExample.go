@@ -0,0 +1,12 @@
+package models
+
+// WordExample хранит пример употребления слова на конкретном языке
+type WordExample struct {
+	ID       uint   `gorm:"primaryKey"`
+	WordID   uint   `gorm:"not null;index"`
+	LangID   uint   `gorm:"not null;index"`
+	Sentence string `gorm:"size:255"`
+
+	Word     Word     `gorm:"foreignKey:WordID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	UserLang UserLang `gorm:"foreignKey:LangID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
@@ -0,0 +1,57 @@
+package routes
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// normalizeAnswer приводит ответ к виду для сравнения:
+// без регистра и лишних пробелов.
+func normalizeAnswer(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// isAcceptedAnswer проверяет, совпадает ли ответ с одним из допустимых переводов.
+func isAcceptedAnswer(answer string, accepted []string) bool {
+	given := normalizeAnswer(answer)
+	if given == "" {
+		return false
+	}
+	for _, a := range accepted {
+		if normalizeAnswer(a) == given {
+			return true
+		}
+	}
+	return false
+}
+
+// loadAcceptedTranslations возвращает все переводы слова на язык,
+// основной перевод идёт первым.
+func loadAcceptedTranslations(db *gorm.DB, wordID, langID uint) ([]string, error) {
+	var translations []string
+	err := db.Raw(`
+		SELECT translation FROM langhelpercopy.user_words
+		WHERE word_id = ? AND lang_id = ?
+		ORDER BY is_primary DESC, id
+	`, wordID, langID).Scan(&translations).Error
+	return translations, err
+}
+
+// parseAlternatives разбирает строку синонимов вида "a; b; c",
+// убирая пустые значения, повторы и совпадения с основным переводом.
+// Запятая разделителем не считается: она бывает внутри синонима.
+func parseAlternatives(raw, primary string) []string {
+	seen := map[string]bool{normalizeAnswer(primary): true}
+	var result []string
+	for _, part := range strings.Split(raw, ";") {
+		alt := strings.TrimSpace(part)
+		key := normalizeAnswer(alt)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, alt)
+	}
+	return result
+}
@@ -0,0 +1,474 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"langhelperCopy/config"
+	"langhelperCopy/database"
+	"langhelperCopy/models"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Массовые действия над выбранными словами
+const (
+	bulkAddToDecks     = "add_to_decks"
+	bulkRemoveFromDeck = "remove_from_deck"
+	bulkAddTags        = "add_tags"
+	bulkRemoveTags     = "remove_tags"
+	bulkDelete         = "delete"
+	bulkMoveLang       = "move_lang"
+)
+
+var bulkActionTitles = map[string]string{
+	bulkAddToDecks:     "Add to decks",
+	bulkRemoveFromDeck: "Remove from deck",
+	bulkAddTags:        "Add tags",
+	bulkRemoveTags:     "Remove tags",
+	bulkDelete:         "Delete words",
+	bulkMoveLang:       "Move translations",
+}
+
+// BulkActionsData - данные панели массовых действий на странице со списком слов
+type BulkActionsData struct {
+	FormID    string
+	ReturnURL string
+	Deck      *models.Deck // колода, из которой можно убрать слова
+	Decks     []models.Deck
+	Langs     []models.UserLang
+}
+
+// BulkItemResult - результат действия над одним словом
+type BulkItemResult struct {
+	WordID  uint
+	Label   string
+	OK      bool
+	Message string
+}
+
+// BulkWordsPageData - данные страницы с результатами массового действия
+type BulkWordsPageData struct {
+	Title     string
+	Action    string
+	Results   []BulkItemResult
+	Succeeded int
+	Error     string
+	ReturnURL string
+}
+
+// bulkInputError - ошибка в параметрах действия; ничего не изменено
+type bulkInputError string
+
+func (e bulkInputError) Error() string {
+	return string(e)
+}
+
+// loadManualDecks возвращает обычные (не умные) колоды пользователя, в которые
+// слова добавляются вручную
+func loadManualDecks(db *gorm.DB, userID uint) ([]models.Deck, error) {
+	var decks []models.Deck
+	err := db.Raw(`
+		SELECT * FROM langhelpercopy.decks
+		WHERE user_id = ? AND smart_rule = '' AND deleted_at IS NULL
+		ORDER BY deck_title
+	`, userID).Scan(&decks).Error
+	return decks, err
+}
+
+// loadWordLabels возвращает для каждого слова основной перевод на первый по
+// порядку язык пользователя, чтобы показать слово в отчёте
+func loadWordLabels(db *gorm.DB, userID uint, wordIDs []uint) (map[uint]string, error) {
+	labels := make(map[uint]string, len(wordIDs))
+	if len(wordIDs) == 0 {
+		return labels, nil
+	}
+	var rows []struct {
+		WordID      uint
+		Translation string
+	}
+	err := db.Raw(`
+		SELECT DISTINCT ON (uw.word_id) uw.word_id, uw.translation
+		FROM langhelpercopy.user_words uw
+		JOIN langhelpercopy.user_langs ul ON uw.lang_id = ul.id
+		WHERE ul.user_id = ? AND uw.word_id IN (?) AND uw.is_primary AND uw.translation <> ''
+		ORDER BY uw.word_id, ul.position, ul.id
+	`, userID, wordIDs).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		labels[row.WordID] = row.Translation
+	}
+	return labels, nil
+}
+
+// parseIDList разбирает повторяющийся параметр формы со списком ID без повторов
+func parseIDList(values []string) []uint {
+	var ids []uint
+	for _, raw := range values {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil || containsID(ids, uint(id)) {
+			continue
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids
+}
+
+// safeReturnURL принимает только относительный адрес внутри сайта
+func safeReturnURL(raw string) string {
+	if !strings.HasPrefix(raw, "/") || strings.HasPrefix(raw, "//") {
+		return "/mywords"
+	}
+	return raw
+}
+
+// BulkWordsHandler применяет одно действие к выбранным словам в одной транзакции
+// и показывает результат по каждому слову. Ошибка базы данных откатывает всё действие.
+func BulkWordsHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	action := r.FormValue("action")
+	title, known := bulkActionTitles[action]
+	if !known {
+		http.Error(w, "Unknown action", http.StatusBadRequest)
+		return
+	}
+
+	data := BulkWordsPageData{
+		Title:     "Bulk Word Actions",
+		Action:    title,
+		ReturnURL: safeReturnURL(r.FormValue("return")),
+	}
+
+	db := database.GetDB()
+
+	requested := parseIDList(r.Form["word_id"])
+	var owned []uint
+	if len(requested) > 0 {
+		err = db.Raw(`
+			SELECT DISTINCT uw.word_id
+			FROM langhelpercopy.user_words uw
+			JOIN langhelpercopy.user_langs ul ON uw.lang_id = ul.id
+			JOIN langhelpercopy.words w ON uw.word_id = w.id
+			WHERE ul.user_id = ? AND uw.word_id IN (?)
+				AND ul.deleted_at IS NULL AND w.deleted_at IS NULL
+		`, userID, requested).Scan(&owned).Error
+		if err != nil {
+			log.Printf("Failed to load words: %v", err)
+			http.Error(w, "Failed to load words", http.StatusInternalServerError)
+			return
+		}
+	}
+	labels, err := loadWordLabels(db, userID, owned)
+	if err != nil {
+		log.Printf("Failed to load words: %v", err)
+		http.Error(w, "Failed to load words", http.StatusInternalServerError)
+		return
+	}
+
+	var results map[uint]BulkItemResult
+	if len(requested) == 0 {
+		data.Error = "Select at least one word"
+	} else if len(owned) > 0 {
+		err = database.UnitOfWork(db, func(tx *gorm.DB) error {
+			var err error
+			results, err = applyBulkAction(tx, userID, action, r, owned)
+			return err
+		})
+		var inputErr bulkInputError
+		if errors.As(err, &inputErr) {
+			data.Error = inputErr.Error()
+			results = nil
+		} else if err != nil {
+			log.Printf("Bulk action %s failed: %v", action, err)
+			data.Error = "Something went wrong, nothing was changed. Please try again."
+			results = nil
+		}
+	}
+
+	if data.Error == "" {
+		for _, wordID := range requested {
+			result, ok := results[wordID]
+			if !containsID(owned, wordID) {
+				result = BulkItemResult{Message: "word not found"}
+			} else if !ok {
+				result = BulkItemResult{Message: "skipped"}
+			}
+			result.WordID = wordID
+			result.Label = labels[wordID]
+			if result.OK {
+				data.Succeeded++
+			}
+			data.Results = append(data.Results, result)
+		}
+	}
+
+	tmpl, err := template.ParseFiles("templates/layout.html", "templates/bulkWords.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+	}
+}
+
+// applyBulkAction выполняет действие над словами пользователя внутри транзакции
+func applyBulkAction(tx *gorm.DB, userID uint, action string, r *http.Request, wordIDs []uint) (map[uint]BulkItemResult, error) {
+	switch action {
+	case bulkAddToDecks:
+		return bulkAddWordsToDecks(tx, userID, parseIDList(r.Form["deck_id"]), wordIDs)
+	case bulkRemoveFromDeck:
+		deckID, _ := strconv.ParseUint(r.FormValue("from_deck_id"), 10, 64)
+		return bulkRemoveWordsFromDeck(tx, userID, uint(deckID), wordIDs)
+	case bulkAddTags, bulkRemoveTags:
+		names, err := models.ParseTagNames(r.FormValue("tags"))
+		if err != nil {
+			return nil, bulkInputError(err.Error())
+		}
+		return bulkTagWords(tx, userID, names, action == bulkAddTags, wordIDs)
+	case bulkDelete:
+		return bulkDeleteWords(tx, userID, wordIDs)
+	case bulkMoveLang:
+		return bulkMoveTranslations(tx, userID, r.FormValue("from_lang_id"), r.FormValue("to_lang_id"), wordIDs)
+	}
+	return nil, bulkInputError("unknown action")
+}
+
+// bulkAddWordsToDecks добавляет слова в выбранные обычные колоды
+func bulkAddWordsToDecks(tx *gorm.DB, userID uint, deckIDs []uint, wordIDs []uint) (map[uint]BulkItemResult, error) {
+	if len(deckIDs) == 0 {
+		return nil, bulkInputError("choose at least one deck")
+	}
+	var decks []models.Deck
+	err := tx.Raw("SELECT * FROM langhelpercopy.decks WHERE id IN (?) AND user_id = ? AND deleted_at IS NULL ORDER BY deck_title", deckIDs, userID).Scan(&decks).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(decks) != len(deckIDs) {
+		return nil, bulkInputError("deck not found")
+	}
+	for _, deck := range decks {
+		if deck.IsSmart() {
+			return nil, bulkInputError(fmt.Sprintf("words can't be added to the smart deck %q", deck.DeckTitle))
+		}
+	}
+
+	results := make(map[uint]BulkItemResult, len(wordIDs))
+	for _, wordID := range wordIDs {
+		var added, present []string
+		for _, deck := range decks {
+			result := tx.Exec(`
+				INSERT INTO langhelpercopy.deck_words (deck_id, word_id)
+				SELECT ?, ?
+				WHERE NOT EXISTS (
+					SELECT 1 FROM langhelpercopy.deck_words WHERE deck_id = ? AND word_id = ?
+				)
+			`, deck.ID, wordID, deck.ID, wordID)
+			if result.Error != nil {
+				return nil, result.Error
+			}
+			if result.RowsAffected > 0 {
+				added = append(added, deck.DeckTitle)
+			} else {
+				present = append(present, deck.DeckTitle)
+			}
+		}
+		var parts []string
+		if len(added) > 0 {
+			parts = append(parts, "added to "+strings.Join(added, ", "))
+		}
+		if len(present) > 0 {
+			parts = append(parts, "already in "+strings.Join(present, ", "))
+		}
+		results[wordID] = BulkItemResult{OK: len(added) > 0, Message: strings.Join(parts, "; ")}
+	}
+	return results, nil
+}
+
+// bulkRemoveWordsFromDeck убирает слова из обычной колоды
+func bulkRemoveWordsFromDeck(tx *gorm.DB, userID, deckID uint, wordIDs []uint) (map[uint]BulkItemResult, error) {
+	deck, err := loadUserDeck(tx, deckID, userID)
+	if errors.Is(err, errDeckNotFound) {
+		return nil, bulkInputError("deck not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deck.IsSmart() {
+		return nil, bulkInputError("words of a smart deck are chosen by its rule")
+	}
+
+	results := make(map[uint]BulkItemResult, len(wordIDs))
+	for _, wordID := range wordIDs {
+		result := tx.Exec("DELETE FROM langhelpercopy.deck_words WHERE deck_id = ? AND word_id = ?", deck.ID, wordID)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected > 0 {
+			results[wordID] = BulkItemResult{OK: true, Message: "removed from " + deck.DeckTitle}
+		} else {
+			results[wordID] = BulkItemResult{Message: "not in " + deck.DeckTitle}
+		}
+	}
+	return results, nil
+}
+
+// bulkTagWords добавляет словам метки или снимает их
+func bulkTagWords(tx *gorm.DB, userID uint, names []string, add bool, wordIDs []uint) (map[uint]BulkItemResult, error) {
+	if len(names) == 0 {
+		return nil, bulkInputError("enter at least one tag")
+	}
+	message := "tagged with " + strings.Join(names, ", ")
+	if !add {
+		message = "removed tags " + strings.Join(names, ", ")
+	}
+
+	results := make(map[uint]BulkItemResult, len(wordIDs))
+	for _, wordID := range wordIDs {
+		for _, name := range names {
+			var err error
+			if add {
+				err = addWordTag(tx, userID, wordID, name)
+			} else {
+				err = removeWordTag(tx, userID, wordID, name)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		results[wordID] = BulkItemResult{OK: true, Message: message}
+	}
+	return results, nil
+}
+
+// bulkDeleteWords переносит слова в корзину; переводы, связи с колодами,
+// метки и статистика остаются до окончательного удаления
+func bulkDeleteWords(tx *gorm.DB, userID uint, wordIDs []uint) (map[uint]BulkItemResult, error) {
+	labels, err := loadWordLabels(tx, userID, wordIDs)
+	if err != nil {
+		return nil, err
+	}
+	results := make(map[uint]BulkItemResult, len(wordIDs))
+	for _, wordID := range wordIDs {
+		result := tx.Exec("UPDATE langhelpercopy.words SET deleted_at = NOW() WHERE id = ? AND deleted_at IS NULL", wordID)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			results[wordID] = BulkItemResult{Message: "already in Trash"}
+			continue
+		}
+		if err := recordEntityChange(tx, userID, models.HistoryWord, wordID, models.HistoryDelete, labels[wordID]); err != nil {
+			return nil, err
+		}
+		results[wordID] = BulkItemResult{OK: true, Message: "moved to Trash"}
+	}
+	return results, nil
+}
+
+// bulkMoveTranslations переносит переводы слов с одного языка на другой вместе
+// с примерами и статистикой ответов. Слова, у которых уже есть перевод на
+// новый язык, пропускаются.
+func bulkMoveTranslations(tx *gorm.DB, userID uint, rawFrom, rawTo string, wordIDs []uint) (map[uint]BulkItemResult, error) {
+	fromID, err := parseOwnLangID(tx, userID, rawFrom)
+	if err != nil {
+		return nil, bulkInputError("choose the language to move translations from")
+	}
+	toID, err := parseOwnLangID(tx, userID, rawTo)
+	if err != nil {
+		return nil, bulkInputError("choose the language to move translations to")
+	}
+	if fromID == toID {
+		return nil, bulkInputError("choose two different languages")
+	}
+	var langs []models.UserLang
+	if err := tx.Raw("SELECT id, lang_title FROM langhelpercopy.user_langs WHERE id IN (?, ?)", fromID, toID).Scan(&langs).Error; err != nil {
+		return nil, err
+	}
+	titles := make(map[uint]string, len(langs))
+	for _, l := range langs {
+		titles[l.ID] = l.LangTitle
+	}
+
+	var hasFrom, hasTo []uint
+	err = tx.Raw("SELECT DISTINCT word_id FROM langhelpercopy.user_words WHERE lang_id = ? AND word_id IN (?)", fromID, wordIDs).Scan(&hasFrom).Error
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Raw("SELECT DISTINCT word_id FROM langhelpercopy.user_words WHERE lang_id = ? AND word_id IN (?)", toID, wordIDs).Scan(&hasTo).Error
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[uint]BulkItemResult, len(wordIDs))
+	var moving []uint
+	for _, wordID := range wordIDs {
+		switch {
+		case !containsID(hasFrom, wordID):
+			results[wordID] = BulkItemResult{Message: "no translation into " + titles[fromID]}
+		case containsID(hasTo, wordID):
+			results[wordID] = BulkItemResult{Message: "already has a translation into " + titles[toID]}
+		default:
+			moving = append(moving, wordID)
+			results[wordID] = BulkItemResult{OK: true, Message: "moved to " + titles[toID]}
+		}
+	}
+	if len(moving) == 0 {
+		return results, nil
+	}
+
+	moved, err := loadPrimaryTranslations(tx, moving)
+	if err != nil {
+		return nil, err
+	}
+	args := map[string]interface{}{"from": fromID, "to": toID, "words": moving}
+	statements := []string{
+		"UPDATE langhelpercopy.user_words SET lang_id = @to, updated_at = NOW(), version = version + 1 WHERE lang_id = @from AND word_id IN @words",
+		"DELETE FROM langhelpercopy.word_examples WHERE lang_id = @to AND word_id IN @words",
+		"UPDATE langhelpercopy.word_examples SET lang_id = @to WHERE lang_id = @from AND word_id IN @words",
+		"DELETE FROM langhelpercopy.card_progresses WHERE lang_id = @to AND word_id IN @words",
+		"UPDATE langhelpercopy.card_progresses SET lang_id = @to WHERE lang_id = @from AND word_id IN @words",
+		"UPDATE langhelpercopy.reviews SET lang_id = @to WHERE lang_id = @from AND word_id IN @words",
+		"UPDATE langhelpercopy.study_cards SET lang_id = @to WHERE lang_id = @from AND word_id IN @words",
+	}
+	for _, stmt := range statements {
+		if err := tx.Exec(stmt, args).Error; err != nil {
+			return nil, err
+		}
+	}
+	if _, err := bumpWordVersions(tx, moving); err != nil {
+		return nil, err
+	}
+	for _, wordID := range moving {
+		translation := moved[gridCellKey{wordID, fromID}]
+		if err := recordTranslationChange(tx, userID, wordID, fromID, translation, ""); err != nil {
+			return nil, err
+		}
+		if err := recordTranslationChange(tx, userID, wordID, toID, "", translation); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
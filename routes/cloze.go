@@ -0,0 +1,114 @@
+package routes
+
+import (
+	"math/rand/v2"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// clozeBlank заменяет слово в примере
+const clozeBlank = "_____"
+
+// loadExampleSentences загружает примеры предложений для слов: слово -> язык -> примеры
+func loadExampleSentences(db *gorm.DB, wordIDs []uint) (map[uint]map[uint][]string, error) {
+	examples := make(map[uint]map[uint][]string)
+	if len(wordIDs) == 0 {
+		return examples, nil
+	}
+
+	var rows []struct {
+		WordID   uint
+		LangID   uint
+		Sentence string
+	}
+	err := db.Raw(`
+		SELECT word_id, lang_id, sentence
+		FROM langhelpercopy.word_examples
+		WHERE word_id IN (?)
+		ORDER BY id
+	`, wordIDs).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if examples[row.WordID] == nil {
+			examples[row.WordID] = make(map[uint][]string)
+		}
+		examples[row.WordID][row.LangID] = append(examples[row.WordID][row.LangID], row.Sentence)
+	}
+	return examples, nil
+}
+
+// pickCloze выбирает случайный пример, в котором встречается один из переводов,
+// и возвращает его с пропуском вместо слова.
+func pickCloze(sentences []string, forms []string) (string, bool) {
+	var candidates []string
+	for _, sentence := range sentences {
+		if cloze, ok := makeCloze(sentence, forms); ok {
+			candidates = append(candidates, cloze)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+	return candidates[rand.IntN(len(candidates))], true
+}
+
+// makeCloze заменяет в предложении все вхождения переводов целыми словами
+// (без учёта регистра) на пропуск. Длинные переводы проверяются первыми,
+// чтобы "ice cream" не превратилось в "_____ cream".
+func makeCloze(sentence string, forms []string) (string, bool) {
+	sorted := append([]string(nil), forms...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return utf8.RuneCountInString(sorted[i]) > utf8.RuneCountInString(sorted[j])
+	})
+
+	text := []rune(sentence)
+	found := false
+	for _, form := range sorted {
+		pattern := []rune(normalizeAnswer(form))
+		if len(pattern) == 0 {
+			continue
+		}
+		var result []rune
+		for i := 0; i < len(text); {
+			if matchesWordAt(text, i, pattern) {
+				result = append(result, []rune(clozeBlank)...)
+				i += len(pattern)
+				found = true
+				continue
+			}
+			result = append(result, text[i])
+			i++
+		}
+		text = result
+	}
+	return string(text), found
+}
+
+// matchesWordAt проверяет, что pattern (в нижнем регистре) стоит в text
+// с позиции i отдельным словом.
+func matchesWordAt(text []rune, i int, pattern []rune) bool {
+	if i+len(pattern) > len(text) {
+		return false
+	}
+	if i > 0 && isWordRune(text[i-1]) {
+		return false
+	}
+	if end := i + len(pattern); end < len(text) && isWordRune(text[end]) {
+		return false
+	}
+	for j, r := range pattern {
+		if unicode.ToLower(text[i+j]) != r {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
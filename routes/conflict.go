@@ -0,0 +1,41 @@
+package routes
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+)
+
+// errStaleVersion - запись изменили в другом месте после того, как
+// пользователь открыл форму
+var errStaleVersion = errors.New("edited elsewhere")
+
+// ConflictPageData - страница "изменено в другом месте"
+type ConflictPageData struct {
+	Title     string
+	What      string // что изменили, например "This deck"
+	ReloadURL string
+}
+
+// formVersion возвращает версию записи, которую видел пользователь, из
+// скрытого поля version формы
+func formVersion(r *http.Request) (int, error) {
+	return strconv.Atoi(r.FormValue("version"))
+}
+
+// renderConflict сообщает, что изменения не сохранены, потому что запись
+// изменили в другой вкладке, и предлагает загрузить её заново
+func renderConflict(w http.ResponseWriter, what, reloadURL string) {
+	tmpl, err := template.ParseFiles("templates/layout.html", "templates/conflict.html")
+	if err != nil {
+		http.Error(w, "Error loading templates", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusConflict)
+	tmpl.ExecuteTemplate(w, "layout.html", ConflictPageData{
+		Title:     "Edited elsewhere",
+		What:      what,
+		ReloadURL: reloadURL,
+	})
+}
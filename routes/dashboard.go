@@ -0,0 +1,334 @@
+package routes
+
+import (
+	"langhelperCopy/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Параметры панели прогресса на домашней странице
+const (
+	heatmapWeeks      = 53
+	weakestWordsShown = 10
+	weakestMinReviews = 3 // слова с меньшим числом ответов не считаются слабыми
+)
+
+const dayLayout = "2006-01-02"
+
+// LangAccuracy - точность ответов на одном языке
+type LangAccuracy struct {
+	LangID    uint
+	LangTitle string
+	Total     int
+	Correct   int
+}
+
+// Percent возвращает долю правильных ответов в процентах
+func (a LangAccuracy) Percent() int {
+	if a.Total == 0 {
+		return 0
+	}
+	return a.Correct * 100 / a.Total
+}
+
+// HeatmapDay - одна клетка календаря повторений
+type HeatmapDay struct {
+	Date    time.Time
+	Reviews int
+	Level   int  // 0-4, насыщенность цвета
+	Future  bool // день ещё не наступил
+}
+
+// DeckDue - сколько слов колоды пора повторить и сколько ещё не изучалось
+type DeckDue struct {
+	DeckID    uint
+	DeckTitle string
+	Smart     bool
+	Due       int
+	New       int
+}
+
+// WeakWord - слово с наибольшей долей ошибок
+type WeakWord struct {
+	WordID      uint
+	LangID      uint
+	LangTitle   string
+	Translation string
+	Successes   int
+	Failures    int
+	models.LangTag
+}
+
+// Percent возвращает долю правильных ответов по слову в процентах
+func (w WeakWord) Percent() int {
+	total := w.Successes + w.Failures
+	if total == 0 {
+		return 0
+	}
+	return w.Successes * 100 / total
+}
+
+// Dashboard - данные панели прогресса
+type Dashboard struct {
+	Goal          GoalProgress
+	StudiedToday  int
+	StudiedWeek   int
+	CurrentStreak int
+	LongestStreak int
+	Accuracy      []LangAccuracy
+	Heatmap       [][]HeatmapDay // недели, в каждой дни с понедельника
+	DueDecks      []DeckDue
+	WeakWords     []WeakWord
+}
+
+// loadDashboard собирает статистику пользователя из истории повторений.
+// Дни считаются в часовом поясе пользователя, серия - по дням с выполненной целью.
+func loadDashboard(db *gorm.DB, userID uint) (Dashboard, error) {
+	var dashboard Dashboard
+
+	history, err := loadStudyHistory(db, userID)
+	if err != nil {
+		return dashboard, err
+	}
+	today := history.Today
+
+	weekStart := today.AddDate(0, 0, -mondayOffset(today))
+	for day, activity := range history.Days {
+		date, err := time.Parse(dayLayout, day)
+		if err != nil {
+			continue
+		}
+		if date.Equal(today) {
+			dashboard.StudiedToday = activity.Reviews
+		}
+		if !date.Before(weekStart) && !date.After(today) {
+			dashboard.StudiedWeek += activity.Reviews
+		}
+	}
+	dashboard.Goal = history.goalProgress()
+	dashboard.CurrentStreak, dashboard.LongestStreak = reviewStreaks(history.Kept, today)
+	dashboard.Heatmap = buildHeatmap(history.Days, today)
+
+	if dashboard.Accuracy, err = loadLangAccuracy(db, userID); err != nil {
+		return dashboard, err
+	}
+	if dashboard.DueDecks, err = loadDueDecks(db, userID); err != nil {
+		return dashboard, err
+	}
+	if dashboard.WeakWords, err = loadWeakWords(db, userID); err != nil {
+		return dashboard, err
+	}
+	return dashboard, nil
+}
+
+// reviewStreaks считает текущую и самую длинную серию дней подряд из kept.
+// Текущая серия не прерывается, если сегодняшний день ещё не засчитан.
+func reviewStreaks(kept map[string]bool, today time.Time) (current, longest int) {
+	day := today
+	if !kept[day.Format(dayLayout)] {
+		day = day.AddDate(0, 0, -1)
+	}
+	for kept[day.Format(dayLayout)] {
+		current++
+		day = day.AddDate(0, 0, -1)
+	}
+
+	for key := range kept {
+		date, err := time.Parse(dayLayout, key)
+		if err != nil {
+			continue
+		}
+		// Серия считается только от её первого дня
+		if kept[date.AddDate(0, 0, -1).Format(dayLayout)] {
+			continue
+		}
+		length := 0
+		for kept[date.Format(dayLayout)] {
+			length++
+			date = date.AddDate(0, 0, 1)
+		}
+		if length > longest {
+			longest = length
+		}
+	}
+	return current, longest
+}
+
+// heatmapStart возвращает понедельник первой недели тепловой карты
+func heatmapStart(today time.Time) time.Time {
+	return today.AddDate(0, 0, -mondayOffset(today)-(heatmapWeeks-1)*7)
+}
+
+// buildHeatmap раскладывает последние heatmapWeeks недель по неделям и дням
+func buildHeatmap(days map[string]StudyDay, today time.Time) [][]HeatmapDay {
+	start := heatmapStart(today)
+	weeks := make([][]HeatmapDay, heatmapWeeks)
+	for w := range weeks {
+		weeks[w] = make([]HeatmapDay, 7)
+		for d := range weeks[w] {
+			date := start.AddDate(0, 0, w*7+d)
+			count := days[date.Format(dayLayout)].Reviews
+			weeks[w][d] = HeatmapDay{
+				Date:    date,
+				Reviews: count,
+				Level:   heatmapLevel(count),
+				Future:  date.After(today),
+			}
+		}
+	}
+	return weeks
+}
+
+func heatmapLevel(count int) int {
+	switch {
+	case count == 0:
+		return 0
+	case count < 10:
+		return 1
+	case count < 30:
+		return 2
+	case count < 60:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// mondayOffset возвращает число дней, прошедших с понедельника
+func mondayOffset(date time.Time) int {
+	return (int(date.Weekday()) + 6) % 7
+}
+
+// loadLangAccuracy считает точность ответов по каждому языку
+func loadLangAccuracy(db *gorm.DB, userID uint) ([]LangAccuracy, error) {
+	var accuracy []LangAccuracy
+	err := db.Raw(`
+		SELECT r.lang_id, ul.lang_title,
+			COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE r.correct) AS correct
+		FROM langhelpercopy.reviews r
+		JOIN langhelpercopy.user_langs ul ON ul.id = r.lang_id
+		JOIN langhelpercopy.words w ON w.id = r.word_id
+		WHERE r.user_id = ? AND ul.deleted_at IS NULL AND w.deleted_at IS NULL
+		GROUP BY r.lang_id, ul.lang_title
+		ORDER BY ul.lang_title
+	`, userID).Scan(&accuracy).Error
+	return accuracy, err
+}
+
+// loadDueDecks считает для каждой колоды слова, которые пора повторить
+// хотя бы на одном из её языков, и слова, которые ещё ни разу не проверялись.
+// Отключённые и отложенные слова не учитываются. Обычные колоды считаются
+// одним запросом, умные - по словам, подобранным правилом.
+func loadDueDecks(db *gorm.DB, userID uint) ([]DeckDue, error) {
+	var decks []models.Deck
+	err := db.Raw(`
+		SELECT *
+		FROM langhelpercopy.decks
+		WHERE user_id = ? AND deleted_at IS NULL
+		ORDER BY deck_title
+	`, userID).Scan(&decks).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		DeckID   uint
+		Words    int
+		Due      int
+		Reviewed int
+	}
+	err = db.Raw(`
+		SELECT dw.deck_id,
+			COUNT(DISTINCT dw.word_id) AS words,
+			COUNT(DISTINCT cp.word_id) FILTER (WHERE cp.due_at IS NULL OR cp.due_at <= NOW()) AS due,
+			COUNT(DISTINCT cp.word_id) AS reviewed
+		FROM langhelpercopy.decks d
+		JOIN langhelpercopy.deck_words dw ON dw.deck_id = d.id
+		JOIN langhelpercopy.words w ON w.id = dw.word_id
+		LEFT JOIN (
+			langhelpercopy.deck_langs dl
+			JOIN langhelpercopy.user_langs ul ON ul.id = dl.lang_id AND ul.deleted_at IS NULL
+		) ON dl.deck_id = d.id
+		LEFT JOIN langhelpercopy.card_progresses cp
+			ON cp.user_id = d.user_id AND cp.word_id = dw.word_id AND cp.lang_id = dl.lang_id
+		WHERE d.user_id = ? AND d.deleted_at IS NULL AND d.smart_rule = '' AND w.deleted_at IS NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM langhelpercopy.deck_word_states s
+				WHERE s.deck_id = d.id AND s.word_id = dw.word_id AND (s.suspended OR s.buried_until > NOW())
+			)
+		GROUP BY dw.deck_id
+	`, userID).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	manual := make(map[uint]DeckDue, len(rows))
+	for _, row := range rows {
+		manual[row.DeckID] = DeckDue{Due: row.Due, New: row.Words - row.Reviewed}
+	}
+
+	var result []DeckDue
+	for _, deck := range decks {
+		item := manual[deck.ID]
+		if deck.IsSmart() {
+			if item, err = loadSmartDeckDue(db, deck); err != nil {
+				return nil, err
+			}
+		}
+		item.DeckID, item.DeckTitle, item.Smart = deck.ID, deck.DeckTitle, deck.IsSmart()
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// loadSmartDeckDue считает слова к повторению и новые слова умной колоды
+func loadSmartDeckDue(db *gorm.DB, deck models.Deck) (DeckDue, error) {
+	var item DeckDue
+	wordIDs, err := studyDeckWordIDs(db, deck)
+	if err != nil || len(wordIDs) == 0 {
+		return item, err
+	}
+
+	var counts struct {
+		Due      int
+		Reviewed int
+	}
+	err = db.Raw(`
+		SELECT
+			COUNT(DISTINCT word_id) FILTER (WHERE due_at IS NULL OR due_at <= NOW()) AS due,
+			COUNT(DISTINCT word_id) AS reviewed
+		FROM langhelpercopy.card_progresses
+		WHERE user_id = ? AND word_id IN (?)
+			AND lang_id IN (
+				SELECT dl.lang_id FROM langhelpercopy.deck_langs dl
+				JOIN langhelpercopy.user_langs ul ON ul.id = dl.lang_id
+				WHERE dl.deck_id = ? AND ul.deleted_at IS NULL
+			)
+	`, deck.UserID, wordIDs, deck.ID).Scan(&counts).Error
+	if err != nil {
+		return item, err
+	}
+	item.Due = counts.Due
+	item.New = len(wordIDs) - counts.Reviewed
+	return item, nil
+}
+
+// loadWeakWords возвращает слова с наименьшей долей правильных ответов
+func loadWeakWords(db *gorm.DB, userID uint) ([]WeakWord, error) {
+	var words []WeakWord
+	err := db.Raw(`
+		SELECT cp.word_id, cp.lang_id, ul.lang_title, ul.lang_code,
+			COALESCE(uw.translation, '') AS translation,
+			cp.successes, cp.failures
+		FROM langhelpercopy.card_progresses cp
+		JOIN langhelpercopy.user_langs ul ON ul.id = cp.lang_id
+		JOIN langhelpercopy.words w ON w.id = cp.word_id
+		LEFT JOIN langhelpercopy.user_words uw
+			ON uw.word_id = cp.word_id AND uw.lang_id = cp.lang_id AND uw.is_primary
+		WHERE cp.user_id = ? AND ul.deleted_at IS NULL AND w.deleted_at IS NULL AND cp.failures > 0 AND cp.successes + cp.failures >= ?
+		ORDER BY cp.failures::float / (cp.successes + cp.failures) DESC, cp.failures DESC
+		LIMIT ?
+	`, userID, weakestMinReviews, weakestWordsShown).Scan(&words).Error
+	return words, err
+}
@@ -0,0 +1,466 @@
+package routes
+
+import (
+	"fmt"
+	"html/template"
+	"langhelperCopy/database"
+	"langhelperCopy/models"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// Если перевода на добавляемый язык нет у большей части слов колоды,
+// язык добавляется только после подтверждения
+const langCoverageWarnPercent = 50
+
+// Наборы слов для отчёта о переводах
+const (
+	coverageScopeDeck      = "deck"      // слова колоды
+	coverageScopeAvailable = "available" // слова не из колоды, которые нельзя добавить без переводов
+)
+
+// CoverageCell - основной перевод слова на один язык, пустой если его нет
+type CoverageCell struct {
+	LangID      uint
+	LangTitle   string
+	Translation string
+	models.LangTag
+}
+
+// Missing сообщает, что перевода нет
+func (c CoverageCell) Missing() bool {
+	return c.Translation == ""
+}
+
+// CoverageRow - переводы одного слова на языки колоды
+type CoverageRow struct {
+	WordID  uint
+	Cells   []CoverageCell
+	Missing int
+}
+
+// LangCoverage - сколько слов переведено на язык
+type LangCoverage struct {
+	Lang    models.UserLang
+	Covered int
+	Total   int
+}
+
+// Percent возвращает долю переведённых слов в процентах
+func (c LangCoverage) Percent() int {
+	if c.Total == 0 {
+		return 100
+	}
+	return c.Covered * 100 / c.Total
+}
+
+// Coverage - матрица переводов: слова по строкам, языки по колонкам
+type Coverage struct {
+	Langs    []models.UserLang
+	Rows     []CoverageRow
+	ByLang   []LangCoverage
+	Complete int // слова с переводами на все языки
+	Gaps     int // всего недостающих переводов
+}
+
+// loadCoverage строит матрицу переводов слов wordIDs на языки langs,
+// сохраняя порядок слов и языков
+func loadCoverage(db *gorm.DB, wordIDs []uint, langs []models.UserLang) (Coverage, error) {
+	coverage := Coverage{Langs: langs}
+	byLang := make([]LangCoverage, len(langs))
+	for i, lang := range langs {
+		byLang[i] = LangCoverage{Lang: lang, Total: len(wordIDs)}
+	}
+	coverage.ByLang = byLang
+	if len(wordIDs) == 0 || len(langs) == 0 {
+		return coverage, nil
+	}
+
+	langIDs := make([]uint, len(langs))
+	for i, lang := range langs {
+		langIDs[i] = lang.ID
+	}
+	var translations []struct {
+		WordID      uint
+		LangID      uint
+		Translation string
+	}
+	err := db.Raw(`
+		SELECT word_id, lang_id, translation
+		FROM langhelpercopy.user_words
+		WHERE word_id IN (?) AND lang_id IN (?) AND is_primary AND translation <> ''
+	`, wordIDs, langIDs).Scan(&translations).Error
+	if err != nil {
+		return coverage, err
+	}
+	known := make(map[uint]map[uint]string, len(wordIDs))
+	for _, t := range translations {
+		if known[t.WordID] == nil {
+			known[t.WordID] = make(map[uint]string)
+		}
+		known[t.WordID][t.LangID] = t.Translation
+	}
+
+	for _, wordID := range wordIDs {
+		row := CoverageRow{WordID: wordID, Cells: make([]CoverageCell, len(langs))}
+		for i, lang := range langs {
+			cell := CoverageCell{
+				LangID:      lang.ID,
+				LangTitle:   lang.LangTitle,
+				Translation: known[wordID][lang.ID],
+				LangTag:     models.LangTag{LangCode: lang.LangCode},
+			}
+			if cell.Missing() {
+				row.Missing++
+			} else {
+				coverage.ByLang[i].Covered++
+			}
+			row.Cells[i] = cell
+		}
+		if row.Missing == 0 {
+			coverage.Complete++
+		}
+		coverage.Gaps += row.Missing
+		coverage.Rows = append(coverage.Rows, row)
+	}
+	return coverage, nil
+}
+
+// coverageWordIDs возвращает слова отчёта: слова колоды или слова пользователя
+// не из колоды, у которых есть не все переводы на её языки
+func coverageWordIDs(db *gorm.DB, userID uint, deck models.Deck, scope string, langs []models.UserLang) ([]uint, error) {
+	if scope != coverageScopeAvailable {
+		return deckWordIDs(db, deck)
+	}
+	if deck.IsSmart() || len(langs) == 0 {
+		return nil, nil
+	}
+
+	langIDs := make([]uint, len(langs))
+	for i, lang := range langs {
+		langIDs[i] = lang.ID
+	}
+	var wordIDs []uint
+	err := db.Raw(`
+		SELECT uw.word_id
+		FROM langhelpercopy.user_words uw
+		JOIN langhelpercopy.user_langs ul ON uw.lang_id = ul.id
+		JOIN langhelpercopy.words w ON uw.word_id = w.id
+		WHERE ul.user_id = ? AND ul.deleted_at IS NULL AND w.deleted_at IS NULL
+			AND uw.word_id NOT IN (SELECT word_id FROM langhelpercopy.deck_words WHERE deck_id = ?)
+		GROUP BY uw.word_id
+		HAVING COUNT(DISTINCT uw.lang_id) FILTER (WHERE uw.lang_id IN (?) AND uw.is_primary AND uw.translation <> '') < ?
+		ORDER BY uw.word_id
+	`, userID, deck.ID, langIDs, len(langIDs)).Scan(&wordIDs).Error
+	return wordIDs, err
+}
+
+// loadLangCoverage считает, сколько слов колоды переведено на каждый из языков
+func loadLangCoverage(db *gorm.DB, wordIDs []uint, langIDs []uint) (map[uint]int, error) {
+	covered := make(map[uint]int, len(langIDs))
+	if len(wordIDs) == 0 || len(langIDs) == 0 {
+		return covered, nil
+	}
+	var counts []struct {
+		LangID uint
+		Words  int
+	}
+	err := db.Raw(`
+		SELECT lang_id, COUNT(DISTINCT word_id) AS words
+		FROM langhelpercopy.user_words
+		WHERE word_id IN (?) AND lang_id IN (?) AND is_primary AND translation <> ''
+		GROUP BY lang_id
+	`, wordIDs, langIDs).Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range counts {
+		covered[c.LangID] = c.Words
+	}
+	return covered, nil
+}
+
+// DeckCoverageHandler показывает, каких переводов не хватает словам колоды
+// или словам, которые нельзя добавить в колоду
+func DeckCoverageHandler(w http.ResponseWriter, r *http.Request) {
+	deck, userID, ok := deckRequest(w, r)
+	if !ok {
+		return
+	}
+
+	scope := r.URL.Query().Get("scope")
+	if scope != coverageScopeAvailable {
+		scope = coverageScopeDeck
+	}
+	onlyMissing := r.URL.Query().Get("missing") == "1"
+
+	db := database.GetDB()
+
+	langs, err := loadDeckLangs(db, deck.ID)
+	if err != nil {
+		log.Printf("Failed to load deck languages: %v", err)
+		http.Error(w, "Failed to load deck languages", http.StatusInternalServerError)
+		return
+	}
+	wordIDs, err := coverageWordIDs(db, userID, deck, scope, langs)
+	if err != nil {
+		log.Printf("Failed to load deck words: %v", err)
+		http.Error(w, "Failed to load deck words", http.StatusInternalServerError)
+		return
+	}
+	coverage, err := loadCoverage(db, wordIDs, langs)
+	if err != nil {
+		log.Printf("Failed to load translations: %v", err)
+		http.Error(w, "Failed to load translations", http.StatusInternalServerError)
+		return
+	}
+
+	rows := coverage.Rows
+	if onlyMissing {
+		rows = nil
+		for _, row := range coverage.Rows {
+			if row.Missing > 0 {
+				rows = append(rows, row)
+			}
+		}
+	}
+
+	data := struct {
+		Title       string
+		Deck        models.Deck
+		Scope       string
+		OnlyMissing bool
+		Coverage    Coverage
+		Rows        []CoverageRow
+	}{
+		Title:       "Translation Coverage",
+		Deck:        deck,
+		Scope:       scope,
+		OnlyMissing: onlyMissing,
+		Coverage:    coverage,
+		Rows:        rows,
+	}
+
+	tmpl, err := template.ParseFiles("templates/layout.html", "templates/deckCoverage.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+	}
+}
+
+// DeckFillHandler по очереди показывает слова, которым не хватает переводов
+// на языки колоды, и сохраняет введённые переводы. Параметр after - ID
+// последнего показанного слова, чтобы пропущенные слова не повторялись по кругу.
+func DeckFillHandler(w http.ResponseWriter, r *http.Request) {
+	deck, userID, ok := deckRequest(w, r)
+	if !ok {
+		return
+	}
+
+	r.ParseForm()
+	scope := r.FormValue("scope")
+	if scope != coverageScopeAvailable {
+		scope = coverageScopeDeck
+	}
+	after, _ := strconv.ParseUint(r.FormValue("after"), 10, 64)
+
+	db := database.GetDB()
+
+	langs, err := loadDeckLangs(db, deck.ID)
+	if err != nil {
+		log.Printf("Failed to load deck languages: %v", err)
+		http.Error(w, "Failed to load deck languages", http.StatusInternalServerError)
+		return
+	}
+	wordIDs, err := coverageWordIDs(db, userID, deck, scope, langs)
+	if err != nil {
+		log.Printf("Failed to load deck words: %v", err)
+		http.Error(w, "Failed to load deck words", http.StatusInternalServerError)
+		return
+	}
+
+	formError := ""
+	var retry uint // слово, которое нужно показать снова из-за ошибки в форме
+	if r.Method == http.MethodPost {
+		wordID, err := strconv.ParseUint(r.FormValue("word_id"), 10, 64)
+		if err != nil || !containsID(wordIDs, uint(wordID)) {
+			http.Error(w, "Invalid word ID", http.StatusBadRequest)
+			return
+		}
+		coverage, err := loadCoverage(db, []uint{uint(wordID)}, langs)
+		if err != nil {
+			log.Printf("Failed to load translations: %v", err)
+			http.Error(w, "Failed to load translations", http.StatusInternalServerError)
+			return
+		}
+		formError, err = fillMissingTranslations(db, userID, r, coverage.Rows[0])
+		if err != nil {
+			log.Printf("Failed to save translations: %v", err)
+			http.Error(w, "Failed to save translations", http.StatusInternalServerError)
+			return
+		}
+		if formError == "" {
+			params := url.Values{"scope": {scope}, "after": {strconv.FormatUint(wordID, 10)}}
+			http.Redirect(w, r, fmt.Sprintf("/deck/%d/fill?%s", deck.ID, params.Encode()), http.StatusSeeOther)
+			return
+		}
+		retry = uint(wordID)
+	}
+
+	coverage, err := loadCoverage(db, wordIDs, langs)
+	if err != nil {
+		log.Printf("Failed to load translations: %v", err)
+		http.Error(w, "Failed to load translations", http.StatusInternalServerError)
+		return
+	}
+
+	// Следующее слово с пропусками после слова after в порядке колоды.
+	// Слово after могло выпасть из списка слов не из колоды, когда у него
+	// появились все переводы; этот список упорядочен по ID.
+	start := -1
+	for i, row := range coverage.Rows {
+		if row.WordID == retry {
+			start = i
+			break
+		}
+		if uint64(row.WordID) == after {
+			start = i + 1
+		}
+	}
+	if start < 0 {
+		start = 0
+		if after != 0 && scope == coverageScopeAvailable {
+			for start < len(coverage.Rows) && uint64(coverage.Rows[start].WordID) < after {
+				start++
+			}
+		}
+	}
+	var current *CoverageRow
+	var position, remaining int
+	for i := range coverage.Rows {
+		if coverage.Rows[i].Missing == 0 {
+			continue
+		}
+		remaining++
+		if current == nil && i >= start {
+			current = &coverage.Rows[i]
+			position = remaining
+		}
+	}
+
+	var details *WordDetails
+	if current != nil {
+		langIDs := make([]uint, len(langs))
+		for i, lang := range langs {
+			langIDs[i] = lang.ID
+		}
+		all, err := loadWordDetails(db, []uint{current.WordID}, langIDs)
+		if err != nil {
+			log.Printf("Failed to load word details: %v", err)
+			http.Error(w, "Failed to load word details", http.StatusInternalServerError)
+			return
+		}
+		details = all[current.WordID]
+	}
+
+	data := struct {
+		Title     string
+		Deck      models.Deck
+		Scope     string
+		Word      *CoverageRow
+		Details   *WordDetails
+		Position  int
+		Remaining int
+		FormError string
+	}{
+		Title:     "Fill Missing Translations",
+		Deck:      deck,
+		Scope:     scope,
+		Word:      current,
+		Details:   details,
+		Position:  position,
+		Remaining: remaining,
+		FormError: formError,
+	}
+
+	tmpl, err := template.ParseFiles("templates/layout.html", "templates/deckFill.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+	}
+}
+
+// fillMissingTranslations сохраняет переводы из формы для тех языков, на
+// которые у слова ещё нет перевода. Возвращает текст ошибки для формы.
+func fillMissingTranslations(db *gorm.DB, userID uint, r *http.Request, row CoverageRow) (string, error) {
+	type input struct {
+		langID      uint
+		translation string
+	}
+	var inputs []input
+	for _, cell := range row.Cells {
+		if !cell.Missing() {
+			continue
+		}
+		val := strings.TrimSpace(r.FormValue(fmt.Sprintf("translation_%d", cell.LangID)))
+		if val == "" {
+			continue
+		}
+		if utf8.RuneCountInString(val) > maxTranslationLength {
+			return fmt.Sprintf("Translation %q is longer than %d characters", val, maxTranslationLength), nil
+		}
+		inputs = append(inputs, input{cell.LangID, val})
+	}
+
+	err := database.UnitOfWork(db, func(tx *gorm.DB) error {
+		for _, in := range inputs {
+			// Перевод мог появиться, пока форма была открыта: тогда он не перезаписывается
+			result := tx.Exec(`
+				UPDATE langhelpercopy.user_words SET translation = ?, updated_at = NOW(), version = version + 1
+				WHERE word_id = ? AND lang_id = ? AND is_primary AND translation = ''
+			`, in.translation, row.WordID, in.langID)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				result = tx.Exec(`
+					INSERT INTO langhelpercopy.user_words (lang_id, word_id, translation, is_primary)
+					SELECT ?, ?, ?, TRUE
+					WHERE NOT EXISTS (
+						SELECT 1 FROM langhelpercopy.user_words
+						WHERE word_id = ? AND lang_id = ? AND is_primary
+					)
+				`, in.langID, row.WordID, in.translation, row.WordID, in.langID)
+				if result.Error != nil {
+					return result.Error
+				}
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+			if err := recordTranslationChange(tx, userID, row.WordID, in.langID, "", in.translation); err != nil {
+				return err
+			}
+		}
+		if len(inputs) == 0 {
+			return nil
+		}
+		_, err := bumpWordVersions(tx, []uint{row.WordID})
+		return err
+	})
+	return "", err
+}
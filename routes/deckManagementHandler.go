@@ -0,0 +1,300 @@
+package routes
+
+import (
+	"errors"
+	"langhelperCopy/config"
+	"langhelperCopy/database"
+	"langhelperCopy/models"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+var errDeckNotFound = errors.New("deck not found")
+
+// loadUserDeck загружает колоду, только если она принадлежит пользователю
+func loadUserDeck(db *gorm.DB, deckID, userID uint) (models.Deck, error) {
+	var deck models.Deck
+	err := db.Raw("SELECT * FROM langhelpercopy.decks WHERE id = ? AND user_id = ? AND deleted_at IS NULL", deckID, userID).Scan(&deck).Error
+	if err != nil {
+		return deck, err
+	}
+	if deck.ID == 0 {
+		return deck, errDeckNotFound
+	}
+	return deck, nil
+}
+
+// deckRequest разбирает сессию и ID колоды из URL для обработчиков управления колодами.
+// При ошибке ответ уже отправлен и ok == false.
+func deckRequest(w http.ResponseWriter, r *http.Request) (deck models.Deck, userID uint, ok bool) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return deck, 0, false
+	}
+
+	userID, ok = session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return deck, 0, false
+	}
+
+	deckID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return deck, 0, false
+	}
+
+	deck, err = loadUserDeck(database.GetDB(), uint(deckID), userID)
+	if err != nil {
+		http.Error(w, "Deck not found or access denied", http.StatusNotFound)
+		return deck, 0, false
+	}
+	return deck, userID, true
+}
+
+// RenameDeckHandler меняет название колоды
+func RenameDeckHandler(w http.ResponseWriter, r *http.Request) {
+	deck, userID, ok := deckRequest(w, r)
+	if !ok {
+		return
+	}
+
+	title := strings.TrimSpace(r.FormValue("deck_title"))
+	if err := models.ValidateDeckTitle(title); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	version, err := formVersion(r)
+	if err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	err = database.UnitOfWork(db, func(tx *gorm.DB) error {
+		result := tx.Exec(`
+			UPDATE langhelpercopy.decks SET deck_title = ?, updated_at = NOW(), version = version + 1
+			WHERE id = ? AND version = ?
+		`, title, deck.ID, version)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errStaleVersion
+		}
+		return recordFieldChange(tx, userID, models.HistoryDeck, deck.ID, historyFieldTitle, deck.DeckTitle, title)
+	})
+	if errors.Is(err, errStaleVersion) {
+		renderConflict(w, "This deck", "/mydecks")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to rename deck: %v", err)
+		http.Error(w, "Failed to rename deck", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/mydecks", http.StatusSeeOther)
+}
+
+// DeleteDeckHandler переносит колоду в корзину; языки и список слов колоды
+// сохраняются до окончательного удаления, сами слова пользователя остаются.
+func DeleteDeckHandler(w http.ResponseWriter, r *http.Request) {
+	deck, userID, ok := deckRequest(w, r)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	err := database.UnitOfWork(db, func(tx *gorm.DB) error {
+		return deleteDeck(tx, userID, deck)
+	})
+	if err != nil {
+		log.Printf("Failed to delete deck: %v", err)
+		http.Error(w, "Failed to delete deck", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/mydecks", http.StatusSeeOther)
+}
+
+// DuplicateDeckHandler создаёт копию колоды с её языками, словами и правилом
+func DuplicateDeckHandler(w http.ResponseWriter, r *http.Request) {
+	deck, userID, ok := deckRequest(w, r)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	err := database.UnitOfWork(db, func(tx *gorm.DB) error {
+		title := truncateRunes("Copy of "+deck.DeckTitle, 50)
+		var newID uint
+		err := tx.Raw(
+			"INSERT INTO langhelpercopy.decks (user_id, deck_title, smart_rule, prompt_lang_id) VALUES (?, ?, ?, ?) RETURNING id",
+			userID, title, deck.SmartRule, deck.PromptLangID,
+		).Scan(&newID).Error
+		if err != nil {
+			return err
+		}
+		if err := recordEntityChange(tx, userID, models.HistoryDeck, newID, models.HistoryCreate, title); err != nil {
+			return err
+		}
+
+		err = tx.Exec(`
+			INSERT INTO langhelpercopy.deck_langs (deck_id, lang_id, position)
+			SELECT ?, lang_id, position FROM langhelpercopy.deck_langs WHERE deck_id = ? ORDER BY id
+		`, newID, deck.ID).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Exec(`
+			INSERT INTO langhelpercopy.deck_words (deck_id, word_id)
+			SELECT ?, word_id FROM langhelpercopy.deck_words WHERE deck_id = ? ORDER BY id
+		`, newID, deck.ID).Error
+	})
+	if err != nil {
+		log.Printf("Failed to duplicate deck: %v", err)
+		http.Error(w, "Failed to duplicate deck", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/mydecks", http.StatusSeeOther)
+}
+
+// MergeDecksHandler объединяет несколько колод в новую обычную колоду.
+// Языки и слова объединяются без повторов; слова умных колод берутся
+// по их текущему правилу. Исходные колоды можно удалить.
+func MergeDecksHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	title := strings.TrimSpace(r.FormValue("deck_title"))
+	if err := models.ValidateDeckTitle(title); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	deleteSources := r.FormValue("delete_sources") == "on"
+
+	db := database.GetDB()
+
+	var sources []models.Deck
+	seen := make(map[uint]bool)
+	for _, raw := range r.Form["deck_ids"] {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil || seen[uint(id)] {
+			continue
+		}
+		seen[uint(id)] = true
+
+		deck, err := loadUserDeck(db, uint(id), userID)
+		if err != nil {
+			http.Error(w, "Deck not found or access denied", http.StatusNotFound)
+			return
+		}
+		sources = append(sources, deck)
+	}
+	if len(sources) < 2 {
+		http.Error(w, "Select at least two decks to merge", http.StatusBadRequest)
+		return
+	}
+
+	err = database.UnitOfWork(db, func(tx *gorm.DB) error {
+		var newID uint
+		err := tx.Raw(
+			"INSERT INTO langhelpercopy.decks (user_id, deck_title) VALUES (?, ?) RETURNING id",
+			userID, title,
+		).Scan(&newID).Error
+		if err != nil {
+			return err
+		}
+		if err := recordEntityChange(tx, userID, models.HistoryDeck, newID, models.HistoryCreate, title); err != nil {
+			return err
+		}
+
+		sourceIDs := make([]uint, len(sources))
+		for i, d := range sources {
+			sourceIDs[i] = d.ID
+		}
+
+		err = tx.Exec(`
+			INSERT INTO langhelpercopy.deck_langs (deck_id, lang_id, position)
+			SELECT ?, lang_id, MIN(position) FROM langhelpercopy.deck_langs
+			WHERE deck_id IN (?)
+			GROUP BY lang_id
+			ORDER BY MIN(id)
+		`, newID, sourceIDs).Error
+		if err != nil {
+			return err
+		}
+
+		added := make(map[uint]bool)
+		for _, d := range sources {
+			wordIDs, err := deckWordIDs(tx, d)
+			if err != nil {
+				return err
+			}
+			for _, wid := range wordIDs {
+				if added[wid] {
+					continue
+				}
+				added[wid] = true
+				if err := tx.Exec("INSERT INTO langhelpercopy.deck_words (deck_id, word_id) VALUES (?, ?)", newID, wid).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		if deleteSources {
+			for _, d := range sources {
+				if err := deleteDeck(tx, userID, d); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to merge decks: %v", err)
+		http.Error(w, "Failed to merge decks", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/mydecks", http.StatusSeeOther)
+}
+
+// deleteDeck переносит колоду в корзину; окончательно её удаляет очистка корзины
+func deleteDeck(tx *gorm.DB, userID uint, deck models.Deck) error {
+	if err := tx.Exec("UPDATE langhelpercopy.decks SET deleted_at = NOW() WHERE id = ?", deck.ID).Error; err != nil {
+		return err
+	}
+	return recordEntityChange(tx, userID, models.HistoryDeck, deck.ID, models.HistoryDelete, deck.DeckTitle)
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
@@ -0,0 +1,309 @@
+package routes
+
+import (
+	"encoding/json"
+	"html/template"
+	"langhelperCopy/config"
+	"langhelperCopy/database"
+	"langhelperCopy/models"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// Сколько дней показывают графики статистики колоды
+const (
+	deckStatsHistoryDays  = 30
+	deckStatsForecastDays = 30
+)
+
+// WordLangStat - статистика ответов по слову на одном языке колоды
+type WordLangStat struct {
+	WordID         uint
+	LangID         uint
+	LangTitle      string
+	Translation    string
+	Successes      int
+	Failures       int
+	LastReviewedAt *time.Time
+	IntervalDays   int
+	DueAt          *time.Time
+	models.LangTag
+}
+
+// Attempts возвращает число ответов
+func (s WordLangStat) Attempts() int {
+	return s.Successes + s.Failures
+}
+
+// Accuracy возвращает долю правильных ответов в процентах, -1 если ответов не было
+func (s WordLangStat) Accuracy() int {
+	if s.Attempts() == 0 {
+		return -1
+	}
+	return s.Successes * 100 / s.Attempts()
+}
+
+// DeckStatsDay - ответы по колоде за один день
+type DeckStatsDay struct {
+	Date    string `json:"date"`
+	Reviews int    `json:"reviews"`
+	Correct int    `json:"correct"`
+}
+
+// DeckForecastDay - сколько слов колоды станет пора повторить в этот день
+type DeckForecastDay struct {
+	Date string `json:"date"`
+	Due  int    `json:"due"`
+}
+
+// DeckCharts - данные графиков страницы статистики
+type DeckCharts struct {
+	History  []DeckStatsDay    `json:"history"`
+	Forecast []DeckForecastDay `json:"forecast"`
+}
+
+// deckStatsSorts - допустимые колонки сортировки таблицы
+var deckStatsSorts = map[string]func(a, b WordLangStat) bool{
+	"word":     func(a, b WordLangStat) bool { return strings.ToLower(a.Translation) < strings.ToLower(b.Translation) },
+	"lang":     func(a, b WordLangStat) bool { return a.LangTitle < b.LangTitle },
+	"attempts": func(a, b WordLangStat) bool { return a.Attempts() < b.Attempts() },
+	"accuracy": func(a, b WordLangStat) bool { return a.Accuracy() < b.Accuracy() },
+	"last":     func(a, b WordLangStat) bool { return timeBefore(a.LastReviewedAt, b.LastReviewedAt) },
+	"interval": func(a, b WordLangStat) bool { return a.IntervalDays < b.IntervalDays },
+}
+
+// timeBefore сравнивает необязательные даты, отсутствующая дата считается самой ранней
+func timeBefore(a, b *time.Time) bool {
+	if a == nil {
+		return b != nil
+	}
+	return b != nil && a.Before(*b)
+}
+
+// DeckStatsHandler показывает статистику колоды по словам и языкам.
+// С параметром format=json отдаёт данные для графиков.
+func DeckStatsHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
+	deckID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+
+	var deck models.Deck
+	err = db.Raw("SELECT * FROM langhelpercopy.decks WHERE id = ? AND user_id = ? AND deleted_at IS NULL", deckID, userID).Scan(&deck).Error
+	if err != nil || deck.ID == 0 {
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+
+	timezone, err := userTimezone(db, userID)
+	if err != nil {
+		log.Printf("Failed to load time zone: %v", err)
+		http.Error(w, "Failed to load statistics", http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		charts, err := loadDeckCharts(db, userID, deck, timezone)
+		if err != nil {
+			log.Printf("Failed to load deck charts: %v", err)
+			http.Error(w, "Failed to load statistics", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(charts); err != nil {
+			log.Printf("JSON encode error: %v", err)
+		}
+		return
+	}
+
+	stats, err := loadWordLangStats(db, userID, deck)
+	if err != nil {
+		log.Printf("Failed to load deck statistics: %v", err)
+		http.Error(w, "Failed to load statistics", http.StatusInternalServerError)
+		return
+	}
+
+	sortBy := r.URL.Query().Get("sort")
+	less, ok := deckStatsSorts[sortBy]
+	if !ok {
+		sortBy, less = "word", deckStatsSorts["word"]
+	}
+	desc := r.URL.Query().Get("order") == "desc"
+	sort.SliceStable(stats, func(i, j int) bool {
+		if desc {
+			return less(stats[j], stats[i])
+		}
+		return less(stats[i], stats[j])
+	})
+
+	var reviewed, attempts, successes int
+	for _, s := range stats {
+		if s.Attempts() > 0 {
+			reviewed++
+		}
+		attempts += s.Attempts()
+		successes += s.Successes
+	}
+	accuracy := -1
+	if attempts > 0 {
+		accuracy = successes * 100 / attempts
+	}
+
+	data := struct {
+		Title    string
+		Deck     models.Deck
+		Stats    []WordLangStat
+		Sort     string
+		Desc     bool
+		Reviewed int
+		Attempts int
+		Accuracy int
+		Timezone string
+		Location *time.Location
+	}{
+		Title:    "Deck Statistics",
+		Deck:     deck,
+		Stats:    stats,
+		Sort:     sortBy,
+		Desc:     desc,
+		Reviewed: reviewed,
+		Attempts: attempts,
+		Accuracy: accuracy,
+		Timezone: timezone,
+	}
+	if data.Location, err = time.LoadLocation(timezone); err != nil {
+		data.Location = time.UTC
+	}
+
+	tmpl, err := template.ParseFiles("templates/layout.html", "templates/deckStats.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+	}
+}
+
+// loadWordLangStats возвращает статистику по каждому слову колоды на каждом
+// её языке, включая ещё не проверявшиеся пары.
+func loadWordLangStats(db *gorm.DB, userID uint, deck models.Deck) ([]WordLangStat, error) {
+	wordIDs, err := deckWordIDs(db, deck)
+	if err != nil || len(wordIDs) == 0 {
+		return nil, err
+	}
+
+	var stats []WordLangStat
+	err = db.Raw(`
+		SELECT uw.word_id, uw.lang_id, ul.lang_title, ul.lang_code, uw.translation,
+			COALESCE(cp.successes, 0) AS successes,
+			COALESCE(cp.failures, 0) AS failures,
+			cp.last_reviewed_at,
+			COALESCE(cp.interval_days, 0) AS interval_days,
+			cp.due_at
+		FROM langhelpercopy.user_words uw
+		JOIN langhelpercopy.deck_langs dl ON dl.lang_id = uw.lang_id AND dl.deck_id = ?
+		JOIN langhelpercopy.user_langs ul ON ul.id = uw.lang_id
+		LEFT JOIN langhelpercopy.card_progresses cp
+			ON cp.user_id = ? AND cp.word_id = uw.word_id AND cp.lang_id = uw.lang_id
+		WHERE uw.word_id IN (?) AND uw.is_primary AND ul.deleted_at IS NULL
+	`, deck.ID, userID, wordIDs).Scan(&stats).Error
+	return stats, err
+}
+
+// loadDeckCharts собирает ответы по колоде за последние дни и прогноз
+// повторений на ближайшие дни в часовом поясе пользователя.
+func loadDeckCharts(db *gorm.DB, userID uint, deck models.Deck, timezone string) (DeckCharts, error) {
+	var charts DeckCharts
+
+	var todayStr string
+	err := db.Raw("SELECT TO_CHAR(NOW() AT TIME ZONE ?, 'YYYY-MM-DD')", timezone).Scan(&todayStr).Error
+	if err != nil {
+		return charts, err
+	}
+	today, err := time.Parse(dayLayout, todayStr)
+	if err != nil {
+		return charts, err
+	}
+
+	var history []DeckStatsDay
+	err = db.Raw(`
+		SELECT TO_CHAR(DATE(created_at AT TIME ZONE ?), 'YYYY-MM-DD') AS date,
+			COUNT(*) AS reviews,
+			COUNT(*) FILTER (WHERE correct) AS correct
+		FROM langhelpercopy.reviews
+		WHERE user_id = ? AND deck_id = ? AND created_at >= NOW() - make_interval(days => ?)
+		GROUP BY 1
+	`, timezone, userID, deck.ID, deckStatsHistoryDays+1).Scan(&history).Error
+	if err != nil {
+		return charts, err
+	}
+	byDay := make(map[string]DeckStatsDay, len(history))
+	for _, day := range history {
+		byDay[day.Date] = day
+	}
+	for i := deckStatsHistoryDays - 1; i >= 0; i-- {
+		date := today.AddDate(0, 0, -i).Format(dayLayout)
+		day := byDay[date]
+		day.Date = date
+		charts.History = append(charts.History, day)
+	}
+
+	dueByDay := make(map[string]int)
+	wordIDs, err := studyDeckWordIDs(db, deck)
+	if err != nil {
+		return charts, err
+	}
+	if len(wordIDs) > 0 {
+		var forecast []DeckForecastDay
+		// Просроченные повторения попадают на сегодня
+		err = db.Raw(`
+			SELECT TO_CHAR(GREATEST(DATE(COALESCE(due_at, NOW()) AT TIME ZONE ?), ?::date), 'YYYY-MM-DD') AS date,
+				COUNT(*) AS due
+			FROM langhelpercopy.card_progresses
+			WHERE user_id = ? AND word_id IN (?)
+				AND lang_id IN (
+					SELECT dl.lang_id FROM langhelpercopy.deck_langs dl
+					JOIN langhelpercopy.user_langs ul ON ul.id = dl.lang_id
+					WHERE dl.deck_id = ? AND ul.deleted_at IS NULL
+				)
+				AND COALESCE(due_at, NOW()) < NOW() + make_interval(days => ?)
+			GROUP BY 1
+		`, timezone, todayStr, userID, wordIDs, deck.ID, deckStatsForecastDays).Scan(&forecast).Error
+		if err != nil {
+			return charts, err
+		}
+		for _, day := range forecast {
+			dueByDay[day.Date] = day.Due
+		}
+	}
+	for i := 0; i < deckStatsForecastDays; i++ {
+		date := today.AddDate(0, 0, i).Format(dayLayout)
+		charts.Forecast = append(charts.Forecast, DeckForecastDay{Date: date, Due: dueByDay[date]})
+	}
+	return charts, nil
+}
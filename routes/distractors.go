@@ -0,0 +1,193 @@
+package routes
+
+import (
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+)
+
+// Веса признаков при выборе неправильных вариантов
+const (
+	distractorConfusionWeight = 4.0 // раньше путали с этим словом
+	distractorSameDeckWeight  = 3.0 // слово из той же колоды
+	distractorSamePOSWeight   = 2.0 // та же часть речи
+	distractorLengthWeight    = 2.0 // похожая длина
+	distractorJitter          = 1.0 // случайность, чтобы варианты менялись
+)
+
+type distractorCandidate struct {
+	WordID       uint
+	Translation  string
+	PartOfSpeech string
+}
+
+// distractorEngine подбирает неправильные варианты ответов для одной тренировки.
+// Кандидаты и история ошибок по каждому языку загружаются один раз.
+type distractorEngine struct {
+	db       *gorm.DB
+	userID   uint
+	deckSet  map[uint]bool
+	pools    map[uint][]distractorCandidate
+	wordPOS  map[uint]string
+	confused map[uint]map[uint]map[string]int // язык -> слово -> ключ ответа -> сколько раз выбран
+}
+
+func newDistractorEngine(db *gorm.DB, userID uint, deckWordIDs []uint) *distractorEngine {
+	deckSet := make(map[uint]bool, len(deckWordIDs))
+	for _, id := range deckWordIDs {
+		deckSet[id] = true
+	}
+	return &distractorEngine{
+		db:       db,
+		userID:   userID,
+		deckSet:  deckSet,
+		pools:    make(map[uint][]distractorCandidate),
+		wordPOS:  make(map[uint]string),
+		confused: make(map[uint]map[uint]map[string]int),
+	}
+}
+
+func (e *distractorEngine) load(langID uint) error {
+	if _, ok := e.pools[langID]; ok {
+		return nil
+	}
+
+	var pool []distractorCandidate
+	err := e.db.Raw(`
+		SELECT uw.word_id, uw.translation, w.part_of_speech
+		FROM langhelpercopy.user_words uw
+		JOIN langhelpercopy.user_langs ul ON uw.lang_id = ul.id
+		JOIN langhelpercopy.words w ON uw.word_id = w.id
+		WHERE ul.user_id = ? AND uw.lang_id = ? AND uw.is_primary
+			AND ul.deleted_at IS NULL AND w.deleted_at IS NULL
+	`, e.userID, langID).Scan(&pool).Error
+	if err != nil {
+		return err
+	}
+	for _, c := range pool {
+		e.wordPOS[c.WordID] = c.PartOfSpeech
+	}
+
+	var mistakes []struct {
+		WordID uint
+		Answer string
+		Times  int
+	}
+	err = e.db.Raw(`
+		SELECT word_id, answer, COUNT(*) AS times
+		FROM langhelpercopy.reviews
+		WHERE user_id = ? AND lang_id = ? AND NOT correct AND answer <> ''
+		GROUP BY word_id, answer
+	`, e.userID, langID).Scan(&mistakes).Error
+	if err != nil {
+		return err
+	}
+	confused := make(map[uint]map[string]int)
+	for _, m := range mistakes {
+		if confused[m.WordID] == nil {
+			confused[m.WordID] = make(map[string]int)
+		}
+		confused[m.WordID][foldKey(m.Answer)] += m.Times
+	}
+
+	e.pools[langID] = pool
+	e.confused[langID] = confused
+	return nil
+}
+
+// pick возвращает до n неправильных вариантов для слова на языке langID.
+// Варианты не совпадают ни с одним допустимым ответом и друг с другом
+// с точностью до регистра и диакритики. Если слов в языке мало,
+// вариантов будет меньше n.
+func (e *distractorEngine) pick(wordID, langID uint, accepted []string, n int) ([]string, error) {
+	if err := e.load(langID); err != nil {
+		return nil, err
+	}
+
+	excluded := make(map[string]bool, len(accepted))
+	for _, a := range accepted {
+		excluded[foldKey(a)] = true
+	}
+
+	correctLen := 0
+	if len(accepted) > 0 {
+		correctLen = utf8.RuneCountInString(accepted[0])
+	}
+	pos := e.wordPOS[wordID]
+	confusions := e.confused[langID][wordID]
+
+	type scored struct {
+		text  string
+		key   string
+		score float64
+	}
+	var candidates []scored
+	for _, c := range e.pools[langID] {
+		if c.WordID == wordID {
+			continue
+		}
+		key := foldKey(c.Translation)
+		if key == "" || excluded[key] {
+			continue
+		}
+
+		score := rand.Float64() * distractorJitter
+		if times := confusions[key]; times > 0 {
+			score += distractorConfusionWeight + math.Log1p(float64(times))
+		}
+		if e.deckSet[c.WordID] {
+			score += distractorSameDeckWeight
+		}
+		if pos != "" && c.PartOfSpeech == pos {
+			score += distractorSamePOSWeight
+		}
+		score += distractorLengthWeight * lengthSimilarity(correctLen, utf8.RuneCountInString(c.Translation))
+
+		candidates = append(candidates, scored{text: c.Translation, key: key, score: score})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	result := make([]string, 0, n)
+	for _, c := range candidates {
+		if len(result) == n {
+			break
+		}
+		if excluded[c.key] {
+			continue
+		}
+		excluded[c.key] = true
+		result = append(result, c.text)
+	}
+	return result, nil
+}
+
+// lengthSimilarity возвращает 1 для одинаковой длины и стремится к 0 при большой разнице
+func lengthSimilarity(a, b int) float64 {
+	longest := math.Max(float64(a), float64(b))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - math.Abs(float64(a-b))/longest
+}
+
+// foldKey приводит строку к ключу сравнения без регистра, лишних пробелов и диакритики
+func foldKey(s string) string {
+	// transform.Chain хранит состояние, поэтому создаётся на каждый вызов
+	folder := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(folder, normalizeAnswer(s))
+	if err != nil {
+		return normalizeAnswer(s)
+	}
+	return strings.TrimSpace(folded)
+}
@@ -0,0 +1,88 @@
+package routes
+
+import (
+	"html/template"
+	"langhelperCopy/config"
+	"langhelperCopy/database"
+	"langhelperCopy/models"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ExamResult - итог завершённого экзамена
+type ExamResult struct {
+	ID         uint
+	DeckTitle  string
+	CreatedAt  time.Time
+	FinishedAt time.Time
+	Total      int
+	Correct    int
+}
+
+// Percent возвращает долю правильных ответов в процентах
+func (e ExamResult) Percent() int {
+	if e.Total == 0 {
+		return 0
+	}
+	return e.Correct * 100 / e.Total
+}
+
+// Duration возвращает время прохождения экзамена
+func (e ExamResult) Duration() string {
+	return e.FinishedAt.Sub(e.CreatedAt).Round(time.Second).String()
+}
+
+// ExamHistoryHandler показывает результаты завершённых экзаменов пользователя
+func ExamHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
+	db := database.GetDB()
+
+	var exams []ExamResult
+	err = db.Raw(`
+		SELECT s.id, d.deck_title, s.created_at, s.finished_at,
+			COUNT(c.id) AS total,
+			COUNT(c.id) FILTER (WHERE c.correct) AS correct
+		FROM langhelpercopy.study_sessions s
+		JOIN langhelpercopy.decks d ON s.deck_id = d.id
+		LEFT JOIN langhelpercopy.study_cards c ON c.session_id = s.id
+		WHERE s.user_id = ? AND s.mode = ? AND s.status = ? AND d.deleted_at IS NULL
+		GROUP BY s.id, d.deck_title, s.created_at, s.finished_at
+		ORDER BY s.finished_at DESC
+	`, userID, models.StudyModeExam, models.StudySessionFinished).Scan(&exams).Error
+	if err != nil {
+		log.Printf("Failed to load exams: %v", err)
+		http.Error(w, "Failed to load exams", http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := template.ParseFiles("templates/layout.html", "templates/exams.html")
+	if err != nil {
+		log.Printf("template.ParseFiles error: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Title string
+		Exams []ExamResult
+	}{
+		Title: "Exam History",
+		Exams: exams,
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
+		log.Printf("ExecuteTemplate error: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package routes
+
+import (
+	"encoding/csv"
+	"langhelperCopy/config"
+	"langhelperCopy/database"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ExportWordsHandler выгружает словарь пользователя в CSV:
+// по строке на слово, по группе колонок на каждый язык.
+func ExportWordsHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
+	db := database.GetDB()
+
+	// Колонки языков идут в порядке, выбранном пользователем
+	langs, err := loadUserLangs(db, userID)
+	if err != nil {
+		http.Error(w, "Failed to get user langs", http.StatusInternalServerError)
+		return
+	}
+
+	var wordIDs []uint
+	err = db.Raw(`
+		SELECT DISTINCT word_id FROM langhelpercopy.user_words
+		WHERE lang_id IN (SELECT id FROM langhelpercopy.user_langs WHERE user_id = ? AND deleted_at IS NULL)
+			AND word_id NOT IN (SELECT id FROM langhelpercopy.words WHERE deleted_at IS NOT NULL)
+		ORDER BY word_id`, userID).Scan(&wordIDs).Error
+	if err != nil {
+		http.Error(w, "Failed to load words", http.StatusInternalServerError)
+		return
+	}
+
+	langIDs := make([]uint, len(langs))
+	for i, lang := range langs {
+		langIDs[i] = lang.ID
+	}
+	details, err := loadWordDetails(db, wordIDs, langIDs)
+	if err != nil {
+		log.Printf("Failed to load word details: %v", err)
+		http.Error(w, "Failed to load words", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="words.csv"`)
+
+	out := csv.NewWriter(w)
+
+	header := []string{"word_id", "part_of_speech", "notes"}
+	for _, lang := range langs {
+		header = append(header,
+			lang.LangTitle,
+			lang.LangTitle+" alternatives",
+			lang.LangTitle+" gender",
+			lang.LangTitle+" pronunciation",
+			lang.LangTitle+" notes",
+			lang.LangTitle+" examples",
+		)
+	}
+	out.Write(header)
+
+	for _, wid := range wordIDs {
+		wd, ok := details[wid]
+		if !ok {
+			continue
+		}
+		record := []string{strconv.FormatUint(uint64(wid), 10), wd.PartOfSpeech, wd.Notes}
+		for _, lang := range langs {
+			td := wd.Lang(lang.ID)
+			record = append(record,
+				td.Translation,
+				strings.Join(td.Alternatives, "; "),
+				td.Gender,
+				td.Pronunciation,
+				td.Notes,
+				strings.Join(td.Examples, "\n"),
+			)
+		}
+		out.Write(record)
+	}
+
+	out.Flush()
+	if err := out.Error(); err != nil {
+		log.Printf("CSV export error: %v", err)
+	}
+}
@@ -11,20 +11,43 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+
+	"gorm.io/gorm"
 )
 
 type LangTest struct {
 	DeckLang models.DeckLang
 	Options  []string
-	Correct  string
+	Correct  string // используется только на сервере, в форму не выводится
+	Reverse  bool   // вопрос на изучаемом языке, ответ на основном
+	Prompt   string // слово или пример с пропуском, который показывается в вопросе
+	Cloze    bool   // нужно заполнить пропуск в примере
+	Typed    bool   // ответ вводится, а не выбирается
 }
 
+// distractorCount - число неправильных вариантов в карточке
+const distractorCount = 4
+
 type WordTest struct {
 	WordID   uint
 	MainWord string
 	Tests    []LangTest
 }
 
+// FlashcardsPageData - данные для всех шагов страницы flashcards.html
+type FlashcardsPageData struct {
+	Title          string
+	Decks          []models.Deck
+	Deck           *models.Deck
+	DeckLangs      []models.DeckLang
+	MainLang       uint
+	MainLangTitle  string
+	MainLangTag    models.LangTag
+	Pref           *models.QuizPreference
+	WordTests      []WordTest
+	ActiveSessions []StudySessionSummary
+}
+
 func FlashcardsHandler(w http.ResponseWriter, r *http.Request) {
 	session, err := config.Store.Get(r, config.SessionName)
 	if err != nil || session.Values["authenticated"] != true {
@@ -44,7 +67,7 @@ func FlashcardsHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Загружаем колоды пользователя
 	var decks []models.Deck
-	err = db.Raw("SELECT * FROM langhelpercopy.decks WHERE user_id = ? ORDER BY deck_title", userID).Scan(&decks).Error
+	err = db.Raw("SELECT * FROM langhelpercopy.decks WHERE user_id = ? AND deleted_at IS NULL ORDER BY deck_title", userID).Scan(&decks).Error
 	if err != nil {
 		log.Printf("Failed to load decks: %v", err)
 		http.Error(w, "Failed to load decks", http.StatusInternalServerError)
@@ -59,16 +82,16 @@ func FlashcardsHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		data := struct {
-			Title     string
-			Decks     []models.Deck
-			Deck      *models.Deck
-			DeckLangs []models.DeckLang
-			MainLang  uint
-			WordTests []WordTest
-		}{
-			Title: "Flashcards",
-			Decks: decks,
+		// Незавершённые сессии можно продолжить с любого устройства
+		activeSessions, err := loadActiveStudySessions(db, userID)
+		if err != nil {
+			log.Printf("Failed to load study sessions: %v", err)
+		}
+
+		data := FlashcardsPageData{
+			Title:          "Flashcards",
+			Decks:          decks,
+			ActiveSessions: activeSessions,
 		}
 
 		err = tmpl.ExecuteTemplate(w, "layout.html", data)
@@ -105,8 +128,7 @@ func handleSelectDeck(w http.ResponseWriter, r *http.Request, userID uint, decks
 	}
 
 	db := database.GetDB()
-	var deck models.Deck
-	err = db.Raw("SELECT * FROM langhelpercopy.decks WHERE id = ?", deckID).Scan(&deck).Error
+	deck, err := loadUserDeck(db, uint(deckID), userID)
 	if err != nil {
 		log.Printf("Failed to find deck: %v", err)
 		http.Error(w, "Deck not found", http.StatusNotFound)
@@ -114,7 +136,12 @@ func handleSelectDeck(w http.ResponseWriter, r *http.Request, userID uint, decks
 	}
 
 	var deckLangs []models.DeckLang
-	err = db.Raw("SELECT * FROM langhelpercopy.deck_langs WHERE deck_id = ?", deckID).Scan(&deckLangs).Error
+	err = db.Raw(`
+		SELECT dl.* FROM langhelpercopy.deck_langs dl
+		JOIN langhelpercopy.user_langs l ON dl.lang_id = l.id
+		WHERE dl.deck_id = ? AND l.deleted_at IS NULL
+		ORDER BY dl.position, l.position, l.id
+	`, deckID).Scan(&deckLangs).Error
 	if err != nil {
 		log.Printf("Failed to load deck languages: %v", err)
 		http.Error(w, "Failed to load deck languages", http.StatusInternalServerError)
@@ -132,6 +159,16 @@ func handleSelectDeck(w http.ResponseWriter, r *http.Request, userID uint, decks
 		deckLangs[i].UserLang = userLang
 	}
 
+	// Подставляем настройки прошлой тренировки по этой колоде
+	pref, err := loadQuizPreference(db, userID, deck.ID)
+	if err != nil {
+		log.Printf("Failed to load quiz preferences: %v", err)
+	}
+	userMainLang, err := userMainLangID(db, userID)
+	if err != nil {
+		log.Printf("Failed to load main language: %v", err)
+	}
+
 	tmpl, err := template.ParseFiles("templates/layout.html", "templates/flashcards.html")
 	if err != nil {
 		log.Printf("template.ParseFiles error: %v", err)
@@ -139,18 +176,13 @@ func handleSelectDeck(w http.ResponseWriter, r *http.Request, userID uint, decks
 		return
 	}
 
-	data := struct {
-		Title     string
-		Decks     []models.Deck
-		Deck      *models.Deck
-		DeckLangs []models.DeckLang
-		MainLang  uint
-		WordTests []WordTest
-	}{
+	data := FlashcardsPageData{
 		Title:     "Flashcards",
 		Decks:     decks,
 		Deck:      &deck,
 		DeckLangs: deckLangs,
+		MainLang:  defaultPromptLangID(deck, pref, userMainLang, deckLangs),
+		Pref:      pref,
 	}
 
 	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
@@ -176,8 +208,7 @@ func handleSelectLang(w http.ResponseWriter, r *http.Request, userID uint, decks
 	db := database.GetDB()
 
 	// Загружаем колоду
-	var deck models.Deck
-	err = db.Raw("SELECT * FROM langhelpercopy.decks WHERE id = ?", deckID).Scan(&deck).Error
+	deck, err := loadUserDeck(db, uint(deckID), userID)
 	if err != nil {
 		log.Printf("Failed to find deck: %v", err)
 		http.Error(w, "Deck not found", http.StatusNotFound)
@@ -186,33 +217,147 @@ func handleSelectLang(w http.ResponseWriter, r *http.Request, userID uint, decks
 
 	// Загружаем языки колоды
 	var deckLangs []models.DeckLang
-	err = db.Raw("SELECT * FROM langhelpercopy.deck_langs WHERE deck_id = ?", deckID).Scan(&deckLangs).Error
+	err = db.Raw(`
+		SELECT dl.* FROM langhelpercopy.deck_langs dl
+		JOIN langhelpercopy.user_langs l ON dl.lang_id = l.id
+		WHERE dl.deck_id = ? AND l.deleted_at IS NULL
+		ORDER BY dl.position, l.position, l.id
+	`, deckID).Scan(&deckLangs).Error
 	if err != nil {
 		log.Printf("Failed to load deck languages: %v", err)
 		http.Error(w, "Failed to load deck languages", http.StatusInternalServerError)
 		return
 	}
 
-	// Загружаем слова из колоды
-	var deckWords []models.DeckWord
-	err = db.Raw("SELECT * FROM langhelpercopy.deck_words WHERE deck_id = ?", deckID).Scan(&deckWords).Error
+	// Язык вопросов сохраняется в настройках и сессии, поэтому он должен
+	// быть одним из языков колоды
+	mainLangInDeck := false
+	for _, dl := range deckLangs {
+		if dl.LangID == uint(mainLangID) {
+			mainLangInDeck = true
+			break
+		}
+	}
+	if !mainLangInDeck {
+		http.Error(w, "Language is not in this deck", http.StatusBadRequest)
+		return
+	}
+
+	// Загружаем слова из колоды (для умной колоды - по её правилу),
+	// отключённые и отложенные слова пропускаются
+	wordIDs, err := studyDeckWordIDs(db, deck)
 	if err != nil {
 		log.Printf("Failed to load words: %v", err)
 		http.Error(w, "Failed to load words", http.StatusInternalServerError)
 		return
 	}
 
-	if len(deckWords) == 0 {
+	if len(wordIDs) == 0 {
 		http.Error(w, "No words in deck", http.StatusBadRequest)
 		return
 	}
 
-	// Собираем ID слов
-	wordIDs := make([]uint, len(deckWords))
-	for i, dw := range deckWords {
-		wordIDs[i] = dw.WordID
+	// Загружаем названия языков
+	var mainLangTitle string
+	var mainLangTag models.LangTag
+	for i := range deckLangs {
+		var userLang models.UserLang
+		err = db.Raw("SELECT * FROM langhelpercopy.user_langs WHERE id = ?", deckLangs[i].LangID).Scan(&userLang).Error
+		if err != nil {
+			log.Printf("Failed to load language title: %v", err)
+			continue
+		}
+		deckLangs[i].UserLang = userLang
+		if userLang.ID == uint(mainLangID) {
+			mainLangTitle = userLang.LangTitle
+			mainLangTag = models.LangTag{LangCode: userLang.LangCode}
+		}
 	}
 
+	// Настройки тренировки запоминаются для колоды
+	pref := parseQuizPreference(r, userID, deck.ID, uint(mainLangID), deckLangs)
+	if err := saveQuizPreference(db, pref); err != nil {
+		log.Printf("Failed to save quiz preferences: %v", err)
+	}
+
+	// Для игры на пары нужен другой набор: несколько слов и один изучаемый язык
+	mode := r.FormValue("mode")
+	var wordTests []WordTest
+	if mode == "match" {
+		wordTests, err = buildMatchPairs(db, wordIDs, uint(mainLangID), pref)
+	} else {
+		wordTests, err = buildWordTests(db, userID, wordIDs, uint(mainLangID), deckLangs, pref)
+	}
+	if err != nil {
+		log.Printf("Failed to build tests: %v", err)
+		http.Error(w, "Failed to load main translations", http.StatusInternalServerError)
+		return
+	}
+
+	// По одной карточке, экзамен и пары: тесты сохраняются в сессии на сервере
+	if mode == "session" || mode == "exam" || mode == "match" {
+		if len(wordTests) == 0 {
+			http.Error(w, "No words to study", http.StatusBadRequest)
+			return
+		}
+		study := models.StudySession{
+			UserID:     userID,
+			DeckID:     deck.ID,
+			MainLangID: uint(mainLangID),
+			Mode:       models.StudyModeStudy,
+		}
+		switch mode {
+		case "exam":
+			study.Mode = models.StudyModeExam
+			study.TimeLimitSec, study.CardTimeLimitSec, err = parseExamLimits(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		case "match":
+			if len(wordTests) < matchMinPairs {
+				http.Error(w, fmt.Sprintf("Matching needs at least %d words translated into both languages", matchMinPairs), http.StatusBadRequest)
+				return
+			}
+			study.Mode = models.StudyModeMatch
+		}
+		sessionID, err := createStudySession(db, study, wordTests)
+		if err != nil {
+			log.Printf("Failed to create study session: %v", err)
+			http.Error(w, "Failed to start session", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/study/%d", sessionID), http.StatusSeeOther)
+		return
+	}
+
+	tmpl, err := template.ParseFiles("templates/layout.html", "templates/flashcards.html")
+	if err != nil {
+		log.Printf("template.ParseFiles error: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := FlashcardsPageData{
+		Title:         "Flashcards",
+		Decks:         decks,
+		Deck:          &deck,
+		DeckLangs:     deckLangs,
+		MainLang:      uint(mainLangID),
+		MainLangTitle: mainLangTitle,
+		MainLangTag:   mainLangTag,
+		Pref:          &pref,
+		WordTests:     wordTests,
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
+		log.Printf("ExecuteTemplate error: %v", err)
+	}
+}
+
+// buildWordTests формирует тесты по словам колоды согласно настройкам тренировки:
+// слова перемешиваются, число карточек ограничивается pref.CardCount.
+func buildWordTests(db *gorm.DB, userID uint, wordIDs []uint, mainLangID uint, deckLangs []models.DeckLang, pref models.QuizPreference) ([]WordTest, error) {
 	// Формируем IN условие для SQL запроса
 	inClause := "("
 	for i, id := range wordIDs {
@@ -225,11 +370,9 @@ func handleSelectLang(w http.ResponseWriter, r *http.Request, userID uint, decks
 
 	// Загружаем основные переводы
 	var mainTranslations []models.UserWord
-	err = db.Raw("SELECT * FROM langhelpercopy.user_words WHERE word_id IN "+inClause+" AND lang_id = ?", mainLangID).Scan(&mainTranslations).Error
+	err := db.Raw("SELECT * FROM langhelpercopy.user_words WHERE word_id IN "+inClause+" AND lang_id = ? AND is_primary", mainLangID).Scan(&mainTranslations).Error
 	if err != nil {
-		log.Printf("Failed to load main translations: %v", err)
-		http.Error(w, "Failed to load main translations", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 
 	mainMap := make(map[uint]string)
@@ -237,7 +380,22 @@ func handleSelectLang(w http.ResponseWriter, r *http.Request, userID uint, decks
 		mainMap[uw.WordID] = uw.Translation
 	}
 
+	// Слова идут в случайном порядке, чтобы ограничение числа карточек давало разные наборы
+	rand.Shuffle(len(wordIDs), func(i, j int) {
+		wordIDs[i], wordIDs[j] = wordIDs[j], wordIDs[i]
+	})
+
+	// Примеры предложений для карточек с пропуском
+	var examples map[uint]map[uint][]string
+	if pref.ClozeMode != models.ClozeOff {
+		examples, err = loadExampleSentences(db, wordIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Формируем тесты
+	distractors := newDistractorEngine(db, userID, wordIDs)
 	var wordTests []WordTest
 	for _, wid := range wordIDs {
 		mainWord, ok := mainMap[wid]
@@ -250,97 +408,166 @@ func handleSelectLang(w http.ResponseWriter, r *http.Request, userID uint, decks
 			MainWord: mainWord,
 		}
 
-		// Для каждого языка (кроме основного) создаем тест
+		mainAccepted, err := loadAcceptedTranslations(db, wid, mainLangID)
+		if err != nil {
+			log.Printf("Failed to load accepted translations: %v", err)
+			continue
+		}
+
+		// Для каждого выбранного изучаемого языка создаем тест
 		for _, dl := range deckLangs {
-			if dl.LangID == uint(mainLangID) {
+			if !pref.HasTarget(dl.LangID) {
 				continue
 			}
 
-			// Загружаем правильный перевод
-			var correct models.UserWord
-			err = db.Raw("SELECT * FROM langhelpercopy.user_words WHERE word_id = ? AND lang_id = ? LIMIT 1", wid, dl.LangID).Scan(&correct).Error
+			// Синонимы тоже верны, поэтому не должны попадать в неправильные варианты
+			targetAccepted, err := loadAcceptedTranslations(db, wid, dl.LangID)
 			if err != nil {
+				log.Printf("Failed to load accepted translations: %v", err)
 				continue
 			}
-
-			// Загружаем 4 случайных неправильных варианта
-			var wrongOptions []models.UserWord
-			err = db.Raw("SELECT * FROM langhelpercopy.user_words WHERE lang_id = ? AND translation != ? ORDER BY RANDOM() LIMIT 4", dl.LangID, correct.Translation).Scan(&wrongOptions).Error
-			if err != nil {
-				log.Printf("Failed to load wrong options: %v", err)
+			if len(targetAccepted) == 0 {
 				continue
 			}
 
-			// Формируем варианты ответов
-			options := make([]string, 0, 5)
-			options = append(options, correct.Translation)
-			for _, wo := range wrongOptions {
-				options = append(options, wo.Translation)
+			// Если слово есть в примере, карточка становится карточкой с пропуском
+			if pref.ClozeMode != models.ClozeOff {
+				if sentence, ok := pickCloze(examples[wid][dl.LangID], targetAccepted); ok {
+					test := LangTest{
+						DeckLang: dl,
+						Correct:  targetAccepted[0],
+						Prompt:   sentence,
+						Cloze:    true,
+						Typed:    pref.ClozeMode == models.ClozeTyped,
+					}
+					if !test.Typed {
+						test.Options, err = choiceOptions(distractors, wid, dl.LangID, targetAccepted)
+						if err != nil {
+							log.Printf("Failed to load wrong options: %v", err)
+							continue
+						}
+					}
+					wt.Tests = append(wt.Tests, test)
+					continue
+				}
 			}
 
-			// Перемешиваем варианты
-			rand.Shuffle(len(options), func(i, j int) {
-				options[i], options[j] = options[j], options[i]
-			})
+			reverse := pref.Direction == models.DirectionReverse ||
+				(pref.Direction == models.DirectionMixed && rand.IntN(2) == 0)
 
-			// Загружаем информацию о языке
-			var userLang models.UserLang
-			err = db.Raw("SELECT * FROM langhelpercopy.user_langs WHERE id = ?", dl.LangID).Scan(&userLang).Error
+			// В обратном направлении спрашивается слово изучаемого языка,
+			// а варианты даются на основном
+			prompt, answerLangID, accepted := mainWord, dl.LangID, targetAccepted
+			if reverse {
+				prompt, answerLangID, accepted = targetAccepted[0], mainLangID, mainAccepted
+			}
+			if len(accepted) == 0 {
+				continue
+			}
+
+			options, err := choiceOptions(distractors, wid, answerLangID, accepted)
 			if err != nil {
+				log.Printf("Failed to load wrong options: %v", err)
 				continue
 			}
-			dl.UserLang = userLang
 
 			wt.Tests = append(wt.Tests, LangTest{
 				DeckLang: dl,
 				Options:  options,
-				Correct:  correct.Translation,
+				Correct:  accepted[0],
+				Reverse:  reverse,
+				Prompt:   prompt,
 			})
 		}
 
 		if len(wt.Tests) > 0 {
 			wordTests = append(wordTests, wt)
 		}
+		if pref.CardCount > 0 && len(wordTests) >= pref.CardCount {
+			break
+		}
 	}
 
-	tmpl, err := template.ParseFiles("templates/layout.html", "templates/flashcards.html")
+	return wordTests, nil
+}
+
+// choiceOptions возвращает перемешанные варианты ответа: правильный и неправильные
+func choiceOptions(distractors *distractorEngine, wordID, langID uint, accepted []string) ([]string, error) {
+	// Подбираем неправильные варианты
+	wrongOptions, err := distractors.pick(wordID, langID, accepted, distractorCount)
 	if err != nil {
-		log.Printf("template.ParseFiles error: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 
-	data := struct {
-		Title     string
-		Decks     []models.Deck
-		Deck      *models.Deck
-		DeckLangs []models.DeckLang
-		MainLang  uint
-		WordTests []WordTest
-	}{
-		Title:     "Flashcards",
-		Decks:     decks,
-		Deck:      &deck,
-		DeckLangs: deckLangs,
-		MainLang:  uint(mainLangID),
-		WordTests: wordTests,
+	// Формируем варианты ответов
+	options := make([]string, 0, distractorCount+1)
+	options = append(options, accepted[0])
+	options = append(options, wrongOptions...)
+
+	// Перемешиваем варианты
+	rand.Shuffle(len(options), func(i, j int) {
+		options[i], options[j] = options[j], options[i]
+	})
+	return options, nil
+}
+
+// parseQuizPreference читает настройки тренировки из формы. Если изучаемые
+// языки не выбраны, тестируются все языки колоды кроме основного.
+func parseQuizPreference(r *http.Request, userID, deckID, mainLangID uint, deckLangs []models.DeckLang) models.QuizPreference {
+	pref := models.QuizPreference{
+		UserID:     userID,
+		DeckID:     deckID,
+		MainLangID: mainLangID,
+		Direction:  r.FormValue("direction"),
+	}
+	if !models.IsValidDirection(pref.Direction) {
+		pref.Direction = models.DirectionForward
+	}
+	if count, err := strconv.Atoi(r.FormValue("card_count")); err == nil && count > 0 {
+		pref.CardCount = count
+	}
+	if mode := r.FormValue("cloze_mode"); models.IsValidClozeMode(mode) {
+		pref.ClozeMode = mode
 	}
 
-	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
-		log.Printf("ExecuteTemplate error: %v", err)
+	selected := make(map[uint]bool)
+	for _, raw := range r.Form["target_lang_ids"] {
+		if id, err := strconv.ParseUint(raw, 10, 64); err == nil {
+			selected[uint(id)] = true
+		}
 	}
+
+	var targets []uint
+	for _, dl := range deckLangs {
+		if dl.LangID == mainLangID {
+			continue
+		}
+		if len(selected) == 0 || selected[dl.LangID] {
+			targets = append(targets, dl.LangID)
+		}
+	}
+	pref.SetTargets(targets)
+	return pref
 }
 
 type FlashcardResult struct {
-	MainWord    string
-	LangResults []LangResult
+	MainWord     string
+	PartOfSpeech string
+	Notes        string
+	LangResults  []LangResult
 }
 
 type LangResult struct {
-	Name    string
-	Chosen  string
-	Correct string
-	Status  string // "correct" или "incorrect"
+	Name           string
+	Chosen         string
+	Correct        string
+	Alternatives   []string
+	Gender         string
+	Pronunciation  string
+	Examples       []string
+	Reverse        bool   // ответ давался на основном языке
+	Status         string // "correct", "incorrect" или "missing"
+	models.LangTag        // язык ответа
 }
 
 func FlashcardsCheckHandler(w http.ResponseWriter, r *http.Request) {
@@ -355,6 +582,12 @@ func FlashcardsCheckHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "Failed to parse form", http.StatusBadRequest)
 		return
@@ -366,30 +599,70 @@ func FlashcardsCheckHandler(w http.ResponseWriter, r *http.Request) {
 	deckID := r.FormValue("deck_id")
 	mainLangID := r.FormValue("main_lang_id")
 
+	// Ответы записываются только по своей колоде и её словам
+	deckIDNum, err := strconv.ParseUint(deckID, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+	deck, err := loadUserDeck(db, uint(deckIDNum), userID)
+	if err != nil {
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+	deckWords, err := studyDeckWordIDs(db, deck)
+	if err != nil {
+		log.Printf("Failed to load deck words: %v", err)
+		http.Error(w, "Failed to load deck words", http.StatusInternalServerError)
+		return
+	}
+
 	// Получаем название основного языка
-	var mainLangTitle string
-	err = db.Raw("SELECT lang_title FROM user_langs WHERE id = ?", mainLangID).Scan(&mainLangTitle).Error
+	var mainLang models.UserLang
+	err = db.Raw("SELECT id, lang_title, lang_code FROM user_langs WHERE id = ? AND user_id = ?", mainLangID, userID).Scan(&mainLang).Error
+	mainLangTitle := mainLang.LangTitle
 	if err != nil {
 		http.Error(w, "Failed to get main language title", http.StatusInternalServerError)
 		return
 	}
+	if mainLang.ID == 0 {
+		http.Error(w, "Language not found", http.StatusNotFound)
+		return
+	}
 
 	// Получаем все языки колоды кроме основного
 	var otherLangs []struct {
 		ID    uint
 		Title string
+		models.LangTag
 	}
 	err = db.Raw(`
-        SELECT ul.id, ul.lang_title as title 
+        SELECT ul.id, ul.lang_title as title, ul.lang_code 
         FROM deck_langs dl
         JOIN user_langs ul ON dl.lang_id = ul.id
-        WHERE dl.deck_id = ? AND ul.id != ?
+        WHERE dl.deck_id = ? AND ul.id != ? AND ul.deleted_at IS NULL
     `, deckID, mainLangID).Scan(&otherLangs).Error
 	if err != nil {
 		http.Error(w, "Failed to get other languages", http.StatusInternalServerError)
 		return
 	}
 
+	// Оставляем только языки, выбранные для тренировки
+	if targets := r.Form["target_lang_ids"]; len(targets) > 0 {
+		selected := make(map[string]bool, len(targets))
+		for _, id := range targets {
+			selected[id] = true
+		}
+		filtered := otherLangs[:0]
+		for _, lang := range otherLangs {
+			if selected[strconv.FormatUint(uint64(lang.ID), 10)] {
+				filtered = append(filtered, lang)
+			}
+		}
+		otherLangs = filtered
+	}
+	mainLangIDNum, _ := strconv.ParseUint(mainLangID, 10, 64)
+
 	// Собираем названия языков для заголовков таблицы
 	var langTitles []string
 	for _, lang := range otherLangs {
@@ -398,42 +671,133 @@ func FlashcardsCheckHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Обрабатываем ответы
 	var results []FlashcardResult
+	var reviews []models.Review
 
 	// Проходим по всем словам в форме
 	for key, values := range r.Form {
 		if strings.HasPrefix(key, "word_") && strings.HasSuffix(key, "_main") {
 			// Извлекаем ID слова
 			wordIDStr := strings.TrimPrefix(strings.TrimSuffix(key, "_main"), "word_")
+			wordID, err := strconv.ParseUint(wordIDStr, 10, 64)
+			if err != nil || !containsID(deckWords, uint(wordID)) {
+				continue
+			}
 			mainWord := values[0]
 
 			result := FlashcardResult{
 				MainWord: mainWord,
 			}
 
+			// Метаданные слова показываются рядом с результатами
+			langIDs := make([]uint, len(otherLangs))
+			for i, lang := range otherLangs {
+				langIDs[i] = lang.ID
+			}
+			details, err := loadWordDetails(db, []uint{uint(wordID)}, langIDs)
+			if err != nil {
+				log.Printf("Failed to load word details: %v", err)
+			}
+			wd := details[uint(wordID)]
+			if wd != nil {
+				result.PartOfSpeech = wd.PartOfSpeech
+				result.Notes = wd.Notes
+			}
+
 			// Проверяем ответы для каждого языка
 			for _, lang := range otherLangs {
 				answerKey := fmt.Sprintf("word_%s_lang_%d", wordIDStr, lang.ID)
 				chosenAnswer := r.FormValue(answerKey)
+				// Поле _correct только отмечает, что карточка по языку была:
+				// правильный ответ в форму не попадает
 				correctAnswerKey := fmt.Sprintf("word_%s_lang_%d_correct", wordIDStr, lang.ID)
-				correctAnswer := r.FormValue(correctAnswerKey)
+
+				// Карточки по этому языку не было, колонка остаётся пустой
+				if _, ok := r.Form[correctAnswerKey]; !ok {
+					result.LangResults = append(result.LangResults, LangResult{Name: lang.Title, Status: "missing"})
+					continue
+				}
+
+				// В обратном направлении ответ дан на основном языке
+				reverse := r.FormValue(fmt.Sprintf("word_%s_lang_%d_dir", wordIDStr, lang.ID)) == models.DirectionReverse
+				answerLangID := lang.ID
+				if reverse {
+					answerLangID = uint(mainLangIDNum)
+				}
+
+				// Ответ проверяется по всем переводам из базы, а не по полю формы
+				accepted, err := loadAcceptedTranslations(db, uint(wordID), answerLangID)
+				if err != nil {
+					log.Printf("Failed to load accepted translations: %v", err)
+					http.Error(w, "Failed to check answers", http.StatusInternalServerError)
+					return
+				}
+				var correctAnswer string
+				if len(accepted) > 0 {
+					correctAnswer = accepted[0]
+				}
 
 				status := "incorrect"
-				if chosenAnswer == correctAnswer {
+				correct := isAcceptedAnswer(chosenAnswer, accepted)
+				if correct {
 					status = "correct"
 				}
 
-				result.LangResults = append(result.LangResults, LangResult{
-					Name:    lang.Title,
-					Chosen:  chosenAnswer,
-					Correct: correctAnswer,
-					Status:  status,
+				reviews = append(reviews, models.Review{
+					UserID:  userID,
+					DeckID:  deck.ID,
+					WordID:  uint(wordID),
+					LangID:  answerLangID,
+					Answer:  chosenAnswer,
+					Correct: correct,
 				})
+
+				var alternatives []string
+				if len(accepted) > 1 {
+					alternatives = accepted[1:]
+				}
+
+				langResult := LangResult{
+					Name:         lang.Title,
+					Chosen:       chosenAnswer,
+					Correct:      correctAnswer,
+					Alternatives: alternatives,
+					Reverse:      reverse,
+					Status:       status,
+					LangTag:      lang.LangTag,
+				}
+				if reverse {
+					langResult.LangTag = models.LangTag{LangCode: mainLang.LangCode}
+				}
+				if wd != nil && !reverse {
+					td := wd.Lang(lang.ID)
+					langResult.Gender = td.Gender
+					langResult.Pronunciation = td.Pronunciation
+					langResult.Examples = td.Examples
+				}
+
+				result.LangResults = append(result.LangResults, langResult)
 			}
 
 			results = append(results, result)
 		}
 	}
 
+	// Ответы сохраняются в истории для статистики и умных колод; все
+	// ответы тренировки записываются вместе
+	err = database.UnitOfWork(db, func(tx *gorm.DB) error {
+		for _, review := range reviews {
+			if err := recordReview(tx, review); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to record reviews: %v", err)
+		http.Error(w, "Failed to save answers", http.StatusInternalServerError)
+		return
+	}
+
 	// Рендерим страницу с результатами
 	tmpl, err := template.ParseFiles("templates/layout.html", "templates/flashcardsCheck.html")
 	if err != nil {
@@ -445,11 +809,13 @@ func FlashcardsCheckHandler(w http.ResponseWriter, r *http.Request) {
 	data := struct {
 		Title         string
 		MainLangTitle string
+		MainLangTag   models.LangTag
 		LangTitles    []string
 		Results       []FlashcardResult
 	}{
 		Title:         "Flashcards Results",
 		MainLangTitle: mainLangTitle,
+		MainLangTag:   models.LangTag{LangCode: mainLang.LangCode},
 		LangTitles:    langTitles,
 		Results:       results,
 	}
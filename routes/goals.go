@@ -0,0 +1,348 @@
+package routes
+
+import (
+	"encoding/json"
+	"langhelperCopy/config"
+	"langhelperCopy/database"
+	"langhelperCopy/models"
+	"log"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Время занятий оценивается по промежуткам между ответами
+const (
+	studyBreakSeconds  = 300 // более долгая пауза считается перерывом
+	firstAnswerSeconds = 20  // сколько засчитывается за первый ответ после перерыва
+)
+
+// StudyDay - занятия за один день в часовом поясе пользователя
+type StudyDay struct {
+	Reviews int
+	Seconds float64
+}
+
+// Minutes возвращает время занятий в полных минутах
+func (d StudyDay) Minutes() int {
+	return int(d.Seconds / 60)
+}
+
+// GoalProgress - выполнение дневной цели и серия дней, когда цель выполнена
+type GoalProgress struct {
+	Type    string `json:"type"`
+	Target  int    `json:"target"`
+	Done    int    `json:"done"`
+	Met     bool   `json:"met"`
+	Streak  int    `json:"streak"`
+	Freezes int    `json:"freezes"`
+}
+
+// Percent возвращает выполнение цели в процентах, не больше 100
+func (g GoalProgress) Percent() int {
+	if g.Target <= 0 || g.Done >= g.Target {
+		return 100
+	}
+	return g.Done * 100 / g.Target
+}
+
+// studyHistory - история занятий пользователя по дням его часового пояса.
+// Days покрывает только окно тепловой карты, Kept - все дни, когда цель
+// выполнена или серию сохранила заморозка.
+type studyHistory struct {
+	User  models.User
+	Today time.Time
+	Days  map[string]StudyDay
+	Kept  map[string]bool
+}
+
+// loadStudyHistory загружает историю занятий, ничего не записывая: выполненные
+// дни, заморозки и их выдача сохраняются при ответах (см. updateGoalDay).
+// Заморозки, которые закроют пропуск при следующем ответе, уже учитываются в
+// серии, чтобы она не обнулялась до возвращения пользователя.
+func loadStudyHistory(db *gorm.DB, userID uint) (studyHistory, error) {
+	var history studyHistory
+	var err error
+	if history.User, err = loadGoalUser(db, userID); err != nil {
+		return history, err
+	}
+	if history.Today, err = userToday(db, history.User.Timezone); err != nil {
+		return history, err
+	}
+	if history.Days, err = loadStudyDays(db, userID, history.User.Timezone, heatmapStart(history.Today)); err != nil {
+		return history, err
+	}
+	if history.Kept, err = loadKeptDays(db, userID); err != nil {
+		return history, err
+	}
+
+	missed := missedGoalDays(history.Kept, history.Today, history.User.StreakFreezes)
+	history.User.StreakFreezes -= len(missed)
+	for _, day := range missed {
+		history.Kept[day] = true
+	}
+	return history, nil
+}
+
+// loadGoalUser загружает цель, часовой пояс и заморозки пользователя
+func loadGoalUser(db *gorm.DB, userID uint) (models.User, error) {
+	var user models.User
+	err := db.Raw(`
+		SELECT id, timezone, daily_goal_type, daily_goal, streak_freezes, freeze_awarded_on
+		FROM langhelpercopy.users WHERE id = ?
+	`, userID).Scan(&user).Error
+	if err != nil {
+		return user, err
+	}
+	if models.ValidateTimezone(user.Timezone) != nil {
+		user.Timezone = "UTC"
+	}
+	return user, nil
+}
+
+// userToday возвращает сегодняшнюю дату в часовом поясе tz
+func userToday(db *gorm.DB, tz string) (time.Time, error) {
+	var today string
+	err := db.Raw("SELECT TO_CHAR(NOW() AT TIME ZONE ?, 'YYYY-MM-DD')", tz).Scan(&today).Error
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(dayLayout, today)
+}
+
+// loadKeptDays возвращает дни с выполненной целью и дни, закрытые заморозкой
+func loadKeptDays(db *gorm.DB, userID uint) (map[string]bool, error) {
+	var days []string
+	err := db.Raw(`
+		SELECT TO_CHAR(day, 'YYYY-MM-DD') FROM langhelpercopy.goal_days WHERE user_id = ?
+		UNION
+		SELECT TO_CHAR(day, 'YYYY-MM-DD') FROM langhelpercopy.streak_freezes WHERE user_id = ?
+	`, userID, userID).Scan(&days).Error
+	if err != nil {
+		return nil, err
+	}
+
+	kept := make(map[string]bool, len(days))
+	for _, day := range days {
+		kept[day] = true
+	}
+	return kept, nil
+}
+
+// userTimezone возвращает часовой пояс пользователя, UTC если он не задан
+func userTimezone(db *gorm.DB, userID uint) (string, error) {
+	var timezone string
+	err := db.Raw("SELECT timezone FROM langhelpercopy.users WHERE id = ?", userID).Scan(&timezone).Error
+	if err != nil {
+		return "", err
+	}
+	if models.ValidateTimezone(timezone) != nil {
+		timezone = "UTC"
+	}
+	return timezone, nil
+}
+
+// loadStudyDays считает ответы и время занятий по дням в часовом поясе tz,
+// начиная с дня from. Первый ответ окна считается ответом после перерыва.
+func loadStudyDays(db *gorm.DB, userID uint, tz string, from time.Time) (map[string]StudyDay, error) {
+	var rows []struct {
+		Day     string
+		Reviews int
+		Seconds float64
+	}
+	err := db.Raw(`
+		SELECT TO_CHAR(day, 'YYYY-MM-DD') AS day, COUNT(*) AS reviews, SUM(seconds) AS seconds
+		FROM (
+			SELECT DATE(created_at AT TIME ZONE ?) AS day,
+				CASE
+					WHEN gap IS NULL OR gap > ? THEN ?
+					ELSE gap
+				END AS seconds
+			FROM (
+				SELECT created_at,
+					EXTRACT(EPOCH FROM created_at - LAG(created_at) OVER (ORDER BY created_at)) AS gap
+				FROM langhelpercopy.reviews
+				WHERE user_id = ? AND created_at >= CAST(? AS timestamp) AT TIME ZONE ?
+			) answers
+		) timed
+		GROUP BY day
+	`, tz, studyBreakSeconds, firstAnswerSeconds, userID, from.Format(dayLayout), tz).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	days := make(map[string]StudyDay, len(rows))
+	for _, row := range rows {
+		days[row.Day] = StudyDay{Reviews: row.Reviews, Seconds: row.Seconds}
+	}
+	return days, nil
+}
+
+// goalDone возвращает выполненную за день часть цели в её единицах
+func goalDone(user models.User, day StudyDay) int {
+	if user.DailyGoalType == models.GoalMinutes {
+		return day.Minutes()
+	}
+	return day.Reviews
+}
+
+// updateGoalDay вызывается при каждом ответе. Пока цель на сегодня не
+// выполнена, заморозки закрывают пропуски после последнего дня серии; когда
+// ответов хватает, день записывается выполненным и может принести заморозку.
+func updateGoalDay(tx *gorm.DB, userID uint) error {
+	// Строка пользователя блокируется, чтобы параллельные ответы не потратили
+	// и не выдали заморозки дважды
+	if err := tx.Exec("SELECT id FROM langhelpercopy.users WHERE id = ? FOR UPDATE", userID).Error; err != nil {
+		return err
+	}
+	user, err := loadGoalUser(tx, userID)
+	if err != nil {
+		return err
+	}
+	today, err := userToday(tx, user.Timezone)
+	if err != nil {
+		return err
+	}
+
+	kept, err := loadKeptDays(tx, userID)
+	if err != nil {
+		return err
+	}
+	if kept[today.Format(dayLayout)] {
+		return nil
+	}
+	if err := applyStreakFreezes(tx, &user, kept, today); err != nil {
+		return err
+	}
+
+	days, err := loadStudyDays(tx, userID, user.Timezone, today)
+	if err != nil {
+		return err
+	}
+	if goalDone(user, days[today.Format(dayLayout)]) < user.DailyGoal {
+		return nil
+	}
+	err = tx.Exec(`
+		INSERT INTO langhelpercopy.goal_days (user_id, day) VALUES (?, ?)
+		ON CONFLICT (user_id, day) DO NOTHING
+	`, userID, today.Format(dayLayout)).Error
+	if err != nil {
+		return err
+	}
+	kept[today.Format(dayLayout)] = true
+	return awardStreakFreeze(tx, user, kept, today)
+}
+
+// missedGoalDays возвращает пропуски между последним днём серии и вчерашним
+// днём, если на все хватает available заморозок. Иначе серия всё равно
+// прервана и заморозки не тратятся.
+func missedGoalDays(kept map[string]bool, today time.Time, available int) []string {
+	if available <= 0 || len(kept) == 0 {
+		return nil
+	}
+
+	var missed []string
+	day := today.AddDate(0, 0, -1)
+	for !kept[day.Format(dayLayout)] {
+		missed = append(missed, day.Format(dayLayout))
+		if len(missed) > available {
+			return nil
+		}
+		day = day.AddDate(0, 0, -1)
+	}
+	return missed
+}
+
+// applyStreakFreezes закрывает заморозками пропуски, найденные missedGoalDays
+func applyStreakFreezes(tx *gorm.DB, user *models.User, kept map[string]bool, today time.Time) error {
+	missed := missedGoalDays(kept, today, user.StreakFreezes)
+	if len(missed) == 0 {
+		return nil
+	}
+
+	// Заморозки - служебный счётчик: версию пользователя они не меняют,
+	// чтобы открытая страница настроек не устаревала из-за тренировки
+	result := tx.Exec(`
+		UPDATE langhelpercopy.users SET streak_freezes = streak_freezes - ?
+		WHERE id = ? AND streak_freezes >= ?
+	`, len(missed), user.ID, len(missed))
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+	for _, day := range missed {
+		err := tx.Exec(`
+			INSERT INTO langhelpercopy.streak_freezes (user_id, day) VALUES (?, ?)
+			ON CONFLICT (user_id, day) DO NOTHING
+		`, user.ID, day).Error
+		if err != nil {
+			return err
+		}
+		kept[day] = true
+	}
+	user.StreakFreezes -= len(missed)
+	return nil
+}
+
+// awardStreakFreeze выдаёт заморозку в день, когда серия достигла очередных
+// StreakFreezeEvery дней, но не больше MaxStreakFreezes одновременно.
+func awardStreakFreeze(tx *gorm.DB, user models.User, kept map[string]bool, today time.Time) error {
+	day := today.Format(dayLayout)
+	streak, _ := reviewStreaks(kept, today)
+	if !kept[day] || streak == 0 || streak%models.StreakFreezeEvery != 0 {
+		return nil
+	}
+	if user.StreakFreezes >= models.MaxStreakFreezes {
+		return nil
+	}
+	if awarded := user.FreezeAwardedOn; awarded != nil && awarded.Format(dayLayout) == day {
+		return nil
+	}
+
+	return tx.Exec(`
+		UPDATE langhelpercopy.users
+		SET streak_freezes = streak_freezes + 1, freeze_awarded_on = ?
+		WHERE id = ? AND streak_freezes < ? AND (freeze_awarded_on IS NULL OR freeze_awarded_on <> ?)
+	`, day, user.ID, models.MaxStreakFreezes, day).Error
+}
+
+// goalProgress возвращает выполнение цели за сегодня
+func (h studyHistory) goalProgress() GoalProgress {
+	streak, _ := reviewStreaks(h.Kept, h.Today)
+	done := goalDone(h.User, h.Days[h.Today.Format(dayLayout)])
+	return GoalProgress{
+		Type:    h.User.DailyGoalType,
+		Target:  h.User.DailyGoal,
+		Done:    done,
+		Met:     done >= h.User.DailyGoal,
+		Streak:  streak,
+		Freezes: h.User.StreakFreezes,
+	}
+}
+
+// GoalProgressHandler отдаёт выполнение дневной цели для шапки страницы
+func GoalProgressHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
+	history, err := loadStudyHistory(database.GetDB(), userID)
+	if err != nil {
+		log.Printf("Failed to load goal progress: %v", err)
+		http.Error(w, "Failed to load goal progress", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(history.goalProgress()); err != nil {
+		log.Printf("JSON encode error: %v", err)
+	}
+}
@@ -0,0 +1,367 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"langhelperCopy/config"
+	"langhelperCopy/database"
+	"langhelperCopy/models"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// Поля, к которым относятся значения записей журнала
+const (
+	historyFieldTranslation  = "translation"
+	historyFieldTitle        = "title"
+	historyFieldCode         = "code"
+	historyFieldPartOfSpeech = "part_of_speech"
+	historyFieldNotes        = "notes"
+)
+
+// recordHistory добавляет запись в журнал изменений
+func recordHistory(db *gorm.DB, e models.HistoryEntry) error {
+	return db.Exec(`
+		INSERT INTO langhelpercopy.history_entries
+			(user_id, entity_type, entity_id, lang_id, action, field, old_value, new_value, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, NOW())
+	`, e.UserID, e.EntityType, e.EntityID, e.LangID, e.Action, e.Field, e.OldValue, e.NewValue).Error
+}
+
+// recordTranslationChange записывает изменение основного перевода слова.
+// Действие определяется по значениям: пустое старое значение - перевод
+// добавлен, пустое новое - удалён. Неизменившийся перевод не записывается.
+func recordTranslationChange(db *gorm.DB, userID, wordID, langID uint, oldValue, newValue string) error {
+	if oldValue == newValue {
+		return nil
+	}
+	action := models.HistoryUpdate
+	if oldValue == "" {
+		action = models.HistoryCreate
+	} else if newValue == "" {
+		action = models.HistoryDelete
+	}
+	return recordHistory(db, models.HistoryEntry{
+		UserID:     userID,
+		EntityType: models.HistoryTranslation,
+		EntityID:   wordID,
+		LangID:     &langID,
+		Action:     action,
+		Field:      historyFieldTranslation,
+		OldValue:   oldValue,
+		NewValue:   newValue,
+	})
+}
+
+// recordEntityChange записывает создание, удаление или восстановление слова,
+// языка или колоды; title - название, под которым запись видна в журнале
+func recordEntityChange(db *gorm.DB, userID uint, entityType string, entityID uint, action, title string) error {
+	e := models.HistoryEntry{
+		UserID:     userID,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		Field:      historyFieldTitle,
+	}
+	if action == models.HistoryCreate {
+		e.NewValue = title
+	} else {
+		e.OldValue = title
+	}
+	return recordHistory(db, e)
+}
+
+// recordFieldChange записывает изменение одного поля слова, языка или колоды
+func recordFieldChange(db *gorm.DB, userID uint, entityType string, entityID uint, field, oldValue, newValue string) error {
+	if oldValue == newValue {
+		return nil
+	}
+	return recordHistory(db, models.HistoryEntry{
+		UserID:     userID,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     models.HistoryUpdate,
+		Field:      field,
+		OldValue:   oldValue,
+		NewValue:   newValue,
+	})
+}
+
+// HistoryRow - запись журнала на странице истории слова
+type HistoryRow struct {
+	models.HistoryEntry
+	LangTitle string
+	CanRevert bool
+}
+
+// What описывает, что изменилось
+func (h HistoryRow) What() string {
+	if h.EntityType == models.HistoryTranslation {
+		return "Translation (" + h.LangTitle + ")"
+	}
+	switch h.Field {
+	case historyFieldPartOfSpeech:
+		return "Part of speech"
+	case historyFieldNotes:
+		return "Notes"
+	}
+	return "Word"
+}
+
+type WordHistoryPageData struct {
+	Title        string
+	WordID       uint
+	WordLabel    string
+	Trashed      bool
+	Rows         []HistoryRow
+	ErrorMessage string
+}
+
+// WordHistoryHandler показывает историю изменений слова и его переводов
+func WordHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
+	wordID64, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid word ID", http.StatusBadRequest)
+		return
+	}
+	wordID := uint(wordID64)
+
+	db := database.GetDB()
+
+	var entries []models.HistoryEntry
+	err = db.Raw(`
+		SELECT * FROM langhelpercopy.history_entries
+		WHERE user_id = ? AND entity_id = ? AND entity_type IN (?, ?)
+		ORDER BY created_at DESC, id DESC
+	`, userID, wordID, models.HistoryWord, models.HistoryTranslation).Scan(&entries).Error
+	if err != nil {
+		log.Printf("Failed to load word history: %v", err)
+		http.Error(w, "Failed to load history", http.StatusInternalServerError)
+		return
+	}
+
+	labels, err := loadWordLabels(db, userID, []uint{wordID})
+	if err != nil {
+		log.Printf("Failed to load word: %v", err)
+		http.Error(w, "Failed to load history", http.StatusInternalServerError)
+		return
+	}
+	if len(entries) == 0 && labels[wordID] == "" {
+		http.Error(w, "Word not found", http.StatusNotFound)
+		return
+	}
+
+	// Названия нужны и для языков в корзине: история их не скрывает
+	var langs []models.UserLang
+	err = db.Raw("SELECT id, lang_title, deleted_at FROM langhelpercopy.user_langs WHERE user_id = ?", userID).Scan(&langs).Error
+	if err != nil {
+		log.Printf("Failed to load languages: %v", err)
+		http.Error(w, "Failed to load history", http.StatusInternalServerError)
+		return
+	}
+	langByID := make(map[uint]models.UserLang, len(langs))
+	for _, l := range langs {
+		langByID[l.ID] = l
+	}
+
+	var word models.Word
+	if err := db.Raw("SELECT id, deleted_at FROM langhelpercopy.words WHERE id = ?", wordID).Scan(&word).Error; err != nil {
+		log.Printf("Failed to load word: %v", err)
+		http.Error(w, "Failed to load history", http.StatusInternalServerError)
+		return
+	}
+	current, err := loadPrimaryTranslations(db, []uint{wordID})
+	if err != nil {
+		log.Printf("Failed to load translations: %v", err)
+		http.Error(w, "Failed to load history", http.StatusInternalServerError)
+		return
+	}
+
+	// Вернуть можно прежнее значение перевода, если слово и язык не в корзине
+	// и это значение не совпадает с текущим
+	trashed := word.ID == 0 || word.DeletedAt != nil
+	rows := make([]HistoryRow, len(entries))
+	for i, e := range entries {
+		rows[i] = HistoryRow{HistoryEntry: e}
+		if e.EntityType != models.HistoryTranslation || e.LangID == nil {
+			continue
+		}
+		lang, ok := langByID[*e.LangID]
+		rows[i].LangTitle = lang.LangTitle
+		if !ok {
+			rows[i].LangTitle = "deleted language"
+		}
+		rows[i].CanRevert = ok && !trashed && lang.DeletedAt == nil &&
+			e.OldValue != "" && e.OldValue != current[gridCellKey{wordID, *e.LangID}]
+	}
+
+	label := labels[wordID]
+	if label == "" {
+		label = fmt.Sprintf("Word #%d", wordID)
+	}
+
+	tmpl, err := template.ParseFiles("templates/layout.html", "templates/wordHistory.html")
+	if err != nil {
+		http.Error(w, "Error loading templates", http.StatusInternalServerError)
+		return
+	}
+	tmpl.ExecuteTemplate(w, "layout.html", WordHistoryPageData{
+		Title:        "Word History",
+		WordID:       wordID,
+		WordLabel:    label,
+		Trashed:      trashed,
+		Rows:         rows,
+		ErrorMessage: r.URL.Query().Get("error"),
+	})
+}
+
+// RevertTranslationHandler возвращает основному переводу слова прежнее
+// значение из записи журнала. Значение проверяется так же, как в таблице слов.
+func RevertTranslationHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
+	wordID64, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid word ID", http.StatusBadRequest)
+		return
+	}
+	wordID := uint(wordID64)
+	entryID, err := strconv.ParseUint(r.FormValue("entry_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid history entry", http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	var entry models.HistoryEntry
+	err = db.Raw(`
+		SELECT * FROM langhelpercopy.history_entries
+		WHERE id = ? AND user_id = ? AND entity_type = ? AND entity_id = ?
+	`, entryID, userID, models.HistoryTranslation, wordID).Scan(&entry).Error
+	if err != nil || entry.ID == 0 || entry.LangID == nil {
+		http.Error(w, "History entry not found", http.StatusNotFound)
+		return
+	}
+
+	historyURL := fmt.Sprintf("/word/%d/history", wordID)
+	err = database.UnitOfWork(db, func(tx *gorm.DB) error {
+		return revertTranslation(tx, userID, wordID, *entry.LangID, entry.OldValue)
+	})
+	var revertErr revertError
+	if errors.As(err, &revertErr) {
+		http.Redirect(w, r, historyURL+"?error="+url.QueryEscape(revertErr.Error()), http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to revert translation: %v", err)
+		http.Error(w, "Failed to revert translation", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, historyURL, http.StatusSeeOther)
+}
+
+// revertError - прежнее значение нельзя вернуть; текст показывается пользователю
+type revertError string
+
+func (e revertError) Error() string {
+	return string(e)
+}
+
+// revertTranslation записывает value основным переводом слова на язык langID
+func revertTranslation(tx *gorm.DB, userID, wordID, langID uint, value string) error {
+	if value == "" {
+		return revertError("this entry has no earlier value to return to")
+	}
+	if _, err := parseOwnLangID(tx, userID, strconv.FormatUint(uint64(langID), 10)); err != nil {
+		return revertError("the language of this translation is in the Trash or was deleted")
+	}
+
+	var owned int64
+	err := tx.Raw(`
+		SELECT COUNT(*) FROM langhelpercopy.words w
+		WHERE w.id = ? AND w.deleted_at IS NULL AND w.`+trashTables[trashWord].Owner,
+		wordID, userID,
+	).Scan(&owned).Error
+	if err != nil {
+		return err
+	}
+	if owned == 0 {
+		return revertError("this word is in the Trash or was deleted")
+	}
+	if _, err := loadWordVersions(tx, []uint{wordID}, true); err != nil {
+		return err
+	}
+
+	key := gridCellKey{wordID, langID}
+	current, err := loadPrimaryTranslations(tx, []uint{wordID})
+	if err != nil {
+		return err
+	}
+	old, exists := current[key]
+	if old == value {
+		return nil
+	}
+	alternatives, err := loadAlternativeKeys(tx, []uint{wordID})
+	if err != nil {
+		return err
+	}
+	if alternatives[key][strings.ToLower(value)] {
+		return revertError(fmt.Sprintf("%q is now an alternative translation of this word", value))
+	}
+	duplicates, err := findGridDuplicates(tx, []gridCellKey{key}, map[gridCellKey]string{key: value})
+	if err != nil {
+		return err
+	}
+	if other, ok := duplicates[key]; ok {
+		return revertError(fmt.Sprintf("%q is now used by word #%d", value, other))
+	}
+
+	if exists {
+		err = tx.Exec(`
+			UPDATE langhelpercopy.user_words SET translation = ?, updated_at = NOW(), version = version + 1
+			WHERE word_id = ? AND lang_id = ? AND is_primary
+		`, value, wordID, langID).Error
+	} else {
+		err = tx.Exec(`
+			INSERT INTO langhelpercopy.user_words (lang_id, word_id, translation, is_primary)
+			VALUES (?, ?, ?, TRUE)
+		`, langID, wordID, value).Error
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := bumpWordVersions(tx, []uint{wordID}); err != nil {
+		return err
+	}
+	return recordTranslationChange(tx, userID, wordID, langID, old, value)
+}
@@ -1,18 +1,34 @@
 package routes
 
 import (
+	"errors"
+	"fmt"
 	"html/template"
 	"langhelperCopy/config"
 	"langhelperCopy/database"
 	"langhelperCopy/models"
+	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
 )
 
 type LangPageData struct {
-	Title     string
-	Languages []models.UserLang
-	EditID    int
+	Title        string
+	Languages    []models.UserLang
+	Catalogue    []models.Language
+	MainLangID   uint
+	UserVersion  int // версия пользователя для формы выбора основного языка
+	EditID       int
+	ErrorMessage string
+}
+
+// IsLast сообщает, что язык с индексом i - последняя колонка
+func (d LangPageData) IsLast(i int) bool {
+	return i == len(d.Languages)-1
 }
 
 func LanguagesHandler(w http.ResponseWriter, r *http.Request) {
@@ -37,63 +53,196 @@ func LanguagesHandler(w http.ResponseWriter, r *http.Request) {
 		editID = tmp
 	}
 
+	errorMessage := r.URL.Query().Get("error")
 	if r.Method == http.MethodPost {
 		r.ParseForm()
-		langtitle := r.FormValue("langtitle")
-		if langtitle != "" {
-			result := db.Exec("INSERT INTO langhelpercopy.user_langs (user_id, lang_title) VALUES (?, ?)", userID, langtitle)
-			if result.Error != nil {
+		code, title, err := parseLangForm(r)
+		if err == nil {
+			err = checkLangCodeFree(db, userID, code, 0)
+		}
+		if err == nil {
+			// Новый язык добавляется последней колонкой
+			err := database.UnitOfWork(db, func(tx *gorm.DB) error {
+				var newID uint
+				err := tx.Raw(`
+					INSERT INTO langhelpercopy.user_langs (user_id, lang_title, lang_code, position)
+					SELECT ?, ?, ?, COALESCE(MAX(position), 0) + 1
+					FROM langhelpercopy.user_langs WHERE user_id = ?
+					RETURNING id
+				`, userID, title, code, userID).Scan(&newID).Error
+				if err != nil {
+					return err
+				}
+				return recordEntityChange(tx, userID, models.HistoryLanguage, newID, models.HistoryCreate, title)
+			})
+			if err != nil {
+				log.Printf("Failed to insert language: %v", err)
 				http.Error(w, "Error inserting language", http.StatusInternalServerError)
 				return
 			}
 			http.Redirect(w, r, "/mylanguages", http.StatusSeeOther)
 			return
 		}
+		errorMessage = err.Error()
 	}
 
-	rows, err := db.Raw("SELECT id, user_id, lang_title FROM langhelpercopy.user_langs WHERE user_id = ?", userID).Rows()
+	languages, err := loadUserLangs(db, userID)
 	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var languages []models.UserLang
-	for rows.Next() {
-		var lang models.UserLang
-		err := rows.Scan(&lang.ID, &lang.UserID, &lang.LangTitle)
-		if err != nil {
-			http.Error(w, "Scan error", http.StatusInternalServerError)
-			return
-		}
-		languages = append(languages, lang)
+	mainLangID, err := userMainLangID(db, userID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	var userVersion int
+	if err := db.Raw("SELECT version FROM langhelpercopy.users WHERE id = ?", userID).Scan(&userVersion).Error; err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
 	}
 
 	tmpl, _ := template.ParseFiles("templates/layout.html", "templates/mylanguages.html")
 	tmpl.ExecuteTemplate(w, "layout.html", LangPageData{
-		Title:     "My Languages",
-		Languages: languages,
-		EditID:    editID,
+		Title:        "My Languages",
+		Languages:    languages,
+		Catalogue:    models.LanguageCatalogue,
+		MainLangID:   mainLangID,
+		UserVersion:  userVersion,
+		EditID:       editID,
+		ErrorMessage: errorMessage,
 	})
 }
 
+// parseLangForm читает язык из формы: код из каталога и необязательную
+// подпись. Язык не из каталога задаётся только подписью.
+func parseLangForm(r *http.Request) (*string, string, error) {
+	label := strings.TrimSpace(r.FormValue("label"))
+	if err := models.ValidateLangLabel(label); err != nil {
+		return nil, "", err
+	}
+
+	code := strings.TrimSpace(r.FormValue("lang_code"))
+	if code == "" {
+		if label == "" {
+			return nil, "", errors.New("choose a language or enter a name")
+		}
+		return nil, label, nil
+	}
+
+	lang, ok := models.LanguageByCode(code)
+	if !ok {
+		return nil, "", errors.New("unknown language")
+	}
+	if label == "" {
+		label = lang.EnglishName
+	}
+	return &lang.Code, label, nil
+}
+
+// checkLangCodeFree проверяет, что пользователь ещё не добавил этот язык каталога.
+// Язык в корзине тоже занимает код: его нужно восстановить, а не добавлять заново.
+func checkLangCodeFree(db *gorm.DB, userID uint, code *string, exceptID int) error {
+	if code == nil {
+		return nil
+	}
+	var existing []models.UserLang
+	err := db.Raw(`
+		SELECT * FROM langhelpercopy.user_langs
+		WHERE user_id = ? AND lang_code = ? AND id <> ?
+	`, userID, *code, exceptID).Scan(&existing).Error
+	if err != nil {
+		return err
+	}
+	for _, l := range existing {
+		if l.DeletedAt != nil {
+			return errors.New("this language is in the Trash - restore it instead")
+		}
+		return errors.New("you already have this language")
+	}
+	return nil
+}
+
 func EditLanguageHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Redirect(w, r, "/mylanguages", http.StatusSeeOther)
 		return
 	}
 
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
 	db := database.GetDB()
 
 	idStr := r.URL.Path[len("/mylanguages/edit/"):]
 	id, _ := strconv.Atoi(idStr)
-	newTitle := r.FormValue("newtitle")
 
-	result := db.Exec("UPDATE langhelpercopy.user_langs SET lang_title = ? WHERE id = ?", newTitle, id)
-	if result.Error != nil {
+	code, title, err := parseLangForm(r)
+	if err == nil {
+		err = checkLangCodeFree(db, userID, code, id)
+	}
+	if err != nil {
+		http.Redirect(w, r, fmt.Sprintf("/mylanguages?edit=%d&error=%s", id, url.QueryEscape(err.Error())), http.StatusSeeOther)
+		return
+	}
+	version, err := formVersion(r)
+	if err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	found := false
+	err = database.UnitOfWork(db, func(tx *gorm.DB) error {
+		var old models.UserLang
+		if err := tx.Raw("SELECT * FROM langhelpercopy.user_langs WHERE id = ? AND user_id = ? AND deleted_at IS NULL FOR UPDATE", id, userID).Scan(&old).Error; err != nil {
+			return err
+		}
+		if old.ID == 0 {
+			return nil
+		}
+		found = true
+		if old.Version != version {
+			return errStaleVersion
+		}
+
+		err := tx.Exec(`
+			UPDATE langhelpercopy.user_langs SET lang_title = ?, lang_code = ?, updated_at = NOW(), version = version + 1
+			WHERE id = ?
+		`, title, code, id).Error
+		if err != nil {
+			return err
+		}
+		newCode := ""
+		if code != nil {
+			newCode = *code
+		}
+		if err := recordFieldChange(tx, userID, models.HistoryLanguage, old.ID, historyFieldTitle, old.LangTitle, title); err != nil {
+			return err
+		}
+		return recordFieldChange(tx, userID, models.HistoryLanguage, old.ID, historyFieldCode, old.Code(), newCode)
+	})
+	if errors.Is(err, errStaleVersion) {
+		renderConflict(w, "This language", fmt.Sprintf("/mylanguages?edit=%d", id))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to update language: %v", err)
 		http.Error(w, "Error updating language", http.StatusInternalServerError)
 		return
 	}
+	if !found {
+		http.Error(w, "Language not found", http.StatusNotFound)
+		return
+	}
 	http.Redirect(w, r, "/mylanguages", http.StatusSeeOther)
 }
 
@@ -103,16 +252,38 @@ func DeleteLanguageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
 	db := database.GetDB()
 
 	idStr := r.URL.Path[len("/mylanguages/delete/"):]
 	id, _ := strconv.Atoi(idStr)
 
-	_ = db.Exec("DELETE FROM langhelpercopy.user_words WHERE lang_id = ?", id)
-	_ = db.Exec("DELETE FROM langhelpercopy.deck_langs WHERE lang_id = ?", id)
-
-	result := db.Exec("DELETE FROM langhelpercopy.user_langs WHERE id = ?", id)
-	if result.Error != nil {
+	// Язык переносится в корзину вместе с переводами: они скрываются
+	// во всех списках и возвращаются при восстановлении
+	err = database.UnitOfWork(db, func(tx *gorm.DB) error {
+		var title string
+		if err := tx.Raw("SELECT lang_title FROM langhelpercopy.user_langs WHERE id = ? AND user_id = ?", id, userID).Scan(&title).Error; err != nil {
+			return err
+		}
+		result := tx.Exec("UPDATE langhelpercopy.user_langs SET deleted_at = NOW() WHERE id = ? AND user_id = ? AND deleted_at IS NULL", id, userID)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return recordEntityChange(tx, userID, models.HistoryLanguage, uint(id), models.HistoryDelete, title)
+	})
+	if err != nil {
+		log.Printf("Failed to delete language: %v", err)
 		http.Error(w, "Error deleting language", http.StatusInternalServerError)
 		return
 	}
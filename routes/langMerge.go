@@ -0,0 +1,493 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"langhelperCopy/config"
+	"langhelperCopy/database"
+	"langhelperCopy/models"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Как разрешать конфликт, когда у слова есть переводы на оба объединяемых языка
+const (
+	mergeKeepTarget = "target" // оставить переводы языка, в который объединяем
+	mergeKeepSource = "source" // оставить переводы объединяемого языка
+	mergeSynonyms   = "both"   // оставить все, переводы source станут синонимами
+	mergeAsk        = "ask"    // выбрать для каждого слова отдельно
+)
+
+// MergeConflict - слово с разными переводами на оба языка
+type MergeConflict struct {
+	WordID uint
+	Source []string
+	Target []string
+}
+
+// MergePreview - что изменится при объединении языка Source с языком Target
+type MergePreview struct {
+	Source     models.UserLang
+	Target     models.UserLang
+	Moving     int // слова, у которых есть перевод только на Source
+	Duplicates int // слова с одинаковыми переводами на оба языка
+	Conflicts  []MergeConflict
+	Decks      int
+	Reviews    int
+}
+
+type MergeLanguagesPageData struct {
+	Title        string
+	Preview      MergePreview
+	Strategy     string
+	ErrorMessage string
+}
+
+// MergeLanguagesHandler показывает, что произойдёт при объединении двух языков
+// пользователя, а после подтверждения выполняет объединение.
+func MergeLanguagesHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
+	r.ParseForm()
+	sourceID, _ := strconv.Atoi(r.FormValue("source"))
+	targetID, _ := strconv.Atoi(r.FormValue("target"))
+	strategy := r.FormValue("strategy")
+	if strategy == "" {
+		strategy = mergeAsk
+	}
+
+	db := database.GetDB()
+
+	preview, err := loadMergePreview(db, userID, uint(sourceID), uint(targetID))
+	if err != nil {
+		http.Redirect(w, r, "/mylanguages?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
+
+	errorMessage := ""
+	if r.Method == http.MethodPost {
+		err = database.UnitOfWork(db, func(tx *gorm.DB) error {
+			return mergeLanguages(tx, userID, preview.Source.ID, preview.Target.ID, strategy, r.Form)
+		})
+		if err == nil {
+			http.Redirect(w, r, "/mylanguages", http.StatusSeeOther)
+			return
+		}
+		var choiceErr mergeChoiceError
+		if !errors.As(err, &choiceErr) {
+			log.Printf("Failed to merge languages %d into %d: %v", preview.Source.ID, preview.Target.ID, err)
+			http.Error(w, "Error merging languages", http.StatusInternalServerError)
+			return
+		}
+		// Пока пользователь смотрел превью, переводы могли измениться
+		errorMessage = err.Error()
+		if preview, err = loadMergePreview(db, userID, preview.Source.ID, preview.Target.ID); err != nil {
+			http.Redirect(w, r, "/mylanguages?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+			return
+		}
+	}
+
+	tmpl, err := template.ParseFiles("templates/layout.html", "templates/langMerge.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = tmpl.ExecuteTemplate(w, "layout.html", MergeLanguagesPageData{
+		Title:        "Merge Languages",
+		Preview:      preview,
+		Strategy:     strategy,
+		ErrorMessage: errorMessage,
+	})
+	if err != nil {
+		log.Printf("Template execution error: %v", err)
+	}
+}
+
+// mergeChoiceError - для слова с конфликтом не выбран вариант
+type mergeChoiceError struct {
+	WordID uint
+}
+
+func (e mergeChoiceError) Error() string {
+	return fmt.Sprintf("choose which translations to keep for every conflicting word (word %d)", e.WordID)
+}
+
+// loadMergeLangs возвращает оба языка, проверяя, что они принадлежат пользователю
+func loadMergeLangs(db *gorm.DB, userID, sourceID, targetID uint) (models.UserLang, models.UserLang, error) {
+	var source, target models.UserLang
+	if sourceID == targetID {
+		return source, target, errors.New("choose two different languages to merge")
+	}
+
+	var langs []models.UserLang
+	err := db.Raw(`
+		SELECT id, user_id, lang_title, lang_code FROM langhelpercopy.user_langs
+		WHERE id IN (?, ?) AND user_id = ? AND deleted_at IS NULL
+	`, sourceID, targetID, userID).Scan(&langs).Error
+	if err != nil {
+		return source, target, err
+	}
+	for _, l := range langs {
+		if l.ID == sourceID {
+			source = l
+		} else {
+			target = l
+		}
+	}
+	if source.ID == 0 || target.ID == 0 {
+		return source, target, errors.New("language not found")
+	}
+	return source, target, nil
+}
+
+// loadMergeOverlap возвращает переводы слов, у которых есть переводы на оба языка,
+// разделяя их на конфликты и полные совпадения
+func loadMergeOverlap(db *gorm.DB, sourceID, targetID uint) (conflicts []MergeConflict, duplicates []uint, err error) {
+	var rows []struct {
+		WordID      uint
+		LangID      uint
+		Translation string
+	}
+	err = db.Raw(`
+		SELECT word_id, lang_id, translation
+		FROM langhelpercopy.user_words
+		WHERE lang_id IN (?, ?) AND word_id IN (
+			SELECT word_id FROM langhelpercopy.user_words WHERE lang_id = ?
+			INTERSECT
+			SELECT word_id FROM langhelpercopy.user_words WHERE lang_id = ?
+		)
+		ORDER BY word_id, is_primary DESC, id
+	`, sourceID, targetID, sourceID, targetID).Scan(&rows).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var current *MergeConflict
+	for _, row := range rows {
+		if current == nil || current.WordID != row.WordID {
+			conflicts = append(conflicts, MergeConflict{WordID: row.WordID})
+			current = &conflicts[len(conflicts)-1]
+		}
+		if row.LangID == sourceID {
+			current.Source = append(current.Source, row.Translation)
+		} else {
+			current.Target = append(current.Target, row.Translation)
+		}
+	}
+
+	// Если все переводы source уже есть у target, выбирать нечего
+	real := conflicts[:0]
+	for _, c := range conflicts {
+		if translationsCovered(c.Source, c.Target) {
+			duplicates = append(duplicates, c.WordID)
+		} else {
+			real = append(real, c)
+		}
+	}
+	return real, duplicates, nil
+}
+
+// translationsCovered сообщает, есть ли каждый перевод из sub среди all без учёта регистра
+func translationsCovered(sub, all []string) bool {
+	known := make(map[string]bool, len(all))
+	for _, t := range all {
+		known[strings.ToLower(strings.TrimSpace(t))] = true
+	}
+	for _, t := range sub {
+		if !known[strings.ToLower(strings.TrimSpace(t))] {
+			return false
+		}
+	}
+	return true
+}
+
+// loadMergePreview подсчитывает последствия объединения, ничего не меняя
+func loadMergePreview(db *gorm.DB, userID, sourceID, targetID uint) (MergePreview, error) {
+	var preview MergePreview
+	var err error
+	preview.Source, preview.Target, err = loadMergeLangs(db, userID, sourceID, targetID)
+	if err != nil {
+		return preview, err
+	}
+
+	var duplicates []uint
+	if preview.Conflicts, duplicates, err = loadMergeOverlap(db, sourceID, targetID); err != nil {
+		return preview, err
+	}
+	preview.Duplicates = len(duplicates)
+
+	var counts struct {
+		Moving  int
+		Decks   int
+		Reviews int
+	}
+	err = db.Raw(`
+		SELECT
+			(SELECT COUNT(DISTINCT word_id) FROM langhelpercopy.user_words
+				WHERE lang_id = ? AND word_id NOT IN (
+					SELECT word_id FROM langhelpercopy.user_words WHERE lang_id = ?)) AS moving,
+			(SELECT COUNT(*) FROM langhelpercopy.deck_langs WHERE lang_id = ?) AS decks,
+			(SELECT COUNT(*) FROM langhelpercopy.reviews WHERE lang_id = ?) AS reviews
+	`, sourceID, targetID, sourceID, sourceID).Scan(&counts).Error
+	if err != nil {
+		return preview, err
+	}
+	preview.Moving = counts.Moving
+	preview.Decks = counts.Decks
+	preview.Reviews = counts.Reviews
+	return preview, nil
+}
+
+// mergeLanguages переносит всё, что связано с языком sourceID, в язык targetID
+// и удаляет sourceID. Вызывается внутри транзакции: конфликты пересчитываются
+// заново, и если для какого-то из них нет выбора, ничего не меняется.
+func mergeLanguages(tx *gorm.DB, userID, sourceID, targetID uint, strategy string, form url.Values) error {
+	var locked []uint
+	err := tx.Raw(`
+		SELECT id FROM langhelpercopy.user_langs WHERE id IN (?, ?) AND user_id = ? FOR UPDATE
+	`, sourceID, targetID, userID).Scan(&locked).Error
+	if err != nil {
+		return err
+	}
+	source, target, err := loadMergeLangs(tx, userID, sourceID, targetID)
+	if err != nil {
+		return err
+	}
+
+	conflicts, duplicates, err := loadMergeOverlap(tx, sourceID, targetID)
+	if err != nil {
+		return err
+	}
+
+	var keepSource, keepTarget, synonyms []uint
+	var replaced []MergeConflict
+	synonyms = append(synonyms, duplicates...)
+	for _, c := range conflicts {
+		choice := strategy
+		if strategy == mergeAsk {
+			choice = form.Get(fmt.Sprintf("word_%d", c.WordID))
+		}
+		switch choice {
+		case mergeKeepSource:
+			keepSource = append(keepSource, c.WordID)
+			replaced = append(replaced, c)
+		case mergeKeepTarget:
+			keepTarget = append(keepTarget, c.WordID)
+		case mergeSynonyms:
+			synonyms = append(synonyms, c.WordID)
+		default:
+			return mergeChoiceError{WordID: c.WordID}
+		}
+	}
+
+	if len(keepTarget) > 0 {
+		err := tx.Exec("DELETE FROM langhelpercopy.user_words WHERE lang_id = ? AND word_id IN (?)", sourceID, keepTarget).Error
+		if err != nil {
+			return err
+		}
+	}
+	if len(keepSource) > 0 {
+		err := tx.Exec("DELETE FROM langhelpercopy.user_words WHERE lang_id = ? AND word_id IN (?)", targetID, keepSource).Error
+		if err != nil {
+			return err
+		}
+		// Прогресс изучения остаётся от тех переводов, которые сохранились
+		err = tx.Exec("DELETE FROM langhelpercopy.card_progresses WHERE lang_id = ? AND word_id IN (?)", targetID, keepSource).Error
+		if err != nil {
+			return err
+		}
+	}
+	if len(synonyms) > 0 {
+		if err := mergeAsSynonyms(tx, sourceID, targetID, synonyms); err != nil {
+			return err
+		}
+	}
+
+	statements := []string{
+		// Переводы переходят к другому языку - открытые формы этих слов устаревают
+		`UPDATE langhelpercopy.words SET version = version + 1
+			WHERE id IN (SELECT word_id FROM langhelpercopy.user_words WHERE lang_id = @source)`,
+		"UPDATE langhelpercopy.user_words SET lang_id = @target, updated_at = NOW(), version = version + 1 WHERE lang_id = @source",
+		`DELETE FROM langhelpercopy.word_examples s
+			USING langhelpercopy.word_examples t
+			WHERE s.lang_id = @source AND t.lang_id = @target
+				AND t.word_id = s.word_id AND t.sentence = s.sentence`,
+		"UPDATE langhelpercopy.word_examples SET lang_id = @target WHERE lang_id = @source",
+		`DELETE FROM langhelpercopy.card_progresses s
+			USING langhelpercopy.card_progresses t
+			WHERE s.lang_id = @source AND t.lang_id = @target
+				AND t.user_id = s.user_id AND t.word_id = s.word_id`,
+		"UPDATE langhelpercopy.card_progresses SET lang_id = @target WHERE lang_id = @source",
+		"UPDATE langhelpercopy.reviews SET lang_id = @target WHERE lang_id = @source",
+		"UPDATE langhelpercopy.study_cards SET lang_id = @target WHERE lang_id = @source",
+		"UPDATE langhelpercopy.study_sessions SET main_lang_id = @target WHERE main_lang_id = @source",
+		"UPDATE langhelpercopy.quiz_preferences SET main_lang_id = @target WHERE main_lang_id = @source",
+		"UPDATE langhelpercopy.users SET main_lang_id = @target, updated_at = NOW(), version = version + 1 WHERE main_lang_id = @source",
+		"UPDATE langhelpercopy.decks SET prompt_lang_id = @target, updated_at = NOW(), version = version + 1 WHERE prompt_lang_id = @source",
+		`DELETE FROM langhelpercopy.deck_langs s
+			USING langhelpercopy.deck_langs t
+			WHERE s.lang_id = @source AND t.lang_id = @target AND t.deck_id = s.deck_id`,
+		"UPDATE langhelpercopy.deck_langs SET lang_id = @target, updated_at = NOW(), version = version + 1 WHERE lang_id = @source",
+	}
+	args := map[string]interface{}{"source": sourceID, "target": targetID}
+	for _, stmt := range statements {
+		if err := tx.Exec(stmt, args).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := mergeQuizTargets(tx, userID, sourceID, targetID); err != nil {
+		return err
+	}
+	if err := mergeSmartRuleLangs(tx, userID, sourceID, targetID); err != nil {
+		return err
+	}
+
+	if err := tx.Exec("DELETE FROM langhelpercopy.user_langs WHERE id = ?", sourceID).Error; err != nil {
+		return err
+	}
+	// Код каталога переходит к оставшемуся языку, если у него своего не было
+	if target.LangCode == nil && source.LangCode != nil {
+		err := tx.Exec(`
+			UPDATE langhelpercopy.user_langs SET lang_code = ?, updated_at = NOW(), version = version + 1
+			WHERE id = ?
+		`, *source.LangCode, targetID).Error
+		if err != nil {
+			return err
+		}
+		if err := recordFieldChange(tx, userID, models.HistoryLanguage, targetID, historyFieldCode, "", *source.LangCode); err != nil {
+			return err
+		}
+	}
+
+	// В журнал попадают удаление source и основные переводы target, которые
+	// заменены переводами source
+	for _, c := range replaced {
+		if len(c.Source) == 0 || len(c.Target) == 0 {
+			continue
+		}
+		if err := recordTranslationChange(tx, userID, c.WordID, targetID, c.Target[0], c.Source[0]); err != nil {
+			return err
+		}
+	}
+	return recordEntityChange(tx, userID, models.HistoryLanguage, sourceID, models.HistoryDelete, source.LangTitle)
+}
+
+// mergeAsSynonyms оставляет у слов переводы обоих языков: переводы source,
+// которых ещё нет у target, становятся синонимами, а заметки основного
+// перевода source заполняют пустые поля основного перевода target.
+func mergeAsSynonyms(tx *gorm.DB, sourceID, targetID uint, wordIDs []uint) error {
+	err := tx.Exec(`
+		UPDATE langhelpercopy.user_words t SET
+			gender = COALESCE(NULLIF(t.gender, ''), s.gender),
+			pronunciation = COALESCE(NULLIF(t.pronunciation, ''), s.pronunciation),
+			notes = COALESCE(NULLIF(t.notes, ''), s.notes),
+			updated_at = NOW(),
+			version = t.version + 1
+		FROM langhelpercopy.user_words s
+		WHERE t.lang_id = ? AND t.is_primary AND s.lang_id = ? AND s.is_primary
+			AND s.word_id = t.word_id AND t.word_id IN (?)
+	`, targetID, sourceID, wordIDs).Error
+	if err != nil {
+		return err
+	}
+
+	err = tx.Exec(`
+		DELETE FROM langhelpercopy.user_words s
+		USING langhelpercopy.user_words t
+		WHERE s.lang_id = ? AND t.lang_id = ? AND s.word_id IN (?)
+			AND t.word_id = s.word_id
+			AND LOWER(TRIM(t.translation)) = LOWER(TRIM(s.translation))
+	`, sourceID, targetID, wordIDs).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Exec(`
+		UPDATE langhelpercopy.user_words
+		SET is_primary = false, gender = '', pronunciation = '', notes = '', updated_at = NOW(), version = version + 1
+		WHERE lang_id = ? AND word_id IN (?)
+	`, sourceID, wordIDs).Error
+}
+
+// mergeQuizTargets заменяет язык в сохранённых настройках тренировок
+func mergeQuizTargets(tx *gorm.DB, userID, sourceID, targetID uint) error {
+	var prefs []models.QuizPreference
+	err := tx.Raw("SELECT * FROM langhelpercopy.quiz_preferences WHERE user_id = ?", userID).Scan(&prefs).Error
+	if err != nil {
+		return err
+	}
+	for _, pref := range prefs {
+		if !pref.HasTarget(sourceID) {
+			continue
+		}
+		pref.SetTargets(replaceLangID(pref.Targets(), sourceID, targetID))
+		err := tx.Exec("UPDATE langhelpercopy.quiz_preferences SET target_lang_ids = ? WHERE id = ?", pref.TargetLangIDs, pref.ID).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeSmartRuleLangs заменяет язык в правилах умных колод
+func mergeSmartRuleLangs(tx *gorm.DB, userID, sourceID, targetID uint) error {
+	var decks []models.Deck
+	err := tx.Raw("SELECT * FROM langhelpercopy.decks WHERE user_id = ? AND smart_rule <> ''", userID).Scan(&decks).Error
+	if err != nil {
+		return err
+	}
+	for _, deck := range decks {
+		rule, err := models.ParseSmartRule(deck.SmartRule)
+		if err != nil {
+			return fmt.Errorf("invalid smart rule for deck %d: %w", deck.ID, err)
+		}
+		if !containsID(rule.LangIDs, sourceID) {
+			continue
+		}
+		rule.LangIDs = replaceLangID(rule.LangIDs, sourceID, targetID)
+		raw, err := rule.Encode()
+		if err != nil {
+			return err
+		}
+		err = tx.Exec("UPDATE langhelpercopy.decks SET smart_rule = ?, updated_at = NOW(), version = version + 1 WHERE id = ?", raw, deck.ID).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceLangID заменяет from на to, не допуская повторов
+func replaceLangID(ids []uint, from, to uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	var result []uint
+	for _, id := range ids {
+		if id == from {
+			id = to
+		}
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,307 @@
+package routes
+
+import (
+	"errors"
+	"langhelperCopy/config"
+	"langhelperCopy/database"
+	"langhelperCopy/models"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// loadUserLangs возвращает языки пользователя в выбранном им порядке колонок
+func loadUserLangs(db *gorm.DB, userID uint) ([]models.UserLang, error) {
+	var langs []models.UserLang
+	err := db.Raw(`
+		SELECT id, user_id, lang_title, lang_code, position, version
+		FROM langhelpercopy.user_langs
+		WHERE user_id = ? AND deleted_at IS NULL
+		ORDER BY position, id
+	`, userID).Scan(&langs).Error
+	return langs, err
+}
+
+// loadDeckLangs возвращает языки колоды в порядке колонок колоды
+func loadDeckLangs(db *gorm.DB, deckID uint) ([]models.UserLang, error) {
+	var langs []models.UserLang
+	err := db.Raw(`
+		SELECT l.id, l.user_id, l.lang_title, l.lang_code, l.position
+		FROM langhelpercopy.deck_langs dl
+		JOIN langhelpercopy.user_langs l ON dl.lang_id = l.id
+		WHERE dl.deck_id = ? AND l.deleted_at IS NULL
+		ORDER BY dl.position, l.position, l.id
+	`, deckID).Scan(&langs).Error
+	return langs, err
+}
+
+// userMainLangID возвращает родной язык пользователя, 0 если он не выбран
+func userMainLangID(db *gorm.DB, userID uint) (uint, error) {
+	var mainLangID *uint
+	err := db.Raw(`
+		SELECT u.main_lang_id FROM langhelpercopy.users u
+		JOIN langhelpercopy.user_langs l ON l.id = u.main_lang_id
+		WHERE u.id = ? AND l.deleted_at IS NULL
+	`, userID).Scan(&mainLangID).Error
+	if err != nil || mainLangID == nil {
+		return 0, err
+	}
+	return *mainLangID, nil
+}
+
+// defaultPromptLangID выбирает язык вопросов для тренировки по колоде:
+// из прошлой тренировки, затем язык колоды по умолчанию, затем родной язык
+// пользователя. Годится только язык, который есть в колоде.
+func defaultPromptLangID(deck models.Deck, pref *models.QuizPreference, userMainLang uint, deckLangs []models.DeckLang) uint {
+	candidates := []uint{}
+	if pref != nil {
+		candidates = append(candidates, pref.MainLangID)
+	}
+	if deck.PromptLangID != nil {
+		candidates = append(candidates, *deck.PromptLangID)
+	}
+	candidates = append(candidates, userMainLang)
+	for _, id := range candidates {
+		for _, dl := range deckLangs {
+			if dl.LangID == id {
+				return id
+			}
+		}
+	}
+	if len(deckLangs) > 0 {
+		return deckLangs[0].LangID
+	}
+	return 0
+}
+
+// moveID сдвигает id на одну позицию вверх или вниз
+func moveID(ids []uint, id uint, up bool) []uint {
+	for i, v := range ids {
+		if v != id {
+			continue
+		}
+		j := i + 1
+		if up {
+			j = i - 1
+		}
+		if j >= 0 && j < len(ids) {
+			ids[i], ids[j] = ids[j], ids[i]
+		}
+		break
+	}
+	return ids
+}
+
+// MoveLanguageHandler сдвигает язык в порядке колонок пользователя
+func MoveLanguageHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
+	langID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid language ID", http.StatusBadRequest)
+		return
+	}
+	up := r.FormValue("dir") == "up"
+
+	db := database.GetDB()
+	err = database.UnitOfWork(db, func(tx *gorm.DB) error {
+		var ids []uint
+		err := tx.Raw(`
+			SELECT id FROM langhelpercopy.user_langs WHERE user_id = ? AND deleted_at IS NULL
+			ORDER BY position, id FOR UPDATE
+		`, userID).Scan(&ids).Error
+		if err != nil {
+			return err
+		}
+		for i, id := range moveID(ids, uint(langID), up) {
+			err := tx.Exec("UPDATE langhelpercopy.user_langs SET position = ?, updated_at = NOW() WHERE id = ? AND position <> ?", i+1, id, i+1).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to reorder languages: %v", err)
+		http.Error(w, "Error reordering languages", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/mylanguages", http.StatusSeeOther)
+}
+
+// MainLanguageHandler выбирает родной язык пользователя; пустое значение сбрасывает выбор
+func MainLanguageHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
+	version, err := formVersion(r)
+	if err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+
+	var mainLangID *uint
+	if raw := r.FormValue("lang_id"); raw != "" {
+		id, err := parseOwnLangID(db, userID, raw)
+		if err != nil {
+			http.Redirect(w, r, "/mylanguages?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+			return
+		}
+		mainLangID = &id
+	}
+
+	// Кнопка переключает выбор, поэтому при устаревшей странице можно было бы
+	// сбросить язык, выбранный в другой вкладке
+	result := db.Exec(`
+		UPDATE langhelpercopy.users SET main_lang_id = ?, updated_at = NOW(), version = version + 1
+		WHERE id = ? AND version = ?
+	`, mainLangID, userID, version)
+	if result.Error != nil {
+		log.Printf("Failed to save main language: %v", result.Error)
+		http.Error(w, "Error saving main language", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		renderConflict(w, "Your main language", "/mylanguages")
+		return
+	}
+	http.Redirect(w, r, "/mylanguages", http.StatusSeeOther)
+}
+
+// parseOwnLangID разбирает ID языка и проверяет, что язык принадлежит пользователю
+func parseOwnLangID(db *gorm.DB, userID uint, raw string) (uint, error) {
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid language")
+	}
+	var count int64
+	err = db.Raw("SELECT COUNT(*) FROM langhelpercopy.user_langs WHERE id = ? AND user_id = ? AND deleted_at IS NULL", id, userID).Scan(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, errors.New("language not found")
+	}
+	return uint(id), nil
+}
+
+// MoveDeckLangHandler сдвигает язык в порядке колонок колоды
+func MoveDeckLangHandler(w http.ResponseWriter, r *http.Request) {
+	deck, _, ok := deckRequest(w, r)
+	if !ok {
+		return
+	}
+
+	langID, err := strconv.ParseUint(r.FormValue("lang_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid language ID", http.StatusBadRequest)
+		return
+	}
+	up := r.FormValue("dir") == "up"
+
+	db := database.GetDB()
+	err = database.UnitOfWork(db, func(tx *gorm.DB) error {
+		var ids []uint
+		err := tx.Raw(`
+			SELECT dl.lang_id
+			FROM langhelpercopy.deck_langs dl
+			JOIN langhelpercopy.user_langs l ON dl.lang_id = l.id
+			WHERE dl.deck_id = ? AND l.deleted_at IS NULL
+			ORDER BY dl.position, l.position, l.id
+			FOR UPDATE OF dl
+		`, deck.ID).Scan(&ids).Error
+		if err != nil {
+			return err
+		}
+		for i, id := range moveID(ids, uint(langID), up) {
+			err := tx.Exec(`
+				UPDATE langhelpercopy.deck_langs SET position = ?, updated_at = NOW()
+				WHERE deck_id = ? AND lang_id = ? AND position <> ?
+			`, i+1, deck.ID, id, i+1).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to reorder deck languages: %v", err)
+		http.Error(w, "Error reordering languages", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/deck/"+strconv.FormatUint(uint64(deck.ID), 10), http.StatusSeeOther)
+}
+
+// DeckPromptLangHandler сохраняет язык вопросов колоды по умолчанию
+func DeckPromptLangHandler(w http.ResponseWriter, r *http.Request) {
+	deck, _, ok := deckRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var promptLangID *uint
+	if raw := r.FormValue("lang_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid language ID", http.StatusBadRequest)
+			return
+		}
+		langID := uint(id)
+		promptLangID = &langID
+	}
+
+	db := database.GetDB()
+	if promptLangID != nil {
+		var count int64
+		err := db.Raw("SELECT COUNT(*) FROM langhelpercopy.deck_langs WHERE deck_id = ? AND lang_id = ?", deck.ID, *promptLangID).Scan(&count).Error
+		if err != nil || count == 0 {
+			http.Error(w, "Language is not in this deck", http.StatusBadRequest)
+			return
+		}
+	}
+
+	version, err := formVersion(r)
+	if err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	result := db.Exec(`
+		UPDATE langhelpercopy.decks SET prompt_lang_id = ?, updated_at = NOW(), version = version + 1
+		WHERE id = ? AND version = ?
+	`, promptLangID, deck.ID, version)
+	if result.Error != nil {
+		log.Printf("Failed to save deck prompt language: %v", result.Error)
+		http.Error(w, "Error saving deck", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		renderConflict(w, "This deck", "/deck/"+strconv.FormatUint(uint64(deck.ID), 10))
+		return
+	}
+	http.Redirect(w, r, "/deck/"+strconv.FormatUint(uint64(deck.ID), 10), http.StatusSeeOther)
+}
@@ -0,0 +1,417 @@
+package routes
+
+import (
+	"fmt"
+	"html/template"
+	"langhelperCopy/config"
+	"langhelperCopy/database"
+	"langhelperCopy/models"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// leechTagName - метка, которая автоматически ставится словам-"пиявкам"
+const leechTagName = "leech"
+
+// leechMistakesShown - сколько последних ошибок показывать для каждой пиявки
+const leechMistakesShown = 10
+
+// updateCardProgress учитывает ответ в статистике слова и помечает слово как
+// "leech", когда число ошибок достигает порога пользователя.
+func updateCardProgress(db *gorm.DB, review models.Review) error {
+	failure, success := 0, 1
+	if !review.Correct {
+		failure, success = 1, 0
+	}
+
+	var progress models.CardProgress
+	err := db.Raw(`
+		INSERT INTO langhelpercopy.card_progresses (user_id, word_id, lang_id, successes, failures, streak, last_reviewed_at)
+		VALUES (?, ?, ?, ?, ?, ?, NOW())
+		ON CONFLICT (user_id, word_id, lang_id) DO UPDATE SET
+			successes = card_progresses.successes + EXCLUDED.successes,
+			failures = card_progresses.failures + EXCLUDED.failures,
+			streak = CASE WHEN EXCLUDED.successes > 0 THEN card_progresses.streak + 1 ELSE 0 END,
+			last_reviewed_at = NOW()
+		RETURNING *
+	`, review.UserID, review.WordID, review.LangID, success, failure, success).Scan(&progress).Error
+	if err != nil {
+		return err
+	}
+
+	// Следующее повторение назначается по числу правильных ответов подряд
+	interval := models.ReviewIntervalDays(progress.Streak)
+	err = db.Exec(`
+		UPDATE langhelpercopy.card_progresses
+		SET interval_days = ?, due_at = NOW() + make_interval(days => ?)
+		WHERE id = ?
+	`, interval, interval, progress.ID).Error
+	if err != nil {
+		return err
+	}
+	if progress.IsLeech || review.Correct {
+		return nil
+	}
+
+	var user models.User
+	err = db.Raw("SELECT id, leech_threshold, leech_auto_suspend FROM langhelpercopy.users WHERE id = ?", review.UserID).Scan(&user).Error
+	if err != nil {
+		return err
+	}
+	if user.LeechThreshold == 0 || progress.Failures < user.LeechThreshold {
+		return nil
+	}
+
+	err = db.Exec("UPDATE langhelpercopy.card_progresses SET is_leech = true, leech_at = NOW() WHERE id = ?", progress.ID).Error
+	if err != nil {
+		return err
+	}
+	if err := addWordTag(db, review.UserID, review.WordID, leechTagName); err != nil {
+		return err
+	}
+	if user.LeechAutoSuspend && review.DeckID != 0 {
+		return setDeckWordState(db, review.UserID, review.DeckID, review.WordID, true, nil)
+	}
+	return nil
+}
+
+// setDeckWordState задаёт состояние слова в колоде. Колода должна
+// принадлежать userID, иначе ничего не меняется.
+func setDeckWordState(db *gorm.DB, userID, deckID, wordID uint, suspended bool, buriedUntil *time.Time) error {
+	return db.Exec(`
+		INSERT INTO langhelpercopy.deck_word_states (deck_id, word_id, suspended, buried_until)
+		SELECT id, ?, ?, ? FROM langhelpercopy.decks WHERE id = ? AND user_id = ?
+		ON CONFLICT (deck_id, word_id) DO UPDATE SET
+			suspended = EXCLUDED.suspended,
+			buried_until = EXCLUDED.buried_until
+	`, wordID, suspended, buriedUntil, deckID, userID).Error
+}
+
+// loadDeckWordStates возвращает состояния слов колоды по ID слова
+func loadDeckWordStates(db *gorm.DB, deckID uint) (map[uint]models.DeckWordState, error) {
+	var states []models.DeckWordState
+	err := db.Raw("SELECT * FROM langhelpercopy.deck_word_states WHERE deck_id = ?", deckID).Scan(&states).Error
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[uint]models.DeckWordState, len(states))
+	for _, s := range states {
+		result[s.WordID] = s
+	}
+	return result, nil
+}
+
+// studyDeckWordIDs возвращает слова колоды для тренировки: без
+// отключённых и отложенных.
+func studyDeckWordIDs(db *gorm.DB, deck models.Deck) ([]uint, error) {
+	wordIDs, err := deckWordIDs(db, deck)
+	if err != nil || len(wordIDs) == 0 {
+		return wordIDs, err
+	}
+
+	var skipped []uint
+	err = db.Raw(`
+		SELECT word_id FROM langhelpercopy.deck_word_states
+		WHERE deck_id = ? AND (suspended OR buried_until > NOW())
+	`, deck.ID).Scan(&skipped).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := wordIDs[:0]
+	for _, id := range wordIDs {
+		if !containsID(skipped, id) {
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
+
+// buryUntil возвращает начало следующего дня в часовом поясе tz - до него
+// слово отложено
+func buryUntil(now time.Time, tz string) time.Time {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		loc = time.UTC
+	}
+	year, month, day := now.In(loc).Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, loc)
+}
+
+// DeckWordStateHandler отключает, откладывает до завтра или возвращает слово в колоду
+func DeckWordStateHandler(w http.ResponseWriter, r *http.Request) {
+	deck, userID, ok := deckRequest(w, r)
+	if !ok {
+		return
+	}
+
+	wordID, err := strconv.ParseUint(r.FormValue("word_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid word ID", http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+
+	wordIDs, err := deckWordIDs(db, deck)
+	if err != nil {
+		log.Printf("Failed to load deck words: %v", err)
+		http.Error(w, "Failed to load deck words", http.StatusInternalServerError)
+		return
+	}
+	if !containsID(wordIDs, uint(wordID)) {
+		http.Error(w, "Word is not in this deck", http.StatusBadRequest)
+		return
+	}
+
+	switch r.FormValue("action") {
+	case "suspend":
+		err = setDeckWordState(db, userID, deck.ID, uint(wordID), true, nil)
+	case "bury":
+		var timezone string
+		if timezone, err = userTimezone(db, userID); err != nil {
+			break
+		}
+		until := buryUntil(time.Now(), timezone)
+		err = setDeckWordState(db, userID, deck.ID, uint(wordID), false, &until)
+	case "restore":
+		err = setDeckWordState(db, userID, deck.ID, uint(wordID), false, nil)
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to update word state: %v", err)
+		http.Error(w, "Failed to update word", http.StatusInternalServerError)
+		return
+	}
+
+	redirect := r.FormValue("redirect")
+	if redirect != "/leeches" {
+		redirect = fmt.Sprintf("/deck/%d", deck.ID)
+	}
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+// LeechMistake - неправильный ответ из истории повторений
+type LeechMistake struct {
+	Answer    string
+	CreatedAt time.Time
+}
+
+// LeechDeck - колода, в которой есть слово-пиявка
+type LeechDeck struct {
+	ID        uint
+	DeckTitle string
+	Suspended bool
+}
+
+// Leech - слово, которое не запоминается, с историей ошибок
+type Leech struct {
+	WordID      uint
+	LangID      uint
+	LangTitle   string
+	Translation string
+	Successes   int
+	Failures    int
+	LeechAt     *time.Time
+	Details     *WordDetails
+	Mistakes    []LeechMistake
+	Decks       []LeechDeck
+	models.LangTag
+}
+
+// LeechesHandler показывает слова-пиявки пользователя с историей ошибок
+func LeechesHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
+	db := database.GetDB()
+
+	var leeches []Leech
+	err = db.Raw(`
+		SELECT cp.word_id, cp.lang_id, ul.lang_title, ul.lang_code, COALESCE(uw.translation, '') AS translation,
+			cp.successes, cp.failures, cp.leech_at
+		FROM langhelpercopy.card_progresses cp
+		JOIN langhelpercopy.user_langs ul ON cp.lang_id = ul.id
+		JOIN langhelpercopy.words w ON w.id = cp.word_id
+		LEFT JOIN langhelpercopy.user_words uw ON uw.word_id = cp.word_id AND uw.lang_id = cp.lang_id AND uw.is_primary
+		WHERE cp.user_id = ? AND cp.is_leech AND ul.deleted_at IS NULL AND w.deleted_at IS NULL
+		ORDER BY cp.failures DESC, cp.leech_at DESC
+	`, userID).Scan(&leeches).Error
+	if err != nil {
+		log.Printf("Failed to load leeches: %v", err)
+		http.Error(w, "Failed to load leeches", http.StatusInternalServerError)
+		return
+	}
+
+	userLangs, err := loadUserLangs(db, userID)
+	if err != nil {
+		log.Printf("Failed to load languages: %v", err)
+		http.Error(w, "Failed to load languages", http.StatusInternalServerError)
+		return
+	}
+
+	if len(leeches) > 0 {
+		wordIDs := make([]uint, len(leeches))
+		for i, l := range leeches {
+			wordIDs[i] = l.WordID
+		}
+		langIDs := make([]uint, len(userLangs))
+		for i, l := range userLangs {
+			langIDs[i] = l.ID
+		}
+
+		// Переводы на другие языки помогают понять, о каком слове речь
+		details, err := loadWordDetails(db, wordIDs, langIDs)
+		if err != nil {
+			log.Printf("Failed to load word details: %v", err)
+			http.Error(w, "Failed to load word details", http.StatusInternalServerError)
+			return
+		}
+
+		var mistakes []struct {
+			WordID    uint
+			LangID    uint
+			Answer    string
+			CreatedAt time.Time
+		}
+		err = db.Raw(`
+			SELECT word_id, lang_id, answer, created_at
+			FROM langhelpercopy.reviews
+			WHERE user_id = ? AND word_id IN (?) AND NOT correct
+			ORDER BY created_at DESC
+		`, userID, wordIDs).Scan(&mistakes).Error
+		if err != nil {
+			log.Printf("Failed to load mistakes: %v", err)
+			http.Error(w, "Failed to load mistakes", http.StatusInternalServerError)
+			return
+		}
+
+		var decks []struct {
+			WordID    uint
+			ID        uint
+			DeckTitle string
+			Suspended bool
+		}
+		err = db.Raw(`
+			SELECT dw.word_id, d.id, d.deck_title, COALESCE(s.suspended, false) AS suspended
+			FROM langhelpercopy.deck_words dw
+			JOIN langhelpercopy.decks d ON dw.deck_id = d.id
+			LEFT JOIN langhelpercopy.deck_word_states s ON s.deck_id = d.id AND s.word_id = dw.word_id
+			WHERE d.user_id = ? AND dw.word_id IN (?) AND d.deleted_at IS NULL
+			ORDER BY d.deck_title
+		`, userID, wordIDs).Scan(&decks).Error
+		if err != nil {
+			log.Printf("Failed to load decks: %v", err)
+			http.Error(w, "Failed to load decks", http.StatusInternalServerError)
+			return
+		}
+
+		for i := range leeches {
+			l := &leeches[i]
+			l.Details = details[l.WordID]
+			for _, m := range mistakes {
+				if m.WordID == l.WordID && m.LangID == l.LangID && len(l.Mistakes) < leechMistakesShown {
+					l.Mistakes = append(l.Mistakes, LeechMistake{Answer: m.Answer, CreatedAt: m.CreatedAt})
+				}
+			}
+			for _, d := range decks {
+				if d.WordID == l.WordID {
+					l.Decks = append(l.Decks, LeechDeck{ID: d.ID, DeckTitle: d.DeckTitle, Suspended: d.Suspended})
+				}
+			}
+		}
+	}
+
+	tmpl, err := template.ParseFiles("templates/layout.html", "templates/leeches.html")
+	if err != nil {
+		log.Printf("template.ParseFiles error: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Title     string
+		Leeches   []Leech
+		UserLangs []models.UserLang
+	}{
+		Title:     "Leeches",
+		Leeches:   leeches,
+		UserLangs: userLangs,
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
+		log.Printf("ExecuteTemplate error: %v", err)
+	}
+}
+
+// ResetLeechHandler снимает пометку "leech" и обнуляет счётчик ошибок слова на языке
+func ResetLeechHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
+	wordID, err := strconv.ParseUint(r.FormValue("word_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid word ID", http.StatusBadRequest)
+		return
+	}
+	langID, err := strconv.ParseUint(r.FormValue("lang_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid language ID", http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+
+	err = database.UnitOfWork(db, func(tx *gorm.DB) error {
+		err := tx.Exec(`
+			UPDATE langhelpercopy.card_progresses
+			SET is_leech = false, leech_at = NULL, failures = 0
+			WHERE user_id = ? AND word_id = ? AND lang_id = ?
+		`, userID, wordID, langID).Error
+		if err != nil {
+			return err
+		}
+
+		// Метка снимается, когда слово больше не пиявка ни на одном языке
+		var remaining int64
+		err = tx.Raw(`
+			SELECT COUNT(*) FROM langhelpercopy.card_progresses
+			WHERE user_id = ? AND word_id = ? AND is_leech
+		`, userID, wordID).Scan(&remaining).Error
+		if err != nil || remaining > 0 {
+			return err
+		}
+		return removeWordTag(tx, userID, uint(wordID), leechTagName)
+	})
+	if err != nil {
+		log.Printf("Failed to reset leech: %v", err)
+		http.Error(w, "Failed to reset leech", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/leeches", http.StatusSeeOther)
+}
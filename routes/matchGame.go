@@ -0,0 +1,166 @@
+package routes
+
+import (
+	"fmt"
+	"langhelperCopy/database"
+	"langhelperCopy/models"
+	"log"
+	"math/rand/v2"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+// Число пар в одной игре на сопоставление
+const (
+	matchMinPairs = 5
+	matchMaxPairs = 8
+)
+
+// buildMatchPairs выбирает от matchMinPairs до matchMaxPairs случайных слов,
+// переведённых на основной и первый выбранный изучаемый язык. Каждая пара -
+// отдельная карточка, а вариантами у всех карточек служит перемешанная правая колонка.
+func buildMatchPairs(db *gorm.DB, wordIDs []uint, mainLangID uint, pref models.QuizPreference) ([]WordTest, error) {
+	targets := pref.Targets()
+	if len(targets) == 0 {
+		return nil, nil
+	}
+	targetLangID := targets[0]
+
+	var rows []struct {
+		WordID   uint
+		MainWord string
+		Target   string
+	}
+	err := db.Raw(`
+		SELECT m.word_id, m.translation AS main_word, t.translation AS target
+		FROM langhelpercopy.user_words m
+		JOIN langhelpercopy.user_words t ON t.word_id = m.word_id AND t.lang_id = ? AND t.is_primary
+		WHERE m.word_id IN (?) AND m.lang_id = ? AND m.is_primary
+	`, targetLangID, wordIDs, mainLangID).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	rand.Shuffle(len(rows), func(i, j int) {
+		rows[i], rows[j] = rows[j], rows[i]
+	})
+
+	count := matchMinPairs + rand.IntN(matchMaxPairs-matchMinPairs+1)
+
+	// Одинаковые слова в колонке нельзя было бы различить
+	seenMain := make(map[string]bool)
+	seenTarget := make(map[string]bool)
+	var tests []WordTest
+	var column []string
+	for _, row := range rows {
+		if len(tests) == count {
+			break
+		}
+		mainKey, targetKey := foldKey(row.MainWord), foldKey(row.Target)
+		if seenMain[mainKey] || seenTarget[targetKey] {
+			continue
+		}
+		seenMain[mainKey], seenTarget[targetKey] = true, true
+
+		tests = append(tests, WordTest{
+			WordID:   row.WordID,
+			MainWord: row.MainWord,
+			Tests: []LangTest{{
+				DeckLang: models.DeckLang{LangID: targetLangID},
+				Correct:  row.Target,
+				Prompt:   row.MainWord,
+			}},
+		})
+		column = append(column, row.Target)
+	}
+
+	rand.Shuffle(len(column), func(i, j int) {
+		column[i], column[j] = column[j], column[i]
+	})
+	for i := range tests {
+		tests[i].Tests[0].Options = column
+	}
+	return tests, nil
+}
+
+// StudyMatchHandler проверяет все пары игры сразу, сохраняет ответы и завершает сессию
+func StudyMatchHandler(w http.ResponseWriter, r *http.Request) {
+	study, userID, ok := studyRequest(w, r)
+	if !ok {
+		return
+	}
+	redirectURL := fmt.Sprintf("/study/%d", study.ID)
+
+	if !study.IsMatch() || study.Status != models.StudySessionActive {
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+
+	cards, err := loadStudyCards(db, study.ID)
+	if err != nil {
+		log.Printf("Failed to load study cards: %v", err)
+		http.Error(w, "Failed to load session", http.StatusInternalServerError)
+		return
+	}
+
+	// Каждая пара проверяется по всем допустимым переводам слова; ответы
+	// сохраняются в истории для статистики и умных колод
+	err = database.UnitOfWork(db, func(tx *gorm.DB) error {
+		for _, card := range cards {
+			if card.Answered {
+				continue
+			}
+			answer := r.FormValue(fmt.Sprintf("pair_%d", card.ID))
+			accepted, err := loadAcceptedTranslations(tx, card.WordID, card.LangID)
+			if err != nil {
+				return err
+			}
+			correct := answer != "" && isAcceptedAnswer(answer, accepted)
+
+			result := tx.Exec(`
+				UPDATE langhelpercopy.study_cards
+				SET answer = ?, answered = true, correct = ?, answered_at = NOW()
+				WHERE id = ? AND NOT answered
+			`, truncateRunes(answer, 255), correct, card.ID)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+
+			err = recordReview(tx, models.Review{
+				UserID:  userID,
+				DeckID:  study.DeckID,
+				WordID:  card.WordID,
+				LangID:  card.LangID,
+				Answer:  answer,
+				Correct: correct,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Exec(`
+			UPDATE langhelpercopy.study_sessions
+			SET status = ?, position = ?, finished_at = NOW(), updated_at = NOW()
+			WHERE id = ?
+		`, models.StudySessionFinished, len(cards), study.ID).Error
+	})
+	if err != nil {
+		log.Printf("Failed to grade matching: %v", err)
+		http.Error(w, "Failed to check pairs", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
@@ -6,8 +6,11 @@ import (
 	"langhelperCopy/config"
 	"langhelperCopy/database"
 	"langhelperCopy/models"
+	"log"
 	"net/http"
 	"strings"
+
+	"gorm.io/gorm"
 )
 
 func DecksHandler(w http.ResponseWriter, r *http.Request) {
@@ -33,13 +36,19 @@ func DecksHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Получаем языки пользователя
-	var userLangs []models.UserLang
-	if err := db.Raw("SELECT * FROM langhelpercopy.user_langs WHERE user_id = ?", userID).Scan(&userLangs).Error; err != nil {
+	userLangs, err := loadUserLangs(db, userID)
+	if err != nil {
 		http.Error(w, "Failed to fetch user languages", http.StatusInternalServerError)
 		return
 	}
 	fmt.Printf("Loaded userLangs: %+v\n", userLangs)
 
+	tags, err := loadUserTags(db, userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch tags", http.StatusInternalServerError)
+		return
+	}
+
 	if r.Method == "POST" {
 		if err := r.ParseForm(); err != nil {
 			http.Error(w, "Invalid form data", http.StatusBadRequest)
@@ -48,18 +57,47 @@ func DecksHandler(w http.ResponseWriter, r *http.Request) {
 
 		deckTitle := strings.TrimSpace(r.FormValue("deck_title"))
 
-		if deckTitle == "" {
-			http.Error(w, "Deck title is required", http.StatusBadRequest)
+		if err := models.ValidateDeckTitle(deckTitle); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		// Создание колоды
-		var deckID uint
-		err := db.Raw(
-			"INSERT INTO langhelpercopy.decks (user_id, deck_title) VALUES (?, ?) RETURNING id",
-			userID, deckTitle,
-		).Scan(&deckID).Error
+		// Для умной колоды сохраняется правило отбора слов
+		smartRule := ""
+		var rule models.SmartRule
+		if r.FormValue("smart") == "on" {
+			rule, err = parseSmartRuleForm(r, userLangs, tags)
+			if err != nil {
+				http.Error(w, "Invalid smart deck rule: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			smartRule, err = rule.Encode()
+			if err != nil {
+				http.Error(w, "Failed to save smart deck rule", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		// Создание колоды вместе с языками умной колоды
+		err := database.UnitOfWork(db, func(tx *gorm.DB) error {
+			var deckID uint
+			err := tx.Raw(
+				"INSERT INTO langhelpercopy.decks (user_id, deck_title, smart_rule) VALUES (?, ?, ?) RETURNING id",
+				userID, deckTitle, smartRule,
+			).Scan(&deckID).Error
+			if err != nil {
+				return err
+			}
+			if err := recordEntityChange(tx, userID, models.HistoryDeck, deckID, models.HistoryCreate, deckTitle); err != nil {
+				return err
+			}
+			if smartRule != "" {
+				return syncSmartDeckLangs(tx, deckID, rule.LangIDs)
+			}
+			return nil
+		})
 		if err != nil {
+			log.Printf("Failed to create deck: %v", err)
 			http.Error(w, "Failed to create deck", http.StatusInternalServerError)
 			return
 		}
@@ -70,7 +108,7 @@ func DecksHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Загружаем все колоды пользователя
 	var decks []models.Deck
-	if err := db.Raw("SELECT * FROM langhelpercopy.decks WHERE user_id = ?", userID).Scan(&decks).Error; err != nil {
+	if err := db.Raw("SELECT * FROM langhelpercopy.decks WHERE user_id = ? AND deleted_at IS NULL", userID).Scan(&decks).Error; err != nil {
 		http.Error(w, "Failed to load decks", http.StatusInternalServerError)
 		return
 	}
@@ -87,7 +125,7 @@ func DecksHandler(w http.ResponseWriter, r *http.Request) {
 			SELECT ul.lang_title
 			FROM langhelpercopy.deck_langs dl
 			JOIN langhelpercopy.user_langs ul ON dl.lang_id = ul.id
-			WHERE dl.deck_id = ?`, d.ID).Scan(&langTitles)
+			WHERE dl.deck_id = ? AND ul.deleted_at IS NULL`, d.ID).Scan(&langTitles)
 
 		decksWithLangs = append(decksWithLangs, DeckWithLangs{
 			Deck:      d,
@@ -99,12 +137,13 @@ func DecksHandler(w http.ResponseWriter, r *http.Request) {
 		"Title":     "My Decks",
 		"Decks":     decksWithLangs,
 		"UserLangs": userLangs,
+		"Tags":      tags,
 	}
 
 	tmpl := template.New("layout.html").Funcs(template.FuncMap{
 		"join": strings.Join,
-	})
-	tmpl, err = tmpl.ParseFiles("templates/layout.html", "templates/mydecks.html")
+	}).Funcs(smartRuleFuncs)
+	tmpl, err = tmpl.ParseFiles("templates/layout.html", "templates/mydecks.html", "templates/smartRule.html")
 	if err != nil {
 		http.Error(w, "Template error: "+err.Error(), http.StatusInternalServerError)
 		return
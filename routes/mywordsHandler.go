@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"errors"
 	"fmt"
 	"html/template"
 	"langhelperCopy/config"
@@ -8,11 +9,70 @@ import (
 	"langhelperCopy/models"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/gorilla/mux"
+	"gorm.io/gorm"
 )
 
+// Ограничения длины соответствуют size у колонок моделей
+const (
+	maxTranslationLength   = 50
+	maxGenderLength        = 20
+	maxPronunciationLength = 100
+	maxNotesLength         = 500
+	maxExampleLength       = 255
+)
+
+// translationInput - перевод слова на один язык из формы /mywords
+type translationInput struct {
+	LangID        uint
+	Translation   string
+	Alternatives  []string
+	Gender        string
+	Pronunciation string
+	Notes         string
+	Examples      []string
+}
+
+// validate возвращает текст ошибки для формы или пустую строку
+func (t translationInput) validate() string {
+	for _, tr := range append([]string{t.Translation}, t.Alternatives...) {
+		if utf8.RuneCountInString(tr) > maxTranslationLength {
+			return fmt.Sprintf("Translation %q is longer than %d characters", tr, maxTranslationLength)
+		}
+	}
+	if utf8.RuneCountInString(t.Gender) > maxGenderLength {
+		return fmt.Sprintf("Gender/article must be at most %d characters", maxGenderLength)
+	}
+	if utf8.RuneCountInString(t.Pronunciation) > maxPronunciationLength {
+		return fmt.Sprintf("Pronunciation must be at most %d characters", maxPronunciationLength)
+	}
+	if utf8.RuneCountInString(t.Notes) > maxNotesLength {
+		return fmt.Sprintf("Notes must be at most %d characters", maxNotesLength)
+	}
+	for _, e := range t.Examples {
+		if utf8.RuneCountInString(e) > maxExampleLength {
+			return fmt.Sprintf("Example sentences must be at most %d characters", maxExampleLength)
+		}
+	}
+	return ""
+}
+
+// parseExamples разбирает примеры из textarea: по одному предложению на строку
+func parseExamples(raw string) []string {
+	var examples []string
+	for _, line := range strings.Split(raw, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			examples = append(examples, line)
+		}
+	}
+	return examples
+}
+
 func WordsHandler(w http.ResponseWriter, r *http.Request) {
 	session, err := config.Store.Get(r, config.SessionName)
 	if err != nil || session.Values["authenticated"] != true {
@@ -28,8 +88,13 @@ func WordsHandler(w http.ResponseWriter, r *http.Request) {
 
 	db := database.GetDB()
 
-	var langs []models.UserLang
-	if err := db.Raw("SELECT id, lang_title FROM langhelpercopy.user_langs WHERE user_id = ?", userID).Scan(&langs).Error; err != nil {
+	langs, err := loadUserLangs(db, userID)
+	if err != nil {
+		http.Error(w, "Failed to get user langs", http.StatusInternalServerError)
+		return
+	}
+	mainLangID, err := userMainLangID(db, userID)
+	if err != nil {
 		http.Error(w, "Failed to get user langs", http.StatusInternalServerError)
 		return
 	}
@@ -39,88 +104,222 @@ func WordsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodPost {
 		r.ParseForm()
 		wordID := r.FormValue("word_id")
+		// Версия слова, которое редактировали; у нового слова её нет
+		version, versionErr := formVersion(r)
 
-		var translations []struct {
-			LangID      uint
-			Translation string
-		}
+		var translations []translationInput
 		for _, lang := range langs {
 			val := strings.TrimSpace(r.FormValue(fmt.Sprintf("translation_%d", lang.ID)))
 			if val != "" {
-				translations = append(translations, struct {
-					LangID      uint
-					Translation string
-				}{lang.ID, val})
+				t := translationInput{
+					LangID:        lang.ID,
+					Translation:   val,
+					Alternatives:  parseAlternatives(r.FormValue(fmt.Sprintf("alternatives_%d", lang.ID)), val),
+					Gender:        strings.TrimSpace(r.FormValue(fmt.Sprintf("gender_%d", lang.ID))),
+					Pronunciation: strings.TrimSpace(r.FormValue(fmt.Sprintf("pronunciation_%d", lang.ID))),
+					Notes:         strings.TrimSpace(r.FormValue(fmt.Sprintf("notes_%d", lang.ID))),
+					Examples:      parseExamples(r.FormValue(fmt.Sprintf("examples_%d", lang.ID))),
+				}
+				translations = append(translations, t)
+
+				if err := t.validate(); err != "" {
+					formError = err
+				}
 			}
 		}
 
+		partOfSpeech := strings.TrimSpace(r.FormValue("part_of_speech"))
+		wordNotes := strings.TrimSpace(r.FormValue("word_notes"))
+		tagNames, tagErr := models.ParseTagNames(r.FormValue("tags"))
+		if tagErr != nil {
+			formError = tagErr.Error()
+		} else if !models.IsValidPartOfSpeech(partOfSpeech) {
+			formError = "Unknown part of speech"
+		} else if utf8.RuneCountInString(wordNotes) > maxNotesLength {
+			formError = fmt.Sprintf("Notes must be at most %d characters", maxNotesLength)
+		}
+
+		if wordID != "" && versionErr != nil {
+			formError = "Invalid form data"
+		}
+
 		if len(translations) == 0 {
 			formError = "At least one translation must be provided"
-		} else {
-			if wordID == "" {
-				var newWordID uint
-				err := db.Raw("INSERT INTO langhelpercopy.words DEFAULT VALUES RETURNING id").Scan(&newWordID).Error
-				if err != nil {
-					log.Fatalf("Failed to insert word: %v", err)
-				}
-				wordID = fmt.Sprint(newWordID)
-
-				for _, t := range translations {
-					db.Exec("INSERT INTO langhelpercopy.user_words (lang_id, word_id, translation) VALUES (?, ?, ?)", t.LangID, wordID, t.Translation)
-				}
-			} else {
-				for _, t := range translations {
-					var existing string
-					db.Raw("SELECT translation FROM langhelpercopy.user_words WHERE word_id = ? AND lang_id = ?", wordID, t.LangID).Scan(&existing)
-					if existing == "" {
-						// новый перевод
-						db.Exec("INSERT INTO langhelpercopy.user_words (lang_id, word_id, translation) VALUES (?, ?, ?)", t.LangID, wordID, t.Translation)
-					} else if existing != t.Translation {
-						// обновляем только если отличается
-						db.Exec("UPDATE langhelpercopy.user_words SET translation = ? WHERE word_id = ? AND lang_id = ?", t.Translation, wordID, t.LangID)
-					}
-				}
-			}
-			if formError == "" {
+		} else if formError == "" {
+			err := database.UnitOfWork(db, func(tx *gorm.DB) error {
+				return saveWord(tx, userID, wordID, wordForm{
+					Version:      version,
+					Translations: translations,
+					PartOfSpeech: partOfSpeech,
+					Notes:        wordNotes,
+					TagNames:     tagNames,
+				})
+			})
+			switch {
+			case errors.Is(err, errWordNotFound):
+				formError = "Word not found"
+			case errors.Is(err, errStaleVersion):
+				renderConflict(w, "This word", "/mywords")
+				return
+			case err != nil:
+				log.Printf("Failed to save word: %v", err)
+				formError = "Failed to save the word, nothing was changed"
+			default:
 				http.Redirect(w, r, "/mywords", http.StatusSeeOther)
 				return
 			}
 		}
 	}
 
+	type WordCell struct {
+		LangID       uint
+		Alternatives string
+		Examples     string
+		TranslationDetails
+		models.LangTag
+	}
 	type WordGroup struct {
 		ID           uint
-		Translations []string
+		Version      int
+		PartOfSpeech string
+		Notes        string
+		Tags         []models.Tag
+		TagNames     string
+		Cells        []WordCell
 	}
 	wordGroups := []WordGroup{}
 
+	// Фильтр по меткам: слово должно иметь все выбранные метки
+	activeTags := make(map[uint]bool)
+	tagQuery := ""
+	tagArgs := []interface{}{userID}
+	for _, raw := range r.URL.Query()["tag"] {
+		tagID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil || activeTags[uint(tagID)] {
+			continue
+		}
+		activeTags[uint(tagID)] = true
+		tagQuery += " AND word_id IN (SELECT word_id FROM langhelpercopy.word_tags WHERE tag_id = ?)"
+		tagArgs = append(tagArgs, tagID)
+	}
+
 	var wordIDs []uint
 	db.Raw(`
 		SELECT DISTINCT word_id FROM langhelpercopy.user_words 
-		WHERE lang_id IN (SELECT id FROM langhelpercopy.user_langs WHERE user_id = ?) 
-		ORDER BY word_id`, userID).Scan(&wordIDs)
+		WHERE lang_id IN (SELECT id FROM langhelpercopy.user_langs WHERE user_id = ? AND deleted_at IS NULL)
+			AND word_id IN (SELECT id FROM langhelpercopy.words WHERE deleted_at IS NULL)`+tagQuery+`
+		ORDER BY word_id`, tagArgs...).Scan(&wordIDs)
+
+	userTags, err := loadUserTags(db, userID)
+	if err != nil {
+		log.Printf("Failed to load tags: %v", err)
+		http.Error(w, "Failed to load tags", http.StatusInternalServerError)
+		return
+	}
+	wordTags, err := loadWordTags(db, userID)
+	if err != nil {
+		log.Printf("Failed to load word tags: %v", err)
+		http.Error(w, "Failed to load tags", http.StatusInternalServerError)
+		return
+	}
+
+	// Ссылки фильтра переключают метку, сохраняя остальные выбранные
+	type TagFilter struct {
+		models.Tag
+		Active bool
+		URL    string
+	}
+	tagFilters := make([]TagFilter, 0, len(userTags))
+	for _, tag := range userTags {
+		params := url.Values{}
+		for id := range activeTags {
+			if id != tag.ID {
+				params.Add("tag", strconv.FormatUint(uint64(id), 10))
+			}
+		}
+		if !activeTags[tag.ID] {
+			params.Add("tag", strconv.FormatUint(uint64(tag.ID), 10))
+		}
+		tagFilters = append(tagFilters, TagFilter{
+			Tag:    tag,
+			Active: activeTags[tag.ID],
+			URL:    "/mywords?" + params.Encode(),
+		})
+	}
+
+	langIDs := make([]uint, len(langs))
+	for i, lang := range langs {
+		langIDs[i] = lang.ID
+	}
+	details, err := loadWordDetails(db, wordIDs, langIDs)
+	if err != nil {
+		log.Printf("Failed to load words: %v", err)
+		http.Error(w, "Failed to load words", http.StatusInternalServerError)
+		return
+	}
+	versions, err := loadWordVersions(db, wordIDs, false)
+	if err != nil {
+		log.Printf("Failed to load word versions: %v", err)
+		http.Error(w, "Failed to load words", http.StatusInternalServerError)
+		return
+	}
 
 	for _, wid := range wordIDs {
-		trans := make([]string, len(langs))
+		wd, ok := details[wid]
+		if !ok {
+			continue
+		}
+		cells := make([]WordCell, len(langs))
 		for i, lang := range langs {
-			var t string
-			db.Raw("SELECT translation FROM langhelpercopy.user_words WHERE word_id = ? AND lang_id = ?", wid, lang.ID).Scan(&t)
-			trans[i] = t
+			td := wd.Lang(lang.ID)
+			cells[i] = WordCell{
+				LangID:             lang.ID,
+				Alternatives:       strings.Join(td.Alternatives, "; "),
+				Examples:           strings.Join(td.Examples, "\n"),
+				TranslationDetails: td,
+				LangTag:            models.LangTag{LangCode: lang.LangCode},
+			}
+		}
+		tagNames := make([]string, len(wordTags[wid]))
+		for i, tag := range wordTags[wid] {
+			tagNames[i] = tag.Name
 		}
 		wordGroups = append(wordGroups, WordGroup{
 			ID:           wid,
-			Translations: trans,
+			Version:      versions[wid],
+			PartOfSpeech: wd.PartOfSpeech,
+			Notes:        wd.Notes,
+			Tags:         wordTags[wid],
+			TagNames:     strings.Join(tagNames, ", "),
+			Cells:        cells,
 		})
 	}
 
+	decks, err := loadManualDecks(db, userID)
+	if err != nil {
+		log.Printf("Failed to load decks: %v", err)
+		http.Error(w, "Failed to load decks", http.StatusInternalServerError)
+		return
+	}
+
 	data := map[string]interface{}{
-		"Title":     "My Words",
-		"Langs":     langs,
-		"Words":     wordGroups,
-		"FormError": formError,
+		"Title":         "My Words",
+		"Langs":         langs,
+		"MainLangID":    mainLangID,
+		"Words":         wordGroups,
+		"PartsOfSpeech": models.PartsOfSpeech,
+		"TagFilters":    tagFilters,
+		"FiltersActive": len(activeTags) > 0,
+		"FormError":     formError,
+		"Bulk": BulkActionsData{
+			FormID:    "bulkWords",
+			ReturnURL: r.URL.RequestURI(),
+			Decks:     decks,
+			Langs:     langs,
+		},
 	}
 
-	tmpl, err := template.ParseFiles("templates/layout.html", "templates/mywords.html")
+	tmpl, err := template.ParseFiles("templates/layout.html", "templates/mywords.html", "templates/bulkActions.html")
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -128,6 +327,129 @@ func WordsHandler(w http.ResponseWriter, r *http.Request) {
 	tmpl.ExecuteTemplate(w, "layout.html", data)
 }
 
+// errWordNotFound - слово из формы не принадлежит пользователю или в корзине
+var errWordNotFound = errors.New("word not found")
+
+// wordForm - проверенные данные формы слова на /mywords
+type wordForm struct {
+	Version      int // версия слова, которую видел пользователь; только при изменении
+	Translations []translationInput
+	PartOfSpeech string
+	Notes        string
+	TagNames     []string
+}
+
+// saveWord создаёт слово (wordID == "") или обновляет существующее вместе с
+// переводами, синонимами, примерами, метками и журналом изменений. Если слово
+// изменили после того, как пользователь открыл форму, возвращает errStaleVersion.
+// Вызывается внутри database.UnitOfWork: при ошибке не сохраняется ничего.
+func saveWord(tx *gorm.DB, userID uint, wordID string, form wordForm) error {
+	var oldWord models.Word
+	if wordID == "" {
+		err := tx.Raw("INSERT INTO langhelpercopy.words DEFAULT VALUES RETURNING id").Scan(&oldWord.ID).Error
+		if err != nil {
+			return err
+		}
+		wordID = fmt.Sprint(oldWord.ID)
+		if err := recordEntityChange(tx, userID, models.HistoryWord, oldWord.ID, models.HistoryCreate, form.Translations[0].Translation); err != nil {
+			return err
+		}
+	} else {
+		err := tx.Raw(`
+			SELECT id, part_of_speech, notes, version FROM langhelpercopy.words w
+			WHERE w.id = ? AND w.deleted_at IS NULL AND w.`+trashTables[trashWord].Owner+`
+			FOR UPDATE
+		`, wordID, userID).Scan(&oldWord).Error
+		if err != nil {
+			return err
+		}
+		if oldWord.ID == 0 {
+			return errWordNotFound
+		}
+		if oldWord.Version != form.Version {
+			return errStaleVersion
+		}
+	}
+
+	for _, t := range form.Translations {
+		var existing string
+		err := tx.Raw("SELECT translation FROM langhelpercopy.user_words WHERE word_id = ? AND lang_id = ? AND is_primary", wordID, t.LangID).Scan(&existing).Error
+		if err != nil {
+			return err
+		}
+		if existing == "" {
+			// новый перевод
+			err = tx.Exec("INSERT INTO langhelpercopy.user_words (lang_id, word_id, translation, is_primary) VALUES (?, ?, ?, TRUE)", t.LangID, wordID, t.Translation).Error
+		} else if existing != t.Translation {
+			// обновляем только если отличается
+			err = tx.Exec(`
+				UPDATE langhelpercopy.user_words SET translation = ?, updated_at = NOW(), version = version + 1
+				WHERE word_id = ? AND lang_id = ? AND is_primary
+			`, t.Translation, wordID, t.LangID).Error
+		}
+		if err != nil {
+			return err
+		}
+		if err := recordTranslationChange(tx, userID, oldWord.ID, t.LangID, existing, t.Translation); err != nil {
+			return err
+		}
+
+		// Синонимы и примеры перезаписываются целиком для каждого указанного языка
+		if err := saveAlternatives(tx, wordID, t.LangID, t.Alternatives); err != nil {
+			return err
+		}
+		if err := saveTranslationMetadata(tx, wordID, t); err != nil {
+			return err
+		}
+	}
+
+	err := tx.Exec("UPDATE langhelpercopy.words SET part_of_speech = ?, notes = ?, version = version + 1 WHERE id = ?", form.PartOfSpeech, form.Notes, wordID).Error
+	if err != nil {
+		return err
+	}
+	if err := recordFieldChange(tx, userID, models.HistoryWord, oldWord.ID, historyFieldPartOfSpeech, oldWord.PartOfSpeech, form.PartOfSpeech); err != nil {
+		return err
+	}
+	if err := recordFieldChange(tx, userID, models.HistoryWord, oldWord.ID, historyFieldNotes, oldWord.Notes, form.Notes); err != nil {
+		return err
+	}
+	return saveWordTags(tx, userID, wordID, form.TagNames)
+}
+
+// saveAlternatives заменяет неосновные переводы слова на языке новым списком.
+func saveAlternatives(db *gorm.DB, wordID string, langID uint, alternatives []string) error {
+	if err := db.Exec("DELETE FROM langhelpercopy.user_words WHERE word_id = ? AND lang_id = ? AND NOT is_primary", wordID, langID).Error; err != nil {
+		return err
+	}
+	for _, alt := range alternatives {
+		if err := db.Exec("INSERT INTO langhelpercopy.user_words (lang_id, word_id, translation, is_primary) VALUES (?, ?, ?, FALSE)", langID, wordID, alt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveTranslationMetadata обновляет метаданные основного перевода и заменяет примеры
+func saveTranslationMetadata(db *gorm.DB, wordID string, t translationInput) error {
+	err := db.Exec(`
+		UPDATE langhelpercopy.user_words SET gender = ?, pronunciation = ?, notes = ?, updated_at = NOW(), version = version + 1
+		WHERE word_id = ? AND lang_id = ? AND is_primary
+			AND (gender, pronunciation, notes) IS DISTINCT FROM (?, ?, ?)
+	`, t.Gender, t.Pronunciation, t.Notes, wordID, t.LangID, t.Gender, t.Pronunciation, t.Notes).Error
+	if err != nil {
+		return err
+	}
+	if err := db.Exec("DELETE FROM langhelpercopy.word_examples WHERE word_id = ? AND lang_id = ?", wordID, t.LangID).Error; err != nil {
+		return err
+	}
+	for _, e := range t.Examples {
+		if err := db.Exec("INSERT INTO langhelpercopy.word_examples (word_id, lang_id, sentence) VALUES (?, ?, ?)", wordID, t.LangID, e).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func DeleteWordHandler(w http.ResponseWriter, r *http.Request) {
 	session, err := config.Store.Get(r, config.SessionName)
 	if err != nil || session.Values["authenticated"] != true {
@@ -142,31 +464,43 @@ func DeleteWordHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	vars := mux.Vars(r)
-	wordID := vars["id"]
+	wordID, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid word ID", http.StatusBadRequest)
+		return
+	}
 
 	db := database.GetDB()
 
 	var count int64
-	db.Raw(`
+	err = db.Raw(`
 		SELECT COUNT(*) FROM langhelpercopy.user_words 
 		WHERE word_id = ? AND lang_id IN (
 			SELECT id FROM langhelpercopy.user_langs WHERE user_id = ?
 		)
-	`, wordID, userID).Scan(&count)
+	`, wordID, userID).Scan(&count).Error
+	if err != nil {
+		log.Printf("Failed to load word: %v", err)
+		http.Error(w, "Failed to delete word", http.StatusInternalServerError)
+		return
+	}
 
 	if count == 0 {
 		http.Error(w, "Unauthorized", http.StatusForbidden)
 		return
 	}
 
-	// Каскадное удаление связей с колодами
-	db.Exec("DELETE FROM langhelpercopy.deck_words WHERE word_id = ?", wordID)
-
-	// Удаление переводов слова
-	db.Exec("DELETE FROM langhelpercopy.user_words WHERE word_id = ?", wordID)
-
-	// Удаление самого слова
-	db.Exec("DELETE FROM langhelpercopy.words WHERE id = ?", wordID)
+	// Слово переносится в корзину; переводы и связи с колодами остаются
+	// до окончательного удаления
+	err = database.UnitOfWork(db, func(tx *gorm.DB) error {
+		_, err := bulkDeleteWords(tx, userID, []uint{uint(wordID)})
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to delete word: %v", err)
+		http.Error(w, "Failed to delete word", http.StatusInternalServerError)
+		return
+	}
 
 	http.Redirect(w, r, "/mywords", http.StatusSeeOther)
 }
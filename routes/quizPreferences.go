@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"langhelperCopy/models"
+
+	"gorm.io/gorm"
+)
+
+// loadQuizPreference возвращает сохранённые настройки тренировки или nil
+func loadQuizPreference(db *gorm.DB, userID, deckID uint) (*models.QuizPreference, error) {
+	var pref models.QuizPreference
+	err := db.Raw("SELECT * FROM langhelpercopy.quiz_preferences WHERE user_id = ? AND deck_id = ?", userID, deckID).Scan(&pref).Error
+	if err != nil || pref.ID == 0 {
+		return nil, err
+	}
+	return &pref, nil
+}
+
+// saveQuizPreference сохраняет настройки тренировки для колоды
+func saveQuizPreference(db *gorm.DB, pref models.QuizPreference) error {
+	return db.Exec(`
+		INSERT INTO langhelpercopy.quiz_preferences (user_id, deck_id, main_lang_id, target_lang_ids, direction, card_count, cloze_mode)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, deck_id) DO UPDATE SET
+			main_lang_id = EXCLUDED.main_lang_id,
+			target_lang_ids = EXCLUDED.target_lang_ids,
+			direction = EXCLUDED.direction,
+			card_count = EXCLUDED.card_count,
+			cloze_mode = EXCLUDED.cloze_mode
+	`, pref.UserID, pref.DeckID, pref.MainLangID, pref.TargetLangIDs, pref.Direction, pref.CardCount, pref.ClozeMode).Error
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"langhelperCopy/database"
+	"langhelperCopy/models"
+
+	"gorm.io/gorm"
+)
+
+// recordReview сохраняет ответ пользователя в истории повторений
+// и обновляет статистику слова и выполнение дневной цели.
+func recordReview(db *gorm.DB, review models.Review) error {
+	return database.UnitOfWork(db, func(tx *gorm.DB) error {
+		err := tx.Exec(`
+			INSERT INTO langhelpercopy.reviews (user_id, deck_id, word_id, lang_id, answer, correct)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, review.UserID, review.DeckID, review.WordID, review.LangID, truncateRunes(review.Answer, 255), review.Correct).Error
+		if err != nil {
+			return err
+		}
+		if err := updateCardProgress(tx, review); err != nil {
+			return err
+		}
+		return updateGoalDay(tx, review.UserID)
+	})
+}
@@ -16,23 +16,61 @@ func InitializeRoutes() *mux.Router {
 	router.HandleFunc("/home", HomeHandler).Methods("GET")
 	router.HandleFunc("/settings", SettingsHandler).Methods("GET", "POST")
 	router.HandleFunc("/settings/username", SettingsHandler).Methods("GET", "POST")
+	router.HandleFunc("/settings/study", SettingsHandler).Methods("GET", "POST")
+	router.HandleFunc("/settings/goal", SettingsHandler).Methods("GET", "POST")
+	router.HandleFunc("/goal/progress", GoalProgressHandler).Methods("GET")
 	router.HandleFunc("/logout", LogoutHandler).Methods("GET", "POST")
 
 	router.HandleFunc("/mylanguages", LanguagesHandler).Methods("GET", "POST")
 	router.HandleFunc("/mylanguages/edit/{id:[0-9]+}", EditLanguageHandler).Methods("GET", "POST")
 	router.HandleFunc("/mylanguages/delete/{id:[0-9]+}", DeleteLanguageHandler).Methods("GET", "POST")
+	router.HandleFunc("/mylanguages/merge", MergeLanguagesHandler).Methods("GET", "POST")
+	router.HandleFunc("/mylanguages/move/{id:[0-9]+}", MoveLanguageHandler).Methods("POST")
+	router.HandleFunc("/mylanguages/main", MainLanguageHandler).Methods("POST")
 	router.HandleFunc("/mywords", WordsHandler).Methods("GET", "POST")
 	router.HandleFunc("/mywords/delete/{id}", DeleteWordHandler).Methods("GET", "POST")
+	router.HandleFunc("/mywords/export", ExportWordsHandler).Methods("GET")
+	router.HandleFunc("/mywords/grid", WordGridHandler).Methods("GET")
+	router.HandleFunc("/mywords/grid/save", WordGridSaveHandler).Methods("POST")
+	router.HandleFunc("/mywords/grid/undo", WordGridUndoHandler).Methods("POST")
 
 	router.HandleFunc("/mydecks", DecksHandler).Methods("GET", "POST")
 	router.HandleFunc("/deck/{id:[0-9]+}", ViewDeckHandler).Methods("GET", "POST")
+	router.HandleFunc("/deck/{id:[0-9]+}/rule", UpdateSmartRuleHandler).Methods("POST")
+	router.HandleFunc("/deck/{id:[0-9]+}/rename", RenameDeckHandler).Methods("POST")
+	router.HandleFunc("/deck/{id:[0-9]+}/delete", DeleteDeckHandler).Methods("POST")
+	router.HandleFunc("/deck/{id:[0-9]+}/duplicate", DuplicateDeckHandler).Methods("POST")
+	router.HandleFunc("/deck/{id:[0-9]+}/wordstate", DeckWordStateHandler).Methods("POST")
+	router.HandleFunc("/deck/{id:[0-9]+}/stats", DeckStatsHandler).Methods("GET")
+	router.HandleFunc("/deck/{id:[0-9]+}/coverage", DeckCoverageHandler).Methods("GET")
+	router.HandleFunc("/deck/{id:[0-9]+}/fill", DeckFillHandler).Methods("GET", "POST")
+	router.HandleFunc("/deck/{id:[0-9]+}/langs/move", MoveDeckLangHandler).Methods("POST")
+	router.HandleFunc("/deck/{id:[0-9]+}/promptlang", DeckPromptLangHandler).Methods("POST")
+	router.HandleFunc("/decks/merge", MergeDecksHandler).Methods("POST")
 	router.HandleFunc("/deck/addlang/{id:[0-9]+}", AddLangToDeckHandler).Methods("POST")
 	router.HandleFunc("/deck/removelang/{deck_id:[0-9]+}/{lang_id:[0-9]+}", RemoveLangFromDeckHandler).Methods("POST")
 	router.HandleFunc("/decks/addword", AddWordToDeckHandler).Methods("POST")
 	router.HandleFunc("/decks/removeword", RemoveWordFromDeckHandler).Methods("POST")
+	router.HandleFunc("/words/bulk", BulkWordsHandler).Methods("POST")
+	router.HandleFunc("/word/{id:[0-9]+}/history", WordHistoryHandler).Methods("GET")
+	router.HandleFunc("/word/{id:[0-9]+}/history/revert", RevertTranslationHandler).Methods("POST")
+	router.HandleFunc("/trash", TrashHandler).Methods("GET")
+	router.HandleFunc("/trash/restore", RestoreTrashHandler).Methods("POST")
+	router.HandleFunc("/trash/purge", PurgeTrashHandler).Methods("POST")
+	router.HandleFunc("/trash/empty", EmptyTrashHandler).Methods("POST")
 
 	router.HandleFunc("/flashcards", FlashcardsHandler).Methods("GET", "POST")
 	router.HandleFunc("/flashcards/check", FlashcardsCheckHandler).Methods("POST")
+	router.HandleFunc("/study/{id:[0-9]+}", StudySessionHandler).Methods("GET")
+	router.HandleFunc("/study/{id:[0-9]+}/answer", StudyAnswerHandler).Methods("POST")
+	router.HandleFunc("/study/{id:[0-9]+}/next", StudyNextHandler).Methods("POST")
+	router.HandleFunc("/study/{id:[0-9]+}/match", StudyMatchHandler).Methods("POST")
+	router.HandleFunc("/study/{id:[0-9]+}/delete", DeleteStudySessionHandler).Methods("POST")
+	router.HandleFunc("/exams", ExamHistoryHandler).Methods("GET")
+	router.HandleFunc("/leeches", LeechesHandler).Methods("GET")
+	router.HandleFunc("/leeches/reset", ResetLeechHandler).Methods("POST")
+
+	router.HandleFunc("/search", SearchHandler).Methods("GET")
 
 	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
 	return router
@@ -0,0 +1,181 @@
+package routes
+
+import (
+	"encoding/json"
+	"html/template"
+	"langhelperCopy/config"
+	"langhelperCopy/database"
+	"langhelperCopy/models"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type SearchTranslation struct {
+	LangTitle      string `json:"lang"`
+	Translation    string `json:"translation"`
+	models.LangTag `json:"-"`
+}
+
+type SearchDeck struct {
+	ID        uint   `json:"id"`
+	DeckTitle string `json:"title"`
+}
+
+type SearchResult struct {
+	WordID       uint                `json:"word_id"`
+	Score        float64             `json:"score"`
+	Translations []SearchTranslation `json:"translations"`
+	Decks        []SearchDeck        `json:"decks"`
+}
+
+const searchLimit = 30
+
+// SearchHandler ищет слова пользователя по всем переводам с учётом опечаток
+// и диакритики. С параметром format=json отдаёт подсказки для поля в шапке.
+func SearchHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	asJSON := r.URL.Query().Get("format") == "json"
+
+	results := make([]SearchResult, 0)
+	if query != "" {
+		results, err = searchWords(userID, query)
+		if err != nil {
+			log.Printf("Search failed: %v", err)
+			http.Error(w, "Search failed", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if asJSON {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(results); err != nil {
+			log.Printf("JSON encode error: %v", err)
+		}
+		return
+	}
+
+	data := struct {
+		Title   string
+		Query   string
+		Results []SearchResult
+	}{
+		Title:   "Search",
+		Query:   query,
+		Results: results,
+	}
+
+	tmpl, err := template.ParseFiles("templates/layout.html", "templates/search.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+	}
+}
+
+// searchWords возвращает слова, у которых хотя бы один перевод похож на запрос,
+// отсортированные по лучшему сходству среди переводов. Для поиска подстроки
+// символы %, _ и \ в запросе экранируются, чтобы они не работали как шаблон LIKE.
+func searchWords(userID uint, query string) ([]SearchResult, error) {
+	db := database.GetDB()
+
+	var matches []struct {
+		WordID uint
+		Score  float64
+	}
+	err := db.Raw(`
+		SELECT uw.word_id, MAX(similarity(langhelpercopy.unaccent_lower(uw.translation), q.term)) AS score
+		FROM langhelpercopy.user_words uw
+		JOIN langhelpercopy.user_langs ul ON uw.lang_id = ul.id
+		JOIN langhelpercopy.words w ON uw.word_id = w.id
+		CROSS JOIN (
+			SELECT t.term, REPLACE(REPLACE(REPLACE(t.term, '\', '\\'), '%', '\%'), '_', '\_') AS pattern
+			FROM (SELECT langhelpercopy.unaccent_lower(?) AS term) t
+		) q
+		WHERE ul.user_id = ? AND ul.deleted_at IS NULL AND w.deleted_at IS NULL
+		AND (langhelpercopy.unaccent_lower(uw.translation) % q.term
+			OR langhelpercopy.unaccent_lower(uw.translation) LIKE '%' || q.pattern || '%' ESCAPE '\')
+		GROUP BY uw.word_id
+		ORDER BY score DESC, uw.word_id
+		LIMIT ?
+	`, query, userID, searchLimit).Scan(&matches).Error
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(matches))
+	if len(matches) == 0 {
+		return results, nil
+	}
+
+	wordIDs := make([]uint, len(matches))
+	index := make(map[uint]int, len(matches))
+	for i, m := range matches {
+		wordIDs[i] = m.WordID
+		index[m.WordID] = i
+		results = append(results, SearchResult{WordID: m.WordID, Score: m.Score})
+	}
+
+	var translations []struct {
+		WordID      uint
+		LangTitle   string
+		Translation string
+		models.LangTag
+	}
+	err = db.Raw(`
+		SELECT uw.word_id, ul.lang_title, ul.lang_code, uw.translation
+		FROM langhelpercopy.user_words uw
+		JOIN langhelpercopy.user_langs ul ON uw.lang_id = ul.id
+		WHERE uw.word_id IN (?) AND ul.user_id = ? AND ul.deleted_at IS NULL
+		ORDER BY ul.lang_title, uw.is_primary DESC, uw.id
+	`, wordIDs, userID).Scan(&translations).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range translations {
+		i := index[t.WordID]
+		results[i].Translations = append(results[i].Translations, SearchTranslation{
+			LangTitle:   t.LangTitle,
+			Translation: t.Translation,
+			LangTag:     t.LangTag,
+		})
+	}
+
+	var decks []struct {
+		WordID    uint
+		ID        uint
+		DeckTitle string
+	}
+	err = db.Raw(`
+		SELECT dw.word_id, d.id, d.deck_title
+		FROM langhelpercopy.deck_words dw
+		JOIN langhelpercopy.decks d ON dw.deck_id = d.id
+		WHERE dw.word_id IN (?) AND d.user_id = ? AND d.deleted_at IS NULL
+		ORDER BY d.deck_title
+	`, wordIDs, userID).Scan(&decks).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range decks {
+		i := index[d.WordID]
+		results[i].Decks = append(results[i].Decks, SearchDeck{ID: d.ID, DeckTitle: d.DeckTitle})
+	}
+
+	return results, nil
+}
@@ -10,6 +10,7 @@ import (
 	"langhelperCopy/database"
 	"langhelperCopy/models"
 
+	"strconv"
 	"strings"
 
 	"github.com/gorilla/sessions"
@@ -200,7 +201,7 @@ func HomeHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Получаем статистику пользователя
 	var deckCount int64
-	row := db.Raw("SELECT COUNT(*) FROM langhelpercopy.decks WHERE user_id = ?", userID).Row()
+	row := db.Raw("SELECT COUNT(*) FROM langhelpercopy.decks WHERE user_id = ? AND deleted_at IS NULL", userID).Row()
 	if err := row.Scan(&deckCount); err != nil {
 		log.Printf("Failed to get deck count: %v", err)
 		deckCount = 0
@@ -211,23 +212,31 @@ func HomeHandler(w http.ResponseWriter, r *http.Request) {
 		SELECT COUNT(dw.id) 
 		FROM langhelpercopy.deck_words dw
 		INNER JOIN langhelpercopy.decks d ON dw.deck_id = d.id
-		WHERE d.user_id = ?
+		INNER JOIN langhelpercopy.words w ON dw.word_id = w.id
+		WHERE d.user_id = ? AND d.deleted_at IS NULL AND w.deleted_at IS NULL
 	`, userID).Scan(&cardCount).Error
 	if err != nil {
 		log.Printf("Failed to get card count: %v", err)
 		cardCount = 0
 	}
 
+	dashboard, err := loadDashboard(db, userID)
+	if err != nil {
+		log.Printf("Failed to load dashboard: %v", err)
+	}
+
 	data := struct {
 		Title     string
 		Username  string
 		DeckCount int64
 		CardCount int64
+		Dashboard Dashboard
 	}{
 		Title:     "Home",
 		Username:  session.Values["username"].(string),
 		DeckCount: deckCount,
 		CardCount: cardCount,
+		Dashboard: dashboard,
 	}
 
 	tmpl, err := template.ParseFiles("templates/layout.html", "templates/home.html")
@@ -249,6 +258,16 @@ type SettingsData struct {
 	NewUsername     string
 	SuccessMessage  string
 	ErrorMessage    string
+
+	LeechThreshold   int
+	LeechAutoSuspend bool
+
+	Timezone      string
+	DailyGoalType string
+	DailyGoal     int
+	StreakFreezes int
+
+	Version int // версия пользователя, которую отправляют формы настроек
 }
 
 func SettingsHandler(w http.ResponseWriter, r *http.Request) {
@@ -278,11 +297,139 @@ func SettingsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	renderSettingsPage(w, SettingsData{
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/settings/study") {
+		handleStudySettings(w, r, userID, currentUsername)
+		return
+	}
+
+	if r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/settings/goal") {
+		handleGoalSettings(w, r, userID, currentUsername)
+		return
+	}
+
+	renderSettingsPage(w, userID, SettingsData{
 		CurrentUsername: currentUsername,
 	})
 }
 
+// handleStudySettings сохраняет настройки тренировок: порог ошибок для
+// пометки "leech" и автоматическое отключение таких слов в колоде.
+func handleStudySettings(w http.ResponseWriter, r *http.Request, userID uint, currentUsername string) {
+	data := SettingsData{
+		Title:           "Settings",
+		CurrentUsername: currentUsername,
+	}
+
+	if err := r.ParseForm(); err != nil {
+		data.ErrorMessage = "Invalid form data"
+		renderSettingsPage(w, userID, data)
+		return
+	}
+
+	threshold, err := strconv.Atoi(strings.TrimSpace(r.FormValue("leech_threshold")))
+	if err == nil {
+		err = models.ValidateLeechThreshold(threshold)
+	}
+	if err != nil {
+		data.ErrorMessage = "Leech threshold must be a number between 0 and 100"
+		renderSettingsPage(w, userID, data)
+		return
+	}
+	autoSuspend := r.FormValue("leech_auto_suspend") == "on"
+	version, err := formVersion(r)
+	if err != nil {
+		data.ErrorMessage = "Invalid form data"
+		renderSettingsPage(w, userID, data)
+		return
+	}
+
+	db := database.GetDB()
+	result := db.Exec(`
+		UPDATE langhelpercopy.users
+		SET leech_threshold = ?, leech_auto_suspend = ?, updated_at = NOW(), version = version + 1
+		WHERE id = ? AND version = ?`,
+		threshold, autoSuspend, userID, version)
+	if result.Error != nil {
+		log.Printf("Failed to update study settings: %v", result.Error)
+		data.ErrorMessage = "Failed to update study settings"
+		renderSettingsPage(w, userID, data)
+		return
+	}
+	if result.RowsAffected == 0 {
+		renderConflict(w, "Your account settings", "/settings")
+		return
+	}
+
+	data.SuccessMessage = "Study settings saved!"
+	renderSettingsPage(w, userID, data)
+}
+
+// handleGoalSettings сохраняет дневную цель и часовой пояс, по которому
+// считаются дни для цели и серии.
+func handleGoalSettings(w http.ResponseWriter, r *http.Request, userID uint, currentUsername string) {
+	data := SettingsData{
+		Title:           "Settings",
+		CurrentUsername: currentUsername,
+	}
+
+	if err := r.ParseForm(); err != nil {
+		data.ErrorMessage = "Invalid form data"
+		renderSettingsPage(w, userID, data)
+		return
+	}
+
+	goalType := r.FormValue("daily_goal_type")
+	goal, err := strconv.Atoi(strings.TrimSpace(r.FormValue("daily_goal")))
+	if err == nil {
+		err = models.ValidateDailyGoal(goalType, goal)
+	}
+	if err != nil {
+		data.ErrorMessage = "Daily goal must be 1-1000 cards or 1-600 minutes"
+		renderSettingsPage(w, userID, data)
+		return
+	}
+
+	timezone := strings.TrimSpace(r.FormValue("timezone"))
+	if err := models.ValidateTimezone(timezone); err != nil {
+		data.ErrorMessage = "Unknown time zone"
+		renderSettingsPage(w, userID, data)
+		return
+	}
+
+	version, err := formVersion(r)
+	if err != nil {
+		data.ErrorMessage = "Invalid form data"
+		renderSettingsPage(w, userID, data)
+		return
+	}
+
+	db := database.GetDB()
+	result := db.Exec(`
+		UPDATE langhelpercopy.users
+		SET daily_goal_type = ?, daily_goal = ?, timezone = ?, updated_at = NOW(), version = version + 1
+		WHERE id = ? AND version = ?`,
+		goalType, goal, timezone, userID, version)
+	if result.Error != nil {
+		log.Printf("Failed to update daily goal: %v", result.Error)
+		data.ErrorMessage = "Failed to update daily goal"
+		renderSettingsPage(w, userID, data)
+		return
+	}
+	if result.RowsAffected == 0 {
+		renderConflict(w, "Your account settings", "/settings")
+		return
+	}
+
+	data.SuccessMessage = "Daily goal saved!"
+	renderSettingsPage(w, userID, data)
+}
+
 func handleUsernameChange(w http.ResponseWriter, r *http.Request, session *sessions.Session, currentUsername string) {
 	userID, ok := session.Values["user_id"].(uint)
 	if !ok {
@@ -291,7 +438,7 @@ func handleUsernameChange(w http.ResponseWriter, r *http.Request, session *sessi
 	}
 
 	if err := r.ParseForm(); err != nil {
-		renderSettingsPage(w, SettingsData{
+		renderSettingsPage(w, userID, SettingsData{
 			Title:           "Settings",
 			CurrentUsername: currentUsername,
 			ErrorMessage:    "Invalid form data",
@@ -308,20 +455,27 @@ func handleUsernameChange(w http.ResponseWriter, r *http.Request, session *sessi
 	// Валидация
 	if data.NewUsername == "" {
 		data.ErrorMessage = "New username is required"
-		renderSettingsPage(w, data)
+		renderSettingsPage(w, userID, data)
 		return
 	}
 
 	if len(data.NewUsername) < 3 || len(data.NewUsername) > 20 {
 		data.ErrorMessage = "Username must be between 3 and 20 characters"
-		renderSettingsPage(w, data)
+		renderSettingsPage(w, userID, data)
 		return
 	}
 
 	password := r.FormValue("password")
 	if password == "" {
 		data.ErrorMessage = "Password is required"
-		renderSettingsPage(w, data)
+		renderSettingsPage(w, userID, data)
+		return
+	}
+
+	version, err := formVersion(r)
+	if err != nil {
+		data.ErrorMessage = "Invalid form data"
+		renderSettingsPage(w, userID, data)
 		return
 	}
 
@@ -338,13 +492,13 @@ func handleUsernameChange(w http.ResponseWriter, r *http.Request, session *sessi
 		}
 		log.Printf("Database error: %v", err)
 		data.ErrorMessage = "Internal server error"
-		renderSettingsPage(w, data)
+		renderSettingsPage(w, userID, data)
 		return
 	}
 
 	if err := models.ComparePassword(user.Password, password); err != nil {
 		data.ErrorMessage = "Incorrect password"
-		renderSettingsPage(w, data)
+		renderSettingsPage(w, userID, data)
 		return
 	}
 
@@ -354,26 +508,30 @@ func handleUsernameChange(w http.ResponseWriter, r *http.Request, session *sessi
 	if err := row.Scan(&count); err != nil {
 		log.Printf("Database error: %v", err)
 		data.ErrorMessage = "Internal server error"
-		renderSettingsPage(w, data)
+		renderSettingsPage(w, userID, data)
 		return
 	}
 
 	if count > 0 {
 		data.ErrorMessage = "Username already taken"
-		renderSettingsPage(w, data)
+		renderSettingsPage(w, userID, data)
 		return
 	}
 
 	// Обновление username
 	res := db.Exec(`
 	UPDATE langhelpercopy.users 
-	SET username = ? 
-	WHERE id = ?`,
-		data.NewUsername, user.ID)
+	SET username = ?, updated_at = NOW(), version = version + 1
+	WHERE id = ? AND version = ?`,
+		data.NewUsername, user.ID, version)
 	if res.Error != nil {
 		log.Printf("Failed to update username: %v", res.Error)
 		data.ErrorMessage = "Failed to update username"
-		renderSettingsPage(w, data)
+		renderSettingsPage(w, userID, data)
+		return
+	}
+	if res.RowsAffected == 0 {
+		renderConflict(w, "Your account settings", "/settings")
 		return
 	}
 
@@ -382,17 +540,33 @@ func handleUsernameChange(w http.ResponseWriter, r *http.Request, session *sessi
 	if err := session.Save(r, w); err != nil {
 		log.Printf("Failed to save session: %v", err)
 		data.ErrorMessage = "Failed to update session"
-		renderSettingsPage(w, data)
+		renderSettingsPage(w, userID, data)
 		return
 	}
 
 	data.CurrentUsername = data.NewUsername
 	data.NewUsername = ""
 	data.SuccessMessage = "Username successfully updated!"
-	renderSettingsPage(w, data)
+	renderSettingsPage(w, userID, data)
 }
 
-func renderSettingsPage(w http.ResponseWriter, data SettingsData) {
+func renderSettingsPage(w http.ResponseWriter, userID uint, data SettingsData) {
+	// Настройки тренировок всегда показываются из базы
+	var user models.User
+	err := database.GetDB().Raw(`
+		SELECT id, leech_threshold, leech_auto_suspend, timezone, daily_goal_type, daily_goal, streak_freezes, version
+		FROM langhelpercopy.users WHERE id = ?`, userID).Scan(&user).Error
+	if err != nil {
+		log.Printf("Failed to load study settings: %v", err)
+	}
+	data.LeechThreshold = user.LeechThreshold
+	data.LeechAutoSuspend = user.LeechAutoSuspend
+	data.Timezone = user.Timezone
+	data.DailyGoalType = user.DailyGoalType
+	data.DailyGoal = user.DailyGoal
+	data.StreakFreezes = user.StreakFreezes
+	data.Version = user.Version
+
 	tmpl, err := template.ParseFiles("templates/layout.html", "templates/settings.html")
 	if err != nil {
 		log.Printf("Template error: %v", err)
@@ -0,0 +1,163 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"langhelperCopy/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// deckWordIDs возвращает слова колоды: для обычной колоды из deck_words,
+// для умной - вычисленные по её правилу.
+func deckWordIDs(db *gorm.DB, deck models.Deck) ([]uint, error) {
+	if deck.IsSmart() {
+		rule, err := models.ParseSmartRule(deck.SmartRule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid smart rule for deck %d: %w", deck.ID, err)
+		}
+		return smartDeckWordIDs(db, deck.UserID, rule)
+	}
+
+	var wordIDs []uint
+	err := db.Raw(`
+		SELECT dw.word_id FROM langhelpercopy.deck_words dw
+		JOIN langhelpercopy.words w ON w.id = dw.word_id
+		WHERE dw.deck_id = ? AND w.deleted_at IS NULL
+		ORDER BY dw.id
+	`, deck.ID).Scan(&wordIDs).Error
+	return wordIDs, err
+}
+
+// smartDeckWordIDs подбирает слова пользователя, подходящие под правило:
+// есть переводы на все языки правила, есть хотя бы одна из меток,
+// добавлены не раньше даты и точность ответов ниже порога.
+func smartDeckWordIDs(db *gorm.DB, userID uint, rule models.SmartRule) ([]uint, error) {
+	conditions := []string{"ul.user_id = ?", "ul.deleted_at IS NULL", "w.deleted_at IS NULL"}
+	args := []interface{}{userID}
+
+	if len(rule.LangIDs) > 0 {
+		conditions = append(conditions, `uw.word_id IN (
+			SELECT word_id FROM langhelpercopy.user_words
+			WHERE lang_id IN (?) AND is_primary
+			GROUP BY word_id
+			HAVING COUNT(DISTINCT lang_id) = ?)`)
+		args = append(args, rule.LangIDs, len(rule.LangIDs))
+	}
+
+	if len(rule.TagIDs) > 0 {
+		conditions = append(conditions, `uw.word_id IN (
+			SELECT wt.word_id FROM langhelpercopy.word_tags wt
+			JOIN langhelpercopy.tags t ON wt.tag_id = t.id
+			WHERE wt.tag_id IN (?) AND t.user_id = ?)`)
+		args = append(args, rule.TagIDs, userID)
+	}
+
+	if rule.AddedAfter != "" {
+		conditions = append(conditions, "w.created_at >= ?::date")
+		args = append(args, rule.AddedAfter)
+	}
+
+	if rule.MaxAccuracy > 0 {
+		conditions = append(conditions, `uw.word_id IN (
+			SELECT word_id FROM langhelpercopy.reviews
+			WHERE user_id = ?
+			GROUP BY word_id
+			HAVING AVG(CASE WHEN correct THEN 100.0 ELSE 0 END) < ?)`)
+		args = append(args, userID, rule.MaxAccuracy)
+	}
+
+	var wordIDs []uint
+	err := db.Raw(`
+		SELECT DISTINCT uw.word_id
+		FROM langhelpercopy.user_words uw
+		JOIN langhelpercopy.user_langs ul ON uw.lang_id = ul.id
+		JOIN langhelpercopy.words w ON uw.word_id = w.id
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY uw.word_id
+	`, args...).Scan(&wordIDs).Error
+	return wordIDs, err
+}
+
+// parseSmartRuleForm собирает правило из формы, оставляя только языки
+// и метки, принадлежащие пользователю.
+func parseSmartRuleForm(r *http.Request, userLangs []models.UserLang, tags []models.Tag) (models.SmartRule, error) {
+	var rule models.SmartRule
+
+	ownLangs := make(map[uint]bool, len(userLangs))
+	for _, l := range userLangs {
+		ownLangs[l.ID] = true
+	}
+	for _, raw := range r.Form["rule_lang_ids"] {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err == nil && ownLangs[uint(id)] {
+			rule.LangIDs = append(rule.LangIDs, uint(id))
+		}
+	}
+
+	ownTags := make(map[uint]bool, len(tags))
+	for _, t := range tags {
+		ownTags[t.ID] = true
+	}
+	for _, raw := range r.Form["rule_tag_ids"] {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err == nil && ownTags[uint(id)] {
+			rule.TagIDs = append(rule.TagIDs, uint(id))
+		}
+	}
+
+	rule.AddedAfter = strings.TrimSpace(r.FormValue("rule_added_after"))
+
+	if raw := strings.TrimSpace(r.FormValue("rule_max_accuracy")); raw != "" {
+		accuracy, err := strconv.Atoi(raw)
+		if err != nil {
+			return rule, errors.New("accuracy must be a number")
+		}
+		rule.MaxAccuracy = accuracy
+	}
+
+	return rule, rule.Validate()
+}
+
+// syncSmartDeckLangs приводит языки умной колоды к языкам её правила
+func syncSmartDeckLangs(db *gorm.DB, deckID uint, langIDs []uint) error {
+	if err := db.Exec("DELETE FROM langhelpercopy.deck_langs WHERE deck_id = ? AND lang_id NOT IN (?)", deckID, langIDs).Error; err != nil {
+		return err
+	}
+	for _, langID := range langIDs {
+		err := db.Exec(`
+			INSERT INTO langhelpercopy.deck_langs (deck_id, lang_id, position)
+			SELECT ?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM langhelpercopy.deck_langs WHERE deck_id = ?)
+			WHERE NOT EXISTS (
+				SELECT 1 FROM langhelpercopy.deck_langs WHERE deck_id = ? AND lang_id = ?
+			)
+		`, deckID, langID, deckID, deckID, langID).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// smartRuleFuncs используются шаблоном smartRule.html
+var smartRuleFuncs = template.FuncMap{
+	"ruleHasLang": func(rule *models.SmartRule, id uint) bool {
+		return containsID(rule.LangIDs, id)
+	},
+	"ruleHasTag": func(rule *models.SmartRule, id uint) bool {
+		return containsID(rule.TagIDs, id)
+	},
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
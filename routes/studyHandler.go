@@ -0,0 +1,619 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"langhelperCopy/config"
+	"langhelperCopy/database"
+	"langhelperCopy/models"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+var errStudySessionNotFound = errors.New("study session not found")
+
+// StudyCardView - карточка сессии вместе с данными для показа
+type StudyCardView struct {
+	models.StudyCard
+	LangTitle    string
+	Options      []StudyOption
+	Alternatives []string
+	Details      TranslationDetails
+	PromptLang   models.LangTag // язык вопроса
+	AnswerLang   models.LangTag // язык вариантов и ответа
+}
+
+// newStudyCardView определяет языки вопроса и ответа карточки: в обратном
+// направлении и в примерах с пропуском вопрос задаётся на изучаемом языке.
+func newStudyCardView(card models.StudyCard, langs map[uint]models.UserLang, mainLangID uint) StudyCardView {
+	mainTag := models.LangTag{LangCode: langs[mainLangID].LangCode}
+	targetTag := models.LangTag{LangCode: langs[card.LangID].LangCode}
+	view := StudyCardView{
+		StudyCard:  card,
+		LangTitle:  langs[card.LangID].LangTitle,
+		PromptLang: mainTag,
+		AnswerLang: targetTag,
+	}
+	if card.Reverse {
+		view.PromptLang, view.AnswerLang = targetTag, mainTag
+	} else if card.Cloze {
+		view.PromptLang = targetTag
+	}
+	return view
+}
+
+// StudyOption - вариант ответа с номером клавиши для быстрого выбора
+type StudyOption struct {
+	Key  int
+	Text string
+}
+
+// StudySessionSummary - незавершённая сессия в списке на странице тренировок
+type StudySessionSummary struct {
+	ID        uint
+	DeckTitle string
+	Mode      string
+	Position  int
+	Total     int
+	UpdatedAt time.Time
+}
+
+// Пределы ограничений времени экзамена
+const (
+	maxExamMinutes     = 600
+	maxExamCardSeconds = 3600
+	// examTimeGrace учитывает задержку сети, чтобы ответ в последнюю секунду засчитывался
+	examTimeGrace = 2 * time.Second
+)
+
+// parseExamLimits читает ограничения времени экзамена из формы; 0 - без ограничения
+func parseExamLimits(r *http.Request) (totalSec, cardSec int, err error) {
+	minutes, err := parseOptionalInt(r.FormValue("exam_minutes"))
+	if err != nil || minutes < 0 || minutes > maxExamMinutes {
+		return 0, 0, fmt.Errorf("exam time limit must be between 0 and %d minutes", maxExamMinutes)
+	}
+	seconds, err := parseOptionalInt(r.FormValue("exam_card_seconds"))
+	if err != nil || seconds < 0 || seconds > maxExamCardSeconds {
+		return 0, 0, fmt.Errorf("time per card must be between 0 and %d seconds", maxExamCardSeconds)
+	}
+	return minutes * 60, seconds, nil
+}
+
+func parseOptionalInt(raw string) (int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(raw)
+}
+
+// createStudySession сохраняет тесты как карточки новой сессии, по одной на слово и язык
+func createStudySession(db *gorm.DB, study models.StudySession, wordTests []WordTest) (uint, error) {
+	var sessionID uint
+	err := database.UnitOfWork(db, func(tx *gorm.DB) error {
+		// Время начала задаётся здесь же, с ним сравниваются ограничения экзамена
+		now := time.Now()
+		err := tx.Raw(`
+			INSERT INTO langhelpercopy.study_sessions
+				(user_id, deck_id, main_lang_id, mode, status, time_limit_sec, card_time_limit_sec, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id
+		`, study.UserID, study.DeckID, study.MainLangID, study.Mode, models.StudySessionActive,
+			study.TimeLimitSec, study.CardTimeLimitSec, now, now).Scan(&sessionID).Error
+		if err != nil {
+			return err
+		}
+
+		position := 0
+		for _, wt := range wordTests {
+			for _, lt := range wt.Tests {
+				card := models.StudyCard{Prompt: lt.Prompt}
+				if err := card.SetOptions(lt.Options); err != nil {
+					return err
+				}
+				err := tx.Exec(`
+					INSERT INTO langhelpercopy.study_cards (session_id, position, word_id, lang_id, reverse, cloze, typed, prompt, options, expected)
+					VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				`, sessionID, position, wt.WordID, lt.DeckLang.LangID, lt.Reverse, lt.Cloze, lt.Typed,
+					truncateRunes(lt.Prompt, 255), card.Options, truncateRunes(lt.Correct, 255)).Error
+				if err != nil {
+					return err
+				}
+				position++
+			}
+		}
+		return nil
+	})
+	return sessionID, err
+}
+
+// advanceStudySession переходит от отвеченной карточки position к следующей;
+// после последней карточки сессия завершается. Если позиция уже сменилась
+// (двойное нажатие), ничего не происходит.
+func advanceStudySession(db *gorm.DB, sessionID uint, position int) error {
+	return db.Exec(`
+		UPDATE langhelpercopy.study_sessions s
+		SET position = s.position + 1,
+			updated_at = NOW(),
+			card_shown_at = NULL,
+			status = CASE WHEN s.position + 1 >= (SELECT COUNT(*) FROM langhelpercopy.study_cards WHERE session_id = s.id) THEN ? ELSE s.status END,
+			finished_at = CASE WHEN s.position + 1 >= (SELECT COUNT(*) FROM langhelpercopy.study_cards WHERE session_id = s.id) THEN NOW() ELSE s.finished_at END
+		WHERE s.id = ? AND s.position = ? AND EXISTS (
+			SELECT 1 FROM langhelpercopy.study_cards c
+			WHERE c.session_id = s.id AND c.position = s.position AND c.answered
+		)
+	`, models.StudySessionFinished, sessionID, position).Error
+}
+
+// enforceTimeLimits завершает сессию, если истекло общее время, или
+// засчитывает текущую карточку как неотвеченную, если истекло время на неё.
+// Возвращает true, если состояние сессии изменилось.
+func enforceTimeLimits(db *gorm.DB, study models.StudySession, userID uint) (bool, error) {
+	if study.Status != models.StudySessionActive {
+		return false, nil
+	}
+	now := time.Now()
+
+	if deadline, ok := study.Deadline(); ok && now.After(deadline.Add(examTimeGrace)) {
+		err := database.UnitOfWork(db, func(tx *gorm.DB) error {
+			var cards []models.StudyCard
+			err := tx.Raw(`
+				UPDATE langhelpercopy.study_cards
+				SET answered = true, timed_out = true, correct = false, answered_at = NOW()
+				WHERE session_id = ? AND NOT answered
+				RETURNING *
+			`, study.ID).Scan(&cards).Error
+			if err != nil {
+				return err
+			}
+
+			// Все неотвеченные карточки попадают в историю как ошибки
+			for _, card := range cards {
+				if err := recordReview(tx, timedOutReview(study, card, userID)); err != nil {
+					return err
+				}
+			}
+			return tx.Exec(`
+				UPDATE langhelpercopy.study_sessions
+				SET status = ?, finished_at = NOW(), updated_at = NOW(), card_shown_at = NULL,
+					position = (SELECT COUNT(*) FROM langhelpercopy.study_cards WHERE session_id = ?)
+				WHERE id = ?
+			`, models.StudySessionFinished, study.ID, study.ID).Error
+		})
+		return true, err
+	}
+
+	if deadline, ok := study.CardDeadline(); ok && now.After(deadline.Add(examTimeGrace)) {
+		err := database.UnitOfWork(db, func(tx *gorm.DB) error {
+			var card models.StudyCard
+			err := tx.Raw(`
+				UPDATE langhelpercopy.study_cards
+				SET answered = true, timed_out = true, correct = false, answered_at = NOW()
+				WHERE session_id = ? AND position = ? AND NOT answered
+				RETURNING *
+			`, study.ID, study.Position).Scan(&card).Error
+			if err != nil {
+				return err
+			}
+
+			// Карточку видели, но не ответили - это ошибка в истории повторений
+			if card.ID != 0 {
+				if err := recordReview(tx, timedOutReview(study, card, userID)); err != nil {
+					return err
+				}
+			}
+			return advanceStudySession(tx, study.ID, study.Position)
+		})
+		return true, err
+	}
+
+	return false, nil
+}
+
+// timedOutReview описывает карточку, время на которую истекло, как неверный ответ
+func timedOutReview(study models.StudySession, card models.StudyCard, userID uint) models.Review {
+	answerLangID := card.LangID
+	if card.Reverse {
+		answerLangID = study.MainLangID
+	}
+	return models.Review{
+		UserID: userID,
+		DeckID: study.DeckID,
+		WordID: card.WordID,
+		LangID: answerLangID,
+	}
+}
+
+// loadStudySession загружает сессию, только если она принадлежит пользователю
+func loadStudySession(db *gorm.DB, sessionID, userID uint) (models.StudySession, error) {
+	var session models.StudySession
+	err := db.Raw("SELECT * FROM langhelpercopy.study_sessions WHERE id = ? AND user_id = ?", sessionID, userID).Scan(&session).Error
+	if err != nil {
+		return session, err
+	}
+	if session.ID == 0 {
+		return session, errStudySessionNotFound
+	}
+	return session, nil
+}
+
+func loadStudyCards(db *gorm.DB, sessionID uint) ([]models.StudyCard, error) {
+	var cards []models.StudyCard
+	err := db.Raw("SELECT * FROM langhelpercopy.study_cards WHERE session_id = ? ORDER BY position", sessionID).Scan(&cards).Error
+	return cards, err
+}
+
+// loadActiveStudySessions возвращает незавершённые сессии пользователя, последние сверху
+func loadActiveStudySessions(db *gorm.DB, userID uint) ([]StudySessionSummary, error) {
+	var sessions []StudySessionSummary
+	err := db.Raw(`
+		SELECT s.id, d.deck_title, s.mode, s.position, COUNT(c.id) AS total, s.updated_at
+		FROM langhelpercopy.study_sessions s
+		JOIN langhelpercopy.decks d ON s.deck_id = d.id
+		LEFT JOIN langhelpercopy.study_cards c ON c.session_id = s.id
+		WHERE s.user_id = ? AND s.status = ? AND d.deleted_at IS NULL
+		GROUP BY s.id, d.deck_title, s.mode, s.position, s.updated_at
+		ORDER BY s.updated_at DESC
+	`, userID, models.StudySessionActive).Scan(&sessions).Error
+	return sessions, err
+}
+
+// studyRequest разбирает сессию пользователя и ID учебной сессии из URL.
+// При ошибке ответ уже отправлен и ok == false.
+func studyRequest(w http.ResponseWriter, r *http.Request) (study models.StudySession, userID uint, ok bool) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return study, 0, false
+	}
+
+	userID, ok = session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return study, 0, false
+	}
+
+	sessionID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return study, 0, false
+	}
+
+	study, err = loadStudySession(database.GetDB(), uint(sessionID), userID)
+	if err != nil {
+		http.Error(w, "Session not found or access denied", http.StatusNotFound)
+		return study, 0, false
+	}
+	return study, userID, true
+}
+
+// StudySessionHandler показывает текущую карточку сессии, результат ответа на неё
+// или итоги, если все карточки пройдены.
+func StudySessionHandler(w http.ResponseWriter, r *http.Request) {
+	study, userID, ok := studyRequest(w, r)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+
+	// Ограничения времени проверяются на сервере при каждом показе
+	changed, err := enforceTimeLimits(db, study, userID)
+	if err != nil {
+		log.Printf("Failed to apply time limits: %v", err)
+		http.Error(w, "Failed to load session", http.StatusInternalServerError)
+		return
+	}
+	if changed {
+		http.Redirect(w, r, fmt.Sprintf("/study/%d", study.ID), http.StatusSeeOther)
+		return
+	}
+
+	deck, err := loadUserDeck(db, study.DeckID, userID)
+	if err != nil {
+		http.Error(w, "Deck not found or access denied", http.StatusNotFound)
+		return
+	}
+
+	cards, err := loadStudyCards(db, study.ID)
+	if err != nil {
+		log.Printf("Failed to load study cards: %v", err)
+		http.Error(w, "Failed to load session", http.StatusInternalServerError)
+		return
+	}
+
+	var langs []models.UserLang
+	err = db.Raw("SELECT * FROM langhelpercopy.user_langs WHERE user_id = ?", userID).Scan(&langs).Error
+	if err != nil {
+		log.Printf("Failed to load languages: %v", err)
+		http.Error(w, "Failed to load languages", http.StatusInternalServerError)
+		return
+	}
+	langTitles := make(map[uint]string, len(langs))
+	langByID := make(map[uint]models.UserLang, len(langs))
+	for _, l := range langs {
+		langTitles[l.ID] = l.LangTitle
+		langByID[l.ID] = l
+	}
+
+	// Отсчёт времени на карточку начинается с первого показа
+	if study.Status == models.StudySessionActive && study.CardShownAt == nil && study.Position < len(cards) {
+		now := time.Now()
+		err := db.Exec("UPDATE langhelpercopy.study_sessions SET card_shown_at = ? WHERE id = ? AND card_shown_at IS NULL", now, study.ID).Error
+		if err != nil {
+			log.Printf("Failed to start card timer: %v", err)
+		}
+		study.CardShownAt = &now
+	}
+
+	answered, correct := 0, 0
+	for _, c := range cards {
+		if c.Answered {
+			answered++
+		}
+		if c.Correct {
+			correct++
+		}
+	}
+
+	data := struct {
+		Title          string
+		Session        models.StudySession
+		DeckTitle      string
+		MainLangTitle  string
+		LangTitles     map[uint]string
+		Card           *StudyCardView
+		Number         int
+		Total          int
+		Answered       int
+		CorrectCount   int
+		Cards          []StudyCardView
+		Exam           bool
+		DeadlineMs     int64 // окончание сессии в мс Unix, 0 - без ограничения
+		CardDeadlineMs int64
+		Percent        int
+		Duration       string
+		MatchCards     []models.StudyCard // игра на пары: левая колонка
+		MatchOptions   []string           // игра на пары: перемешанная правая колонка
+		MatchLangTitle string
+		MatchPromptTag models.LangTag
+		MatchOptionTag models.LangTag
+	}{
+		Title:         "Study",
+		Session:       study,
+		DeckTitle:     deck.DeckTitle,
+		MainLangTitle: langTitles[study.MainLangID],
+		LangTitles:    langTitles,
+		Number:        study.Position + 1,
+		Total:         len(cards),
+		Answered:      answered,
+		CorrectCount:  correct,
+		Exam:          study.IsExam(),
+	}
+	if deadline, ok := study.Deadline(); ok {
+		data.DeadlineMs = deadline.UnixMilli()
+	}
+	if deadline, ok := study.CardDeadline(); ok {
+		data.CardDeadlineMs = deadline.UnixMilli()
+	}
+
+	if study.IsMatch() && study.Status == models.StudySessionActive && len(cards) > 0 {
+		data.MatchCards = cards
+		data.MatchOptions = cards[0].OptionList()
+		data.MatchLangTitle = langTitles[cards[0].LangID]
+		data.MatchPromptTag = models.LangTag{LangCode: langByID[study.MainLangID].LangCode}
+		data.MatchOptionTag = models.LangTag{LangCode: langByID[cards[0].LangID].LangCode}
+	} else if study.Position < len(cards) {
+		card := cards[study.Position]
+		cardView := newStudyCardView(card, langByID, study.MainLangID)
+		view := &cardView
+		for i, opt := range card.OptionList() {
+			view.Options = append(view.Options, StudyOption{Key: i + 1, Text: opt})
+		}
+
+		// После ответа показываем все допустимые переводы и метаданные слова
+		if card.Answered {
+			answerLangID := card.LangID
+			if card.Reverse {
+				answerLangID = study.MainLangID
+			}
+			accepted, err := loadAcceptedTranslations(db, card.WordID, answerLangID)
+			if err != nil {
+				log.Printf("Failed to load accepted translations: %v", err)
+			}
+			if len(accepted) > 1 {
+				view.Alternatives = accepted[1:]
+			}
+
+			details, err := loadWordDetails(db, []uint{card.WordID}, []uint{card.LangID})
+			if err != nil {
+				log.Printf("Failed to load word details: %v", err)
+			}
+			if wd := details[card.WordID]; wd != nil {
+				view.Details = wd.Lang(card.LangID)
+			}
+		}
+		data.Card = view
+	} else {
+		for _, card := range cards {
+			data.Cards = append(data.Cards, newStudyCardView(card, langByID, study.MainLangID))
+		}
+		if len(cards) > 0 {
+			data.Percent = correct * 100 / len(cards)
+		}
+		if study.FinishedAt != nil {
+			data.Duration = study.FinishedAt.Sub(study.CreatedAt).Round(time.Second).String()
+		}
+	}
+
+	tmpl, err := template.ParseFiles("templates/layout.html", "templates/study.html")
+	if err != nil {
+		log.Printf("template.ParseFiles error: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
+		log.Printf("ExecuteTemplate error: %v", err)
+	}
+}
+
+// StudyAnswerHandler проверяет ответ на текущую карточку и сохраняет его.
+// Повторная отправка того же ответа (например, после обновления страницы) игнорируется.
+func StudyAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	study, userID, ok := studyRequest(w, r)
+	if !ok {
+		return
+	}
+	redirectURL := fmt.Sprintf("/study/%d", study.ID)
+
+	// Карточки игры на сопоставление проверяются только всей формой в StudyMatchHandler
+	if study.IsMatch() {
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+
+	cardID, err := strconv.ParseUint(r.FormValue("card_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid card ID", http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+
+	var card models.StudyCard
+	err = db.Raw("SELECT * FROM langhelpercopy.study_cards WHERE id = ? AND session_id = ?", cardID, study.ID).Scan(&card).Error
+	if err != nil || card.ID == 0 {
+		http.Error(w, "Card not found", http.StatusNotFound)
+		return
+	}
+
+	// Отвечать можно только на текущую карточку и только один раз
+	if card.Answered || card.Position != study.Position || study.Status != models.StudySessionActive {
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+
+	// Ответ после истечения времени не засчитывается
+	expired, err := enforceTimeLimits(db, study, userID)
+	if err != nil {
+		log.Printf("Failed to apply time limits: %v", err)
+		http.Error(w, "Failed to save answer", http.StatusInternalServerError)
+		return
+	}
+	if expired {
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+
+	answer := r.FormValue("answer")
+	answerLangID := card.LangID
+	if card.Reverse {
+		answerLangID = study.MainLangID
+	}
+	accepted, err := loadAcceptedTranslations(db, card.WordID, answerLangID)
+	if err != nil {
+		log.Printf("Failed to load accepted translations: %v", err)
+		http.Error(w, "Failed to check answer", http.StatusInternalServerError)
+		return
+	}
+	correct := isAcceptedAnswer(answer, accepted)
+
+	// Ответ, история повторений и переход к следующей карточке сохраняются
+	// вместе: при ошибке карточка остаётся неотвеченной
+	err = database.UnitOfWork(db, func(tx *gorm.DB) error {
+		result := tx.Exec(`
+			UPDATE langhelpercopy.study_cards
+			SET answer = ?, answered = true, correct = ?, answered_at = NOW()
+			WHERE id = ? AND NOT answered
+		`, truncateRunes(answer, 255), correct, card.ID)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		if err := tx.Exec("UPDATE langhelpercopy.study_sessions SET updated_at = NOW() WHERE id = ?", study.ID).Error; err != nil {
+			return err
+		}
+
+		// Ответ сохраняется в истории для статистики и умных колод
+		err := recordReview(tx, models.Review{
+			UserID:  userID,
+			DeckID:  study.DeckID,
+			WordID:  card.WordID,
+			LangID:  answerLangID,
+			Answer:  answer,
+			Correct: correct,
+		})
+		if err != nil {
+			return err
+		}
+
+		// На экзамене результат не показывается, сразу следующая карточка
+		if study.IsExam() {
+			return advanceStudySession(tx, study.ID, card.Position)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to save answer: %v", err)
+		http.Error(w, "Failed to save answer", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
+
+// StudyNextHandler переходит к следующей карточке после ответа на текущую;
+// после последней карточки сессия завершается.
+func StudyNextHandler(w http.ResponseWriter, r *http.Request) {
+	study, _, ok := studyRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Игра на сопоставление проходит одним шагом, листать её нельзя
+	if study.IsMatch() {
+		http.Redirect(w, r, fmt.Sprintf("/study/%d", study.ID), http.StatusSeeOther)
+		return
+	}
+
+	db := database.GetDB()
+
+	// Позиция передаётся из формы, чтобы двойное нажатие не пропускало карточку
+	position, err := strconv.Atoi(r.FormValue("position"))
+	if err != nil || position != study.Position {
+		http.Redirect(w, r, fmt.Sprintf("/study/%d", study.ID), http.StatusSeeOther)
+		return
+	}
+
+	err = advanceStudySession(db, study.ID, position)
+	if err != nil {
+		log.Printf("Failed to advance session: %v", err)
+		http.Error(w, "Failed to advance session", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/study/%d", study.ID), http.StatusSeeOther)
+}
+
+// DeleteStudySessionHandler удаляет сессию; ответы остаются в истории повторений
+func DeleteStudySessionHandler(w http.ResponseWriter, r *http.Request) {
+	study, _, ok := studyRequest(w, r)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	if err := db.Exec("DELETE FROM langhelpercopy.study_sessions WHERE id = ?", study.ID).Error; err != nil {
+		log.Printf("Failed to delete study session: %v", err)
+		http.Error(w, "Failed to delete session", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/flashcards", http.StatusSeeOther)
+}
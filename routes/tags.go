@@ -0,0 +1,97 @@
+package routes
+
+import (
+	"langhelperCopy/models"
+
+	"gorm.io/gorm"
+)
+
+// loadUserTags возвращает все метки пользователя по алфавиту
+func loadUserTags(db *gorm.DB, userID uint) ([]models.Tag, error) {
+	var tags []models.Tag
+	err := db.Raw("SELECT id, user_id, name FROM langhelpercopy.tags WHERE user_id = ? ORDER BY name", userID).Scan(&tags).Error
+	return tags, err
+}
+
+// loadWordTags возвращает метки слов пользователя, сгруппированные по слову
+func loadWordTags(db *gorm.DB, userID uint) (map[uint][]models.Tag, error) {
+	var rows []struct {
+		WordID uint
+		ID     uint
+		Name   string
+	}
+	err := db.Raw(`
+		SELECT wt.word_id, t.id, t.name
+		FROM langhelpercopy.word_tags wt
+		JOIN langhelpercopy.tags t ON wt.tag_id = t.id
+		WHERE t.user_id = ?
+		ORDER BY t.name
+	`, userID).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[uint][]models.Tag)
+	for _, r := range rows {
+		result[r.WordID] = append(result[r.WordID], models.Tag{ID: r.ID, UserID: userID, Name: r.Name})
+	}
+	return result, nil
+}
+
+// saveWordTags заменяет метки слова, создавая новые метки при необходимости
+func saveWordTags(db *gorm.DB, userID uint, wordID string, names []string) error {
+	err := db.Exec(`
+		DELETE FROM langhelpercopy.word_tags
+		WHERE word_id = ? AND tag_id IN (SELECT id FROM langhelpercopy.tags WHERE user_id = ?)
+	`, wordID, userID).Error
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		var tagID uint
+		err := db.Raw(`
+			INSERT INTO langhelpercopy.tags (user_id, name) VALUES (?, ?)
+			ON CONFLICT (user_id, name) DO UPDATE SET name = EXCLUDED.name
+			RETURNING id
+		`, userID, name).Scan(&tagID).Error
+		if err != nil {
+			return err
+		}
+
+		err = db.Exec(`
+			INSERT INTO langhelpercopy.word_tags (word_id, tag_id) VALUES (?, ?)
+			ON CONFLICT DO NOTHING
+		`, wordID, tagID).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addWordTag добавляет слову одну метку, не трогая остальные
+func addWordTag(db *gorm.DB, userID, wordID uint, name string) error {
+	var tagID uint
+	err := db.Raw(`
+		INSERT INTO langhelpercopy.tags (user_id, name) VALUES (?, ?)
+		ON CONFLICT (user_id, name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id
+	`, userID, name).Scan(&tagID).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Exec(`
+		INSERT INTO langhelpercopy.word_tags (word_id, tag_id) VALUES (?, ?)
+		ON CONFLICT DO NOTHING
+	`, wordID, tagID).Error
+}
+
+// removeWordTag снимает со слова метку с указанным именем
+func removeWordTag(db *gorm.DB, userID, wordID uint, name string) error {
+	return db.Exec(`
+		DELETE FROM langhelpercopy.word_tags
+		WHERE word_id = ? AND tag_id IN (SELECT id FROM langhelpercopy.tags WHERE user_id = ? AND name = ?)
+	`, wordID, userID, name).Error
+}
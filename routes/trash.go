@@ -0,0 +1,353 @@
+package routes
+
+import (
+	"fmt"
+	"html/template"
+	"langhelperCopy/config"
+	"langhelperCopy/database"
+	"langhelperCopy/models"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Виды записей в корзине
+const (
+	trashWord = "word"
+	trashLang = "lang"
+	trashDeck = "deck"
+)
+
+// trashTable описывает таблицу записей одного вида и условие владения ими.
+// У слов нет user_id: слово принадлежит пользователю через его переводы.
+type trashTable struct {
+	Table   string
+	Owner   string
+	History string // вид записей журнала изменений
+}
+
+var trashTables = map[string]trashTable{
+	trashWord: {
+		Table: "langhelpercopy.words",
+		Owner: `id IN (
+			SELECT uw.word_id FROM langhelpercopy.user_words uw
+			JOIN langhelpercopy.user_langs ul ON uw.lang_id = ul.id
+			WHERE ul.user_id = ?)`,
+		History: models.HistoryWord,
+	},
+	trashLang: {Table: "langhelpercopy.user_langs", Owner: "user_id = ?", History: models.HistoryLanguage},
+	trashDeck: {Table: "langhelpercopy.decks", Owner: "user_id = ?", History: models.HistoryDeck},
+}
+
+// TrashItem - удалённое слово, язык или колода
+type TrashItem struct {
+	Kind      string
+	ID        uint
+	Title     string
+	Detail    string
+	DeletedAt time.Time
+}
+
+// PurgeAt - когда запись будет удалена окончательно
+func (t TrashItem) PurgeAt() time.Time {
+	return t.DeletedAt.AddDate(0, 0, config.TrashRetentionDays)
+}
+
+// TrashSection - записи корзины одного вида
+type TrashSection struct {
+	Heading string
+	Items   []TrashItem
+}
+
+type TrashPageData struct {
+	Title         string
+	Sections      []TrashSection
+	RetentionDays int
+}
+
+// Empty сообщает, что корзина пуста
+func (d TrashPageData) Empty() bool {
+	for _, s := range d.Sections {
+		if len(s.Items) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// TrashHandler показывает удалённые слова, языки и колоды пользователя
+func TrashHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
+	db := database.GetDB()
+
+	words, err := loadTrashedWords(db, userID)
+	if err != nil {
+		log.Printf("Failed to load trashed words: %v", err)
+		http.Error(w, "Failed to load trash", http.StatusInternalServerError)
+		return
+	}
+
+	var languages []TrashItem
+	err = db.Raw(`
+		SELECT l.id, l.lang_title AS title, l.deleted_at,
+			(SELECT COUNT(*) FROM langhelpercopy.user_words uw WHERE uw.lang_id = l.id AND uw.is_primary) || ' translations' AS detail
+		FROM langhelpercopy.user_langs l
+		WHERE l.user_id = ? AND l.deleted_at IS NOT NULL
+		ORDER BY l.deleted_at DESC
+	`, userID).Scan(&languages).Error
+	if err != nil {
+		log.Printf("Failed to load trashed languages: %v", err)
+		http.Error(w, "Failed to load trash", http.StatusInternalServerError)
+		return
+	}
+
+	var decks []TrashItem
+	err = db.Raw(`
+		SELECT d.id, d.deck_title AS title, d.deleted_at,
+			CASE WHEN d.smart_rule <> '' THEN 'smart deck'
+				ELSE (SELECT COUNT(*) FROM langhelpercopy.deck_words dw WHERE dw.deck_id = d.id) || ' words' END AS detail
+		FROM langhelpercopy.decks d
+		WHERE d.user_id = ? AND d.deleted_at IS NOT NULL
+		ORDER BY d.deleted_at DESC
+	`, userID).Scan(&decks).Error
+	if err != nil {
+		log.Printf("Failed to load trashed decks: %v", err)
+		http.Error(w, "Failed to load trash", http.StatusInternalServerError)
+		return
+	}
+
+	for i := range languages {
+		languages[i].Kind = trashLang
+	}
+	for i := range decks {
+		decks[i].Kind = trashDeck
+	}
+
+	tmpl, err := template.ParseFiles("templates/layout.html", "templates/trash.html")
+	if err != nil {
+		http.Error(w, "Error loading templates", http.StatusInternalServerError)
+		return
+	}
+	tmpl.ExecuteTemplate(w, "layout.html", TrashPageData{
+		Title: "Trash",
+		Sections: []TrashSection{
+			{Heading: "Words", Items: words},
+			{Heading: "Languages", Items: languages},
+			{Heading: "Decks", Items: decks},
+		},
+		RetentionDays: config.TrashRetentionDays,
+	})
+}
+
+// loadTrashedWords возвращает удалённые слова пользователя; подписью служит
+// основной перевод на первый по порядку язык
+func loadTrashedWords(db *gorm.DB, userID uint) ([]TrashItem, error) {
+	var words []TrashItem
+	err := db.Raw(`
+		SELECT w.id, w.deleted_at
+		FROM langhelpercopy.words w
+		WHERE w.deleted_at IS NOT NULL AND w.`+trashTables[trashWord].Owner+`
+		ORDER BY w.deleted_at DESC
+	`, userID).Scan(&words).Error
+	if err != nil || len(words) == 0 {
+		return words, err
+	}
+
+	wordIDs := make([]uint, len(words))
+	for i, word := range words {
+		wordIDs[i] = word.ID
+	}
+	labels, err := loadWordLabels(db, userID, wordIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range words {
+		words[i].Kind = trashWord
+		words[i].Title = labels[words[i].ID]
+		if words[i].Title == "" {
+			words[i].Title = fmt.Sprintf("Word #%d", words[i].ID)
+		}
+	}
+	return words, nil
+}
+
+// trashItemTitle возвращает название записи для журнала изменений
+func trashItemTitle(db *gorm.DB, userID uint, kind string, id uint) (string, error) {
+	var title string
+	var err error
+	switch kind {
+	case trashWord:
+		var labels map[uint]string
+		labels, err = loadWordLabels(db, userID, []uint{id})
+		title = labels[id]
+	case trashLang:
+		err = db.Raw("SELECT lang_title FROM langhelpercopy.user_langs WHERE id = ?", id).Scan(&title).Error
+	case trashDeck:
+		err = db.Raw("SELECT deck_title FROM langhelpercopy.decks WHERE id = ?", id).Scan(&title).Error
+	}
+	return title, err
+}
+
+// trashRequest разбирает сессию, вид и ID записи корзины из формы.
+// При ошибке ответ уже отправлен и ok == false.
+func trashRequest(w http.ResponseWriter, r *http.Request) (kind string, id uint, userID uint, ok bool) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return "", 0, 0, false
+	}
+
+	userID, ok = session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return "", 0, 0, false
+	}
+
+	kind = r.FormValue("kind")
+	if _, known := trashTables[kind]; !known {
+		http.Error(w, "Unknown item type", http.StatusBadRequest)
+		return "", 0, 0, false
+	}
+	parsed, err := strconv.ParseUint(r.FormValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return "", 0, 0, false
+	}
+	return kind, uint(parsed), userID, true
+}
+
+// RestoreTrashHandler возвращает запись из корзины
+func RestoreTrashHandler(w http.ResponseWriter, r *http.Request) {
+	kind, id, userID, ok := trashRequest(w, r)
+	if !ok {
+		return
+	}
+
+	t := trashTables[kind]
+	db := database.GetDB()
+	restored := false
+	err := database.UnitOfWork(db, func(tx *gorm.DB) error {
+		result := tx.Exec(
+			"UPDATE "+t.Table+" SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL AND "+t.Owner,
+			id, userID,
+		)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		restored = true
+		title, err := trashItemTitle(tx, userID, kind, id)
+		if err != nil {
+			return err
+		}
+		return recordEntityChange(tx, userID, t.History, id, models.HistoryRestore, title)
+	})
+	if err != nil {
+		log.Printf("Failed to restore %s: %v", kind, err)
+		http.Error(w, "Failed to restore item", http.StatusInternalServerError)
+		return
+	}
+	if !restored {
+		http.Error(w, "Item not found in Trash", http.StatusNotFound)
+		return
+	}
+
+	http.Redirect(w, r, "/trash", http.StatusSeeOther)
+}
+
+// PurgeTrashHandler окончательно удаляет запись из корзины
+func PurgeTrashHandler(w http.ResponseWriter, r *http.Request) {
+	kind, id, userID, ok := trashRequest(w, r)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	err := database.UnitOfWork(db, func(tx *gorm.DB) error {
+		return purgeTrashItems(tx, userID, kind, id)
+	})
+	if err != nil {
+		log.Printf("Failed to purge %s: %v", kind, err)
+		http.Error(w, "Failed to delete item", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/trash", http.StatusSeeOther)
+}
+
+// EmptyTrashHandler окончательно удаляет всё содержимое корзины пользователя
+func EmptyTrashHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
+	db := database.GetDB()
+	err = database.UnitOfWork(db, func(tx *gorm.DB) error {
+		// Слова удаляются раньше языков: после удаления языка слово
+		// больше не связано с пользователем
+		for _, kind := range []string{trashWord, trashLang, trashDeck} {
+			if err := purgeTrashItems(tx, userID, kind, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to empty trash: %v", err)
+		http.Error(w, "Failed to empty trash", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/trash", http.StatusSeeOther)
+}
+
+// purgeTrashItems удаляет записи одного вида из корзины пользователя: одну по
+// id или все, если id == 0. Связанные записи удаляются каскадно.
+func purgeTrashItems(tx *gorm.DB, userID uint, kind string, id uint) error {
+	t := trashTables[kind]
+	where := "deleted_at IS NOT NULL AND " + t.Owner
+	args := []interface{}{userID}
+	if id != 0 {
+		where += " AND id = ?"
+		args = append(args, id)
+	}
+
+	if kind == trashLang {
+		// main_lang_id не защищён внешним ключом
+		err := tx.Exec(
+			"UPDATE langhelpercopy.users SET main_lang_id = NULL, updated_at = NOW(), version = version + 1 WHERE main_lang_id IN (SELECT id FROM "+t.Table+" WHERE "+where+")",
+			args...,
+		).Error
+		if err != nil {
+			return err
+		}
+	}
+	if err := tx.Exec("DELETE FROM "+t.Table+" WHERE "+where, args...).Error; err != nil {
+		return err
+	}
+	if kind == trashLang {
+		return database.DeleteOrphanWords(tx)
+	}
+	return nil
+}
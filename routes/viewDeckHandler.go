@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"errors"
 	"html/template"
 	"langhelperCopy/config"
 	"langhelperCopy/database"
@@ -8,8 +9,10 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
+	"gorm.io/gorm"
 )
 
 func ViewDeckHandler(w http.ResponseWriter, r *http.Request) {
@@ -36,20 +39,13 @@ func ViewDeckHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Получение самой колоды
 	var deck models.Deck
-	if err := db.Raw(`SELECT * FROM langhelpercopy.decks WHERE id = ? AND user_id = ?`, deckID, userID).Scan(&deck).Error; err != nil {
+	if err := db.Raw(`SELECT * FROM langhelpercopy.decks WHERE id = ? AND user_id = ? AND deleted_at IS NULL`, deckID, userID).Scan(&deck).Error; err != nil || deck.ID == 0 {
 		http.Error(w, "Deck not found", http.StatusNotFound)
 		return
 	}
 
-	// Получение языков колоды (DeckLangs)
-	var deckLangs []models.UserLang
-	err = db.Raw(`
-		SELECT l.id, l.lang_title, l.user_id
-		FROM langhelpercopy.deck_langs dl 
-		JOIN langhelpercopy.user_langs l ON dl.lang_id = l.id 
-		WHERE dl.deck_id = ?
-		ORDER BY l.lang_title
-	`, deckID).Scan(&deckLangs).Error
+	// Получение языков колоды (DeckLangs) в порядке колонок колоды
+	deckLangs, err := loadDeckLangs(db, uint(deckID))
 	if err != nil {
 		http.Error(w, "Failed to load deck languages", http.StatusInternalServerError)
 		return
@@ -58,30 +54,38 @@ func ViewDeckHandler(w http.ResponseWriter, r *http.Request) {
 	// Языки пользователя, которые ещё не добавлены в колоду (AvailableLangs)
 	var availableLangs []models.UserLang
 	err = db.Raw(`
-		SELECT l.id, l.lang_title, l.user_id
+		SELECT l.id, l.lang_title, l.user_id, l.lang_code
 		FROM langhelpercopy.user_langs l
-		WHERE l.user_id = ? AND l.id NOT IN (
+		WHERE l.user_id = ? AND l.deleted_at IS NULL AND l.id NOT IN (
 			SELECT lang_id FROM langhelpercopy.deck_langs WHERE deck_id = ?
 		)
-		ORDER BY l.lang_title
+		ORDER BY l.position, l.id
 	`, userID, deckID).Scan(&availableLangs).Error
 	if err != nil {
 		http.Error(w, "Failed to load available languages", http.StatusInternalServerError)
 		return
 	}
 
-	// Получение word_id из deck_words
-	var wordIDsInDeck []int
-	err = db.Raw(`SELECT word_id FROM langhelpercopy.deck_words WHERE deck_id = ?`, deckID).Scan(&wordIDsInDeck).Error
+	// Получение слов колоды (для умной колоды - по её правилу)
+	deckWordIDList, err := deckWordIDs(db, deck)
 	if err != nil {
+		log.Printf("Failed to load deck words: %v", err)
 		http.Error(w, "Failed to load deck words", http.StatusInternalServerError)
 		return
 	}
+	wordIDsInDeck := make([]int, len(deckWordIDList))
+	for i, id := range deckWordIDList {
+		wordIDsInDeck[i] = int(id)
+	}
 
 	// Получение переводов для этих слов только по языкам колоды
 	type WordWithTranslations struct {
 		WordID       int
 		Translations map[string]string
+		Details      *WordDetails
+		Suspended    bool
+		BuriedUntil  *time.Time
+		Leech        bool
 	}
 
 	deckWords := make([]WordWithTranslations, 0)
@@ -96,7 +100,7 @@ func ViewDeckHandler(w http.ResponseWriter, r *http.Request) {
 			SELECT uw.word_id, uw.translation, ul.lang_title
 			FROM langhelpercopy.user_words uw
 			JOIN langhelpercopy.user_langs ul ON uw.lang_id = ul.id
-			WHERE uw.word_id IN (?) AND uw.lang_id IN (?)
+			WHERE uw.word_id IN (?) AND uw.lang_id IN (?) AND uw.is_primary
 		`, wordIDsInDeck, langIDs).Rows()
 		if err != nil {
 			http.Error(w, "Failed to load word translations", http.StatusInternalServerError)
@@ -118,28 +122,76 @@ func ViewDeckHandler(w http.ResponseWriter, r *http.Request) {
 			wordMap[wordID][langTitle] = translation
 		}
 
+		// Метаданные слов для отображения под переводами
+		wordIDs := make([]uint, len(wordIDsInDeck))
+		for i, id := range wordIDsInDeck {
+			wordIDs[i] = uint(id)
+		}
+		detailLangIDs := make([]uint, len(langIDs))
+		for i, id := range langIDs {
+			detailLangIDs[i] = uint(id)
+		}
+		details, err := loadWordDetails(db, wordIDs, detailLangIDs)
+		if err != nil {
+			log.Printf("Failed to load word details: %v", err)
+			http.Error(w, "Failed to load word details", http.StatusInternalServerError)
+			return
+		}
+
+		// Отключённые, отложенные и проблемные слова помечаются в списке
+		states, err := loadDeckWordStates(db, deck.ID)
+		if err != nil {
+			log.Printf("Failed to load word states: %v", err)
+			http.Error(w, "Failed to load word states", http.StatusInternalServerError)
+			return
+		}
+		var leechIDs []uint
+		err = db.Raw(`
+			SELECT DISTINCT word_id FROM langhelpercopy.card_progresses
+			WHERE user_id = ? AND is_leech AND word_id IN (?)
+		`, userID, wordIDs).Scan(&leechIDs).Error
+		if err != nil {
+			log.Printf("Failed to load leeches: %v", err)
+			http.Error(w, "Failed to load leeches", http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
 		for id, translations := range wordMap {
-			deckWords = append(deckWords, WordWithTranslations{
+			word := WordWithTranslations{
 				WordID:       id,
 				Translations: translations,
-			})
+				Details:      details[uint(id)],
+				Leech:        containsID(leechIDs, uint(id)),
+			}
+			if state, ok := states[uint(id)]; ok {
+				word.Suspended = state.Suspended
+				if state.IsBuried(now) {
+					word.BuriedUntil = state.BuriedUntil
+				}
+			}
+			deckWords = append(deckWords, word)
 		}
 	}
 
-	// Получение candidate word_ids (у пользователя, но не в колоде)
+	// Получение candidate word_ids (у пользователя, но не в колоде);
+	// в умную колоду слова вручную не добавляются
 	var candidateWordIDs []int
-	err = db.Raw(`
-		SELECT DISTINCT uw.word_id
-		FROM langhelpercopy.user_words uw
-		JOIN langhelpercopy.user_langs ul ON uw.lang_id = ul.id
-		WHERE ul.user_id = ?
-		AND uw.word_id NOT IN (
-			SELECT word_id FROM langhelpercopy.deck_words WHERE deck_id = ?
-		)
-	`, userID, deckID).Scan(&candidateWordIDs).Error
-	if err != nil {
-		http.Error(w, "Failed to load candidate words", http.StatusInternalServerError)
-		return
+	if !deck.IsSmart() {
+		err = db.Raw(`
+			SELECT DISTINCT uw.word_id
+			FROM langhelpercopy.user_words uw
+			JOIN langhelpercopy.user_langs ul ON uw.lang_id = ul.id
+			JOIN langhelpercopy.words w ON uw.word_id = w.id
+			WHERE ul.user_id = ? AND ul.deleted_at IS NULL AND w.deleted_at IS NULL
+			AND uw.word_id NOT IN (
+				SELECT word_id FROM langhelpercopy.deck_words WHERE deck_id = ?
+			)
+		`, userID, deckID).Scan(&candidateWordIDs).Error
+		if err != nil {
+			http.Error(w, "Failed to load candidate words", http.StatusInternalServerError)
+			return
+		}
 	}
 
 	// Отбор слов, у которых есть переводы на все языки колоды
@@ -158,6 +210,7 @@ func ViewDeckHandler(w http.ResponseWriter, r *http.Request) {
 			WHERE ul.user_id = ?
 			AND uw.word_id IN (?)
 			AND uw.lang_id IN (?)
+			AND uw.is_primary
 			ORDER BY uw.word_id
 		`, userID, candidateWordIDs, langIDs).Rows()
 		if err != nil {
@@ -193,23 +246,137 @@ func ViewDeckHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Слова, скрытые из списка из-за недостающих переводов
+	hiddenWordCount := 0
+	if len(deckLangs) > 0 {
+		hiddenWordCount = len(candidateWordIDs) - len(availableWords)
+	}
+
+	// Сколько слов колоды уже переведено на каждый из языков, которые можно добавить
+	availableLangIDs := make([]uint, len(availableLangs))
+	for i, l := range availableLangs {
+		availableLangIDs[i] = l.ID
+	}
+	langCoverage, err := loadLangCoverage(db, deckWordIDList, availableLangIDs)
+	if err != nil {
+		log.Printf("Failed to load language coverage: %v", err)
+		http.Error(w, "Failed to load language coverage", http.StatusInternalServerError)
+		return
+	}
+
+	// Предупреждение перед добавлением языка, на который переведено мало слов
+	var addLangWarning *LangCoverage
+	if raw := r.URL.Query().Get("addlang"); raw != "" && len(deckWordIDList) > 0 {
+		for _, l := range availableLangs {
+			if strconv.FormatUint(uint64(l.ID), 10) == raw {
+				addLangWarning = &LangCoverage{Lang: l, Covered: langCoverage[l.ID], Total: len(deckWordIDList)}
+			}
+		}
+	}
+
+	// Панель массовых действий над словами колоды: в другие обычные колоды,
+	// а из самой колоды можно убрать слова, только если она не умная
+	bulk := BulkActionsData{
+		FormID:    "bulkDeckWords",
+		ReturnURL: "/deck/" + strconv.Itoa(deckID),
+	}
+	if !deck.IsSmart() {
+		bulk.Deck = &deck
+	}
+	manualDecks, err := loadManualDecks(db, userID)
+	if err != nil {
+		log.Printf("Failed to load decks: %v", err)
+		http.Error(w, "Failed to load decks", http.StatusInternalServerError)
+		return
+	}
+	for _, d := range manualDecks {
+		if d.ID != deck.ID {
+			bulk.Decks = append(bulk.Decks, d)
+		}
+	}
+	if bulk.Langs, err = loadUserLangs(db, userID); err != nil {
+		http.Error(w, "Failed to load languages", http.StatusInternalServerError)
+		return
+	}
+
+	// Для умной колоды показывается форма правила
+	var rule *models.SmartRule
+	var userLangs []models.UserLang
+	var tags []models.Tag
+	if deck.IsSmart() {
+		parsed, err := models.ParseSmartRule(deck.SmartRule)
+		if err != nil {
+			log.Printf("Invalid smart rule for deck %d: %v", deck.ID, err)
+		}
+		rule = &parsed
+
+		if userLangs, err = loadUserLangs(db, userID); err != nil {
+			http.Error(w, "Failed to load languages", http.StatusInternalServerError)
+			return
+		}
+		if tags, err = loadUserTags(db, userID); err != nil {
+			http.Error(w, "Failed to load tags", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Язык вопросов по умолчанию: свой у колоды или родной язык пользователя
+	var promptLangID uint
+	if deck.PromptLangID != nil {
+		promptLangID = *deck.PromptLangID
+	}
+	mainLangID, err := userMainLangID(db, userID)
+	if err != nil {
+		http.Error(w, "Failed to load languages", http.StatusInternalServerError)
+		return
+	}
+	var mainLang *models.UserLang
+	if mainLangID != 0 {
+		var lang models.UserLang
+		if err := db.Raw("SELECT id, lang_title FROM langhelpercopy.user_langs WHERE id = ?", mainLangID).Scan(&lang).Error; err == nil && lang.ID != 0 {
+			mainLang = &lang
+		}
+	}
+
 	data := struct {
 		Title              string
 		Deck               models.Deck
 		DeckLanguages      []models.UserLang
+		LastLangIndex      int
+		PromptLangID       uint
+		MainLang           *models.UserLang
 		DeckWords          []WordWithTranslations
 		AvailableLanguages []models.UserLang
 		AvailableWords     []WordWithTranslations
+		HiddenWordCount    int
+		DeckWordCount      int
+		LangCoverage       map[uint]int
+		AddLangWarning     *LangCoverage
+		Bulk               BulkActionsData
+		Rule               *models.SmartRule
+		UserLangs          []models.UserLang
+		Tags               []models.Tag
 	}{
 		Title:              "View deck",
 		Deck:               deck,
 		DeckLanguages:      deckLangs,
+		LastLangIndex:      len(deckLangs) - 1,
+		PromptLangID:       promptLangID,
+		MainLang:           mainLang,
 		DeckWords:          deckWords,
 		AvailableLanguages: availableLangs,
 		AvailableWords:     availableWords,
+		HiddenWordCount:    hiddenWordCount,
+		DeckWordCount:      len(deckWordIDList),
+		LangCoverage:       langCoverage,
+		AddLangWarning:     addLangWarning,
+		Bulk:               bulk,
+		Rule:               rule,
+		UserLangs:          userLangs,
+		Tags:               tags,
 	}
 
-	tmpl, err := template.ParseFiles("templates/layout.html", "templates/viewDeck.html")
+	tmpl, err := template.New("layout.html").Funcs(smartRuleFuncs).ParseFiles("templates/layout.html", "templates/viewDeck.html", "templates/smartRule.html", "templates/bulkActions.html")
 	if err != nil {
 		log.Printf("template.ParseFiles error: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -265,14 +432,56 @@ func AddLangToDeckHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Проверка, что язык принадлежит пользователю
 	var count int
-	row := db.Raw("SELECT COUNT(*) FROM langhelpercopy.user_langs WHERE id = ? AND user_id = ?", langID, userID).Row()
+	row := db.Raw("SELECT COUNT(*) FROM langhelpercopy.user_langs WHERE id = ? AND user_id = ? AND deleted_at IS NULL", langID, userID).Row()
 	row.Scan(&count)
 	if count == 0 {
 		http.Error(w, "Unauthorized language", http.StatusForbidden)
 		return
 	}
 
-	db.Exec("INSERT INTO langhelpercopy.deck_langs (deck_id, lang_id) VALUES (?, ?)", deckID, langID)
+	deck, err := loadUserDeck(db, uint(deckID), userID)
+	if err != nil {
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+
+	// Если на язык переведено меньше половины слов колоды, сначала
+	// показывается предупреждение
+	if r.FormValue("confirm") != "1" {
+		wordIDs, err := deckWordIDs(db, deck)
+		if err != nil {
+			log.Printf("Failed to load deck words: %v", err)
+			http.Error(w, "Failed to load deck words", http.StatusInternalServerError)
+			return
+		}
+		covered, err := loadLangCoverage(db, wordIDs, []uint{uint(langID)})
+		if err != nil {
+			log.Printf("Failed to load language coverage: %v", err)
+			http.Error(w, "Failed to load language coverage", http.StatusInternalServerError)
+			return
+		}
+		coverage := LangCoverage{Covered: covered[uint(langID)], Total: len(wordIDs)}
+		if coverage.Percent() < langCoverageWarnPercent {
+			http.Redirect(w, r, "/deck/"+strconv.Itoa(deckID)+"?addlang="+strconv.Itoa(langID), http.StatusSeeOther)
+			return
+		}
+	}
+
+	// Новый язык добавляется последней колонкой колоды
+	err = db.Exec(`
+		INSERT INTO langhelpercopy.deck_langs (deck_id, lang_id, position)
+		SELECT ?, ?, COALESCE(MAX(position), 0) + 1
+		FROM langhelpercopy.deck_langs WHERE deck_id = ?
+	`, deckID, langID, deckID).Error
+	if err != nil {
+		log.Printf("Failed to add deck language: %v", err)
+		http.Error(w, "Failed to add language to deck", http.StatusInternalServerError)
+		return
+	}
+	if r.FormValue("next") == "fill" {
+		http.Redirect(w, r, "/deck/"+strconv.Itoa(deckID)+"/fill", http.StatusSeeOther)
+		return
+	}
 	http.Redirect(w, r, "/deck/"+strconv.Itoa(deckID), http.StatusSeeOther)
 }
 
@@ -294,7 +503,11 @@ func RemoveLangFromDeckHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	db := database.GetDB()
-	db.Exec("DELETE FROM langhelpercopy.deck_langs WHERE deck_id = ? AND lang_id = ?", deckID, langID)
+	if err := db.Exec("DELETE FROM langhelpercopy.deck_langs WHERE deck_id = ? AND lang_id = ?", deckID, langID).Error; err != nil {
+		log.Printf("Failed to remove deck language: %v", err)
+		http.Error(w, "Failed to remove language from deck", http.StatusInternalServerError)
+		return
+	}
 
 	http.Redirect(w, r, "/deck/"+strconv.Itoa(deckID), http.StatusSeeOther)
 }
@@ -336,14 +549,14 @@ func AddWordToDeckHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Проверяем, что колода принадлежит текущему пользователю
 	var count int64
-	err = db.Raw(`SELECT COUNT(*) FROM langhelpercopy.decks WHERE id = ? AND user_id = ?`, deckID, userID).Scan(&count).Error
+	err = db.Raw(`SELECT COUNT(*) FROM langhelpercopy.decks WHERE id = ? AND user_id = ? AND deleted_at IS NULL`, deckID, userID).Scan(&count).Error
 	if err != nil || count == 0 {
 		http.Error(w, "Deck not found or access denied", http.StatusForbidden)
 		return
 	}
 
 	// Проверяем, что слово существует у пользователя (в user_words)
-	err = db.Raw(`SELECT COUNT(DISTINCT word_id) FROM langhelpercopy.user_words WHERE word_id = ? AND lang_id IN (SELECT id FROM langhelpercopy.user_langs WHERE user_id = ?)`, wordID, userID).Scan(&count).Error
+	err = db.Raw(`SELECT COUNT(DISTINCT word_id) FROM langhelpercopy.user_words WHERE word_id = ? AND word_id IN (SELECT id FROM langhelpercopy.words WHERE deleted_at IS NULL) AND lang_id IN (SELECT id FROM langhelpercopy.user_langs WHERE user_id = ? AND deleted_at IS NULL)`, wordID, userID).Scan(&count).Error
 	if err != nil || count == 0 {
 		http.Error(w, "Word not found or access denied", http.StatusForbidden)
 		return
@@ -409,7 +622,7 @@ func RemoveWordFromDeckHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Проверяем, что колода принадлежит текущему пользователю
 	var count int64
-	err = db.Raw(`SELECT COUNT(*) FROM langhelpercopy.decks WHERE id = ? AND user_id = ?`, deckID, userID).Scan(&count).Error
+	err = db.Raw(`SELECT COUNT(*) FROM langhelpercopy.decks WHERE id = ? AND user_id = ? AND deleted_at IS NULL`, deckID, userID).Scan(&count).Error
 	if err != nil || count == 0 {
 		http.Error(w, "Deck not found or access denied", http.StatusForbidden)
 		return
@@ -425,3 +638,94 @@ func RemoveWordFromDeckHandler(w http.ResponseWriter, r *http.Request) {
 	// Редирект обратно на страницу колоды
 	http.Redirect(w, r, "/deck/"+deckIDStr, http.StatusSeeOther)
 }
+
+// UpdateSmartRuleHandler сохраняет новое правило умной колоды
+func UpdateSmartRuleHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
+	deckID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+
+	var deck models.Deck
+	if err := db.Raw(`SELECT * FROM langhelpercopy.decks WHERE id = ? AND user_id = ? AND deleted_at IS NULL`, deckID, userID).Scan(&deck).Error; err != nil || deck.ID == 0 {
+		http.Error(w, "Deck not found", http.StatusNotFound)
+		return
+	}
+	if !deck.IsSmart() {
+		http.Error(w, "Deck is not a smart deck", http.StatusBadRequest)
+		return
+	}
+
+	userLangs, err := loadUserLangs(db, userID)
+	if err != nil {
+		http.Error(w, "Failed to load languages", http.StatusInternalServerError)
+		return
+	}
+	tags, err := loadUserTags(db, userID)
+	if err != nil {
+		http.Error(w, "Failed to load tags", http.StatusInternalServerError)
+		return
+	}
+
+	rule, err := parseSmartRuleForm(r, userLangs, tags)
+	if err != nil {
+		http.Error(w, "Invalid smart deck rule: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	encoded, err := rule.Encode()
+	if err != nil {
+		http.Error(w, "Failed to save rule", http.StatusInternalServerError)
+		return
+	}
+	version, err := formVersion(r)
+	if err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	// Правило и языки колоды сохраняются вместе
+	err = database.UnitOfWork(db, func(tx *gorm.DB) error {
+		result := tx.Exec(`
+			UPDATE langhelpercopy.decks SET smart_rule = ?, updated_at = NOW(), version = version + 1
+			WHERE id = ? AND version = ?
+		`, encoded, deck.ID, version)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errStaleVersion
+		}
+		return syncSmartDeckLangs(tx, deck.ID, rule.LangIDs)
+	})
+	if errors.Is(err, errStaleVersion) {
+		renderConflict(w, "This deck", "/deck/"+strconv.Itoa(deckID))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to save smart rule: %v", err)
+		http.Error(w, "Failed to save rule", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/deck/"+strconv.Itoa(deckID), http.StatusSeeOther)
+}
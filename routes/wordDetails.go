@@ -0,0 +1,126 @@
+package routes
+
+import (
+	"gorm.io/gorm"
+)
+
+// TranslationDetails содержит перевод слова на один язык вместе с метаданными
+type TranslationDetails struct {
+	Translation   string
+	Alternatives  []string
+	Gender        string
+	Pronunciation string
+	Notes         string
+	Examples      []string
+}
+
+// WordDetails содержит метаданные слова и его переводы по ID языка
+type WordDetails struct {
+	WordID       uint
+	PartOfSpeech string
+	Notes        string
+	Langs        map[uint]*TranslationDetails
+}
+
+// loadWordDetails загружает метаданные и переводы сразу для набора слов,
+// ограничиваясь переданными языками.
+func loadWordDetails(db *gorm.DB, wordIDs []uint, langIDs []uint) (map[uint]*WordDetails, error) {
+	details := make(map[uint]*WordDetails, len(wordIDs))
+	if len(wordIDs) == 0 || len(langIDs) == 0 {
+		return details, nil
+	}
+
+	var words []struct {
+		ID           uint
+		PartOfSpeech string
+		Notes        string
+	}
+	err := db.Raw(`
+		SELECT id, part_of_speech, notes FROM langhelpercopy.words WHERE id IN (?)
+	`, wordIDs).Scan(&words).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range words {
+		details[w.ID] = &WordDetails{
+			WordID:       w.ID,
+			PartOfSpeech: w.PartOfSpeech,
+			Notes:        w.Notes,
+			Langs:        make(map[uint]*TranslationDetails),
+		}
+	}
+
+	var translations []struct {
+		WordID        uint
+		LangID        uint
+		Translation   string
+		IsPrimary     bool
+		Gender        string
+		Pronunciation string
+		Notes         string
+	}
+	err = db.Raw(`
+		SELECT word_id, lang_id, translation, is_primary, gender, pronunciation, notes
+		FROM langhelpercopy.user_words
+		WHERE word_id IN (?) AND lang_id IN (?)
+		ORDER BY is_primary DESC, id
+	`, wordIDs, langIDs).Scan(&translations).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range translations {
+		word, ok := details[t.WordID]
+		if !ok {
+			continue
+		}
+		td := word.translation(t.LangID)
+		if t.IsPrimary {
+			td.Translation = t.Translation
+			td.Gender = t.Gender
+			td.Pronunciation = t.Pronunciation
+			td.Notes = t.Notes
+		} else {
+			td.Alternatives = append(td.Alternatives, t.Translation)
+		}
+	}
+
+	var examples []struct {
+		WordID   uint
+		LangID   uint
+		Sentence string
+	}
+	err = db.Raw(`
+		SELECT word_id, lang_id, sentence
+		FROM langhelpercopy.word_examples
+		WHERE word_id IN (?) AND lang_id IN (?)
+		ORDER BY id
+	`, wordIDs, langIDs).Scan(&examples).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range examples {
+		if word, ok := details[e.WordID]; ok {
+			td := word.translation(e.LangID)
+			td.Examples = append(td.Examples, e.Sentence)
+		}
+	}
+
+	return details, nil
+}
+
+func (wd *WordDetails) translation(langID uint) *TranslationDetails {
+	td, ok := wd.Langs[langID]
+	if !ok {
+		td = &TranslationDetails{}
+		wd.Langs[langID] = td
+	}
+	return td
+}
+
+// Lang возвращает перевод на язык или пустую структуру, удобно для шаблонов
+func (wd *WordDetails) Lang(langID uint) TranslationDetails {
+	if td, ok := wd.Langs[langID]; ok {
+		return *td
+	}
+	return TranslationDetails{}
+}
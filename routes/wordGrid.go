@@ -0,0 +1,676 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"langhelperCopy/config"
+	"langhelperCopy/database"
+	"langhelperCopy/models"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// Ограничения одного сохранения таблицы слов
+const (
+	maxGridBatchCells = 500
+	maxGridBatchBytes = 1 << 20
+)
+
+// Состояния ячейки в ответе на сохранение
+const (
+	gridCellSaved    = "saved"
+	gridCellInvalid  = "invalid"
+	gridCellConflict = "conflict"
+)
+
+// errGridUndoConflict - слова пакета изменили после сохранения, отмена невозможна
+var errGridUndoConflict = errors.New("some of these words were edited after the save, so it can't be undone")
+
+// errGridNothingToUndo - сохранённого пакета нет
+var errGridNothingToUndo = errors.New("nothing to undo")
+
+type gridCellInput struct {
+	LangID uint   `json:"lang_id"`
+	Value  string `json:"value"`
+}
+
+type gridRowInput struct {
+	WordID  uint            `json:"word_id"`
+	Version int             `json:"version"` // версия слова, которую видел пользователь
+	Cells   []gridCellInput `json:"cells"`
+}
+
+type gridBatchInput struct {
+	Rows []gridRowInput `json:"rows"`
+}
+
+// GridCellResult - результат сохранения одной ячейки. Value и Version - текущие
+// значения на сервере после сохранения.
+type GridCellResult struct {
+	WordID  uint   `json:"word_id"`
+	LangID  uint   `json:"lang_id"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Value   string `json:"value"`
+	Version int    `json:"version"`
+}
+
+// GridBatchResult - ответ на сохранение или отмену пакета правок
+type GridBatchResult struct {
+	Cells   []GridCellResult `json:"cells"`
+	CanUndo bool             `json:"can_undo"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// GridRow - строка таблицы слов с версией слова
+type GridRow struct {
+	CoverageRow
+	Version int
+}
+
+// gridCellKey - ячейка таблицы: слово и язык
+type gridCellKey struct {
+	WordID uint
+	LangID uint
+}
+
+// loadWordVersions возвращает текущие версии слов; lock блокирует строки до конца транзакции
+func loadWordVersions(db *gorm.DB, wordIDs []uint, lock bool) (map[uint]int, error) {
+	versions := make(map[uint]int, len(wordIDs))
+	if len(wordIDs) == 0 {
+		return versions, nil
+	}
+	query := "SELECT id, version FROM langhelpercopy.words WHERE id IN (?)"
+	if lock {
+		query += " FOR UPDATE"
+	}
+	var rows []struct {
+		ID      uint
+		Version int
+	}
+	if err := db.Raw(query, wordIDs).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		versions[row.ID] = row.Version
+	}
+	return versions, nil
+}
+
+// bumpWordVersions увеличивает версии изменённых слов и возвращает новые
+func bumpWordVersions(tx *gorm.DB, wordIDs []uint) (map[uint]int, error) {
+	versions := make(map[uint]int, len(wordIDs))
+	if len(wordIDs) == 0 {
+		return versions, nil
+	}
+	var rows []struct {
+		ID      uint
+		Version int
+	}
+	err := tx.Raw(`
+		UPDATE langhelpercopy.words SET version = version + 1
+		WHERE id IN (?)
+		RETURNING id, version
+	`, wordIDs).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		versions[row.ID] = row.Version
+	}
+	return versions, nil
+}
+
+// loadPrimaryTranslations возвращает основные переводы слов по ячейкам
+func loadPrimaryTranslations(db *gorm.DB, wordIDs []uint) (map[gridCellKey]string, error) {
+	current := make(map[gridCellKey]string)
+	if len(wordIDs) == 0 {
+		return current, nil
+	}
+	var rows []struct {
+		WordID      uint
+		LangID      uint
+		Translation string
+	}
+	err := db.Raw(`
+		SELECT word_id, lang_id, translation FROM langhelpercopy.user_words
+		WHERE word_id IN (?) AND is_primary
+	`, wordIDs).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		current[gridCellKey{row.WordID, row.LangID}] = row.Translation
+	}
+	return current, nil
+}
+
+// hasWordEditBatch сообщает, есть ли у пользователя пакет правок для отмены
+func hasWordEditBatch(db *gorm.DB, userID uint) (bool, error) {
+	var count int64
+	err := db.Raw("SELECT COUNT(*) FROM langhelpercopy.word_edit_batches WHERE user_id = ?", userID).Scan(&count).Error
+	return count > 0, err
+}
+
+// writeGridResult отправляет ответ таблицы слов в JSON
+func writeGridResult(w http.ResponseWriter, status int, result GridBatchResult) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("JSON encode error: %v", err)
+	}
+}
+
+// WordGridHandler показывает все слова таблицей, где каждую ячейку можно править
+func WordGridHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
+	db := database.GetDB()
+
+	langs, err := loadUserLangs(db, userID)
+	if err != nil {
+		http.Error(w, "Failed to get user langs", http.StatusInternalServerError)
+		return
+	}
+
+	var wordIDs []uint
+	err = db.Raw(`
+		SELECT DISTINCT word_id FROM langhelpercopy.user_words
+		WHERE lang_id IN (SELECT id FROM langhelpercopy.user_langs WHERE user_id = ? AND deleted_at IS NULL)
+			AND word_id IN (SELECT id FROM langhelpercopy.words WHERE deleted_at IS NULL)
+		ORDER BY word_id
+	`, userID).Scan(&wordIDs).Error
+	if err != nil {
+		log.Printf("Failed to load words: %v", err)
+		http.Error(w, "Failed to load words", http.StatusInternalServerError)
+		return
+	}
+
+	coverage, err := loadCoverage(db, wordIDs, langs)
+	if err != nil {
+		log.Printf("Failed to load translations: %v", err)
+		http.Error(w, "Failed to load words", http.StatusInternalServerError)
+		return
+	}
+	versions, err := loadWordVersions(db, wordIDs, false)
+	if err != nil {
+		log.Printf("Failed to load word versions: %v", err)
+		http.Error(w, "Failed to load words", http.StatusInternalServerError)
+		return
+	}
+	canUndo, err := hasWordEditBatch(db, userID)
+	if err != nil {
+		log.Printf("Failed to load last edit: %v", err)
+		http.Error(w, "Failed to load words", http.StatusInternalServerError)
+		return
+	}
+
+	rows := make([]GridRow, len(coverage.Rows))
+	for i, row := range coverage.Rows {
+		rows[i] = GridRow{CoverageRow: row, Version: versions[row.WordID]}
+	}
+
+	data := struct {
+		Title     string
+		Langs     []models.UserLang
+		Rows      []GridRow
+		CanUndo   bool
+		MaxLength int
+	}{
+		Title:     "Edit Words",
+		Langs:     langs,
+		Rows:      rows,
+		CanUndo:   canUndo,
+		MaxLength: maxTranslationLength,
+	}
+
+	tmpl, err := template.ParseFiles("templates/layout.html", "templates/wordGrid.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+	}
+}
+
+// WordGridSaveHandler сохраняет пакет правок таблицы слов. Ячейки проверяются
+// по отдельности: правильные сохраняются, для остальных возвращается ошибка.
+// Строки, изменённые в другом месте после загрузки таблицы, не сохраняются.
+func WordGridSaveHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
+	var input gridBatchInput
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGridBatchBytes)).Decode(&input); err != nil {
+		writeGridResult(w, http.StatusBadRequest, GridBatchResult{Error: "Invalid request"})
+		return
+	}
+	cells := 0
+	for _, row := range input.Rows {
+		cells += len(row.Cells)
+	}
+	if cells > maxGridBatchCells {
+		writeGridResult(w, http.StatusBadRequest, GridBatchResult{
+			Error: fmt.Sprintf("Save at most %d cells at once", maxGridBatchCells),
+		})
+		return
+	}
+
+	var result GridBatchResult
+	err = database.UnitOfWork(database.GetDB(), func(tx *gorm.DB) error {
+		var err error
+		result, err = saveGridBatch(tx, userID, input)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to save word grid: %v", err)
+		writeGridResult(w, http.StatusInternalServerError, GridBatchResult{Error: "Failed to save changes, nothing was saved"})
+		return
+	}
+	writeGridResult(w, http.StatusOK, result)
+}
+
+// saveGridBatch проверяет и сохраняет ячейки пакета и запоминает его для отмены
+func saveGridBatch(tx *gorm.DB, userID uint, input gridBatchInput) (GridBatchResult, error) {
+	var result GridBatchResult
+
+	langs, err := loadUserLangs(tx, userID)
+	if err != nil {
+		return result, err
+	}
+	langTitles := make(map[uint]string, len(langs))
+	for _, lang := range langs {
+		langTitles[lang.ID] = lang.LangTitle
+	}
+
+	var requested []uint
+	for _, row := range input.Rows {
+		if !containsID(requested, row.WordID) {
+			requested = append(requested, row.WordID)
+		}
+	}
+	var owned []uint
+	if len(requested) > 0 {
+		err = tx.Raw(`
+			SELECT DISTINCT uw.word_id
+			FROM langhelpercopy.user_words uw
+			JOIN langhelpercopy.user_langs ul ON uw.lang_id = ul.id
+			JOIN langhelpercopy.words w ON uw.word_id = w.id
+			WHERE ul.user_id = ? AND uw.word_id IN (?)
+				AND ul.deleted_at IS NULL AND w.deleted_at IS NULL
+		`, userID, requested).Scan(&owned).Error
+		if err != nil {
+			return result, err
+		}
+	}
+	versions, err := loadWordVersions(tx, owned, true)
+	if err != nil {
+		return result, err
+	}
+	current, err := loadPrimaryTranslations(tx, owned)
+	if err != nil {
+		return result, err
+	}
+	alternatives, err := loadAlternativeKeys(tx, owned)
+	if err != nil {
+		return result, err
+	}
+
+	// Проверка каждой ячейки; повтор ячейки в пакете заменяет предыдущее значение
+	cellResults := make(map[gridCellKey]*GridCellResult)
+	var order []gridCellKey
+	var candidates []gridCellKey
+	values := make(map[gridCellKey]string)
+	for _, row := range input.Rows {
+		for _, cell := range row.Cells {
+			key := gridCellKey{row.WordID, cell.LangID}
+			if _, seen := cellResults[key]; !seen {
+				order = append(order, key)
+			}
+			value := strings.TrimSpace(cell.Value)
+			res := &GridCellResult{WordID: row.WordID, LangID: cell.LangID, Value: current[key], Version: versions[row.WordID]}
+			cellResults[key] = res
+
+			switch {
+			case !containsID(owned, row.WordID):
+				res.Status, res.Error = gridCellInvalid, "word not found"
+			case row.Version != versions[row.WordID]:
+				res.Status, res.Error = gridCellConflict, "this word was edited elsewhere, reload to see the latest version"
+			case langTitles[cell.LangID] == "":
+				res.Status, res.Error = gridCellInvalid, "language not found"
+			case value == current[key]:
+				res.Status = gridCellSaved
+			case value == "":
+				res.Status, res.Error = gridCellInvalid, "a translation can't be cleared here, use the edit form on My Words"
+			case utf8.RuneCountInString(value) > maxTranslationLength:
+				res.Status, res.Error = gridCellInvalid, fmt.Sprintf("must be at most %d characters", maxTranslationLength)
+			case alternatives[gridCellKey{row.WordID, cell.LangID}][strings.ToLower(value)]:
+				res.Status, res.Error = gridCellInvalid, fmt.Sprintf("%q is already an alternative translation of this word", value)
+			default:
+				if !containsKey(candidates, key) {
+					candidates = append(candidates, key)
+				}
+				values[key] = value
+			}
+			if res.Status != "" {
+				candidates = removeKey(candidates, key)
+				delete(values, key)
+			}
+		}
+	}
+
+	// Одинаковый основной перевод на одном языке не может быть у двух слов
+	duplicates, err := findGridDuplicates(tx, candidates, values)
+	if err != nil {
+		return result, err
+	}
+	var changes []models.WordCellChange
+	var changedWords []uint
+	for _, key := range candidates {
+		res := cellResults[key]
+		if other, ok := duplicates[key]; ok {
+			res.Status = gridCellInvalid
+			res.Error = fmt.Sprintf("%q is already used by word #%d in %s", values[key], other, langTitles[key.LangID])
+			continue
+		}
+
+		old, exists := current[key]
+		if exists {
+			err = tx.Exec(`
+				UPDATE langhelpercopy.user_words SET translation = ?, updated_at = NOW(), version = version + 1
+				WHERE word_id = ? AND lang_id = ? AND is_primary
+			`, values[key], key.WordID, key.LangID).Error
+		} else {
+			err = tx.Exec(`
+				INSERT INTO langhelpercopy.user_words (lang_id, word_id, translation, is_primary)
+				VALUES (?, ?, ?, TRUE)
+			`, key.LangID, key.WordID, values[key]).Error
+		}
+		if err != nil {
+			return result, err
+		}
+		if err := recordTranslationChange(tx, userID, key.WordID, key.LangID, old, values[key]); err != nil {
+			return result, err
+		}
+		res.Status = gridCellSaved
+		res.Value = values[key]
+		changes = append(changes, models.WordCellChange{WordID: key.WordID, LangID: key.LangID, Old: old, New: values[key]})
+		if !containsID(changedWords, key.WordID) {
+			changedWords = append(changedWords, key.WordID)
+		}
+	}
+
+	newVersions, err := bumpWordVersions(tx, changedWords)
+	if err != nil {
+		return result, err
+	}
+	for i := range changes {
+		changes[i].Version = newVersions[changes[i].WordID]
+	}
+	for _, key := range order {
+		res := cellResults[key]
+		if v, ok := newVersions[key.WordID]; ok {
+			res.Version = v
+		}
+		result.Cells = append(result.Cells, *res)
+	}
+
+	// Новый пакет заменяет предыдущий: отменить можно только последнее сохранение
+	if len(changes) > 0 {
+		raw, err := models.EncodeWordCellChanges(changes)
+		if err != nil {
+			return result, err
+		}
+		err = tx.Exec(`
+			INSERT INTO langhelpercopy.word_edit_batches (user_id, changes, created_at)
+			VALUES (?, ?, NOW())
+			ON CONFLICT (user_id) DO UPDATE SET changes = EXCLUDED.changes, created_at = EXCLUDED.created_at
+		`, userID, raw).Error
+		if err != nil {
+			return result, err
+		}
+		result.CanUndo = true
+	} else if result.CanUndo, err = hasWordEditBatch(tx, userID); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// loadAlternativeKeys возвращает неосновные переводы слов в нижнем регистре по ячейкам
+func loadAlternativeKeys(db *gorm.DB, wordIDs []uint) (map[gridCellKey]map[string]bool, error) {
+	alternatives := make(map[gridCellKey]map[string]bool)
+	if len(wordIDs) == 0 {
+		return alternatives, nil
+	}
+	var rows []struct {
+		WordID uint
+		LangID uint
+		Value  string
+	}
+	err := db.Raw(`
+		SELECT word_id, lang_id, LOWER(translation) AS value FROM langhelpercopy.user_words
+		WHERE word_id IN (?) AND NOT is_primary
+	`, wordIDs).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		key := gridCellKey{row.WordID, row.LangID}
+		if alternatives[key] == nil {
+			alternatives[key] = make(map[string]bool)
+		}
+		alternatives[key][row.Value] = true
+	}
+	return alternatives, nil
+}
+
+// findGridDuplicates находит ячейки, новый перевод которых уже есть у другого
+// слова на том же языке (без учёта регистра), с учётом других ячеек пакета.
+// Возвращает ID другого слова для каждой такой ячейки.
+func findGridDuplicates(tx *gorm.DB, candidates []gridCellKey, values map[gridCellKey]string) (map[gridCellKey]uint, error) {
+	duplicates := make(map[gridCellKey]uint)
+	if len(candidates) == 0 {
+		return duplicates, nil
+	}
+
+	type valueKey struct {
+		LangID uint
+		Value  string
+	}
+	var langIDs []uint
+	var lowered []string
+	for _, key := range candidates {
+		if !containsID(langIDs, key.LangID) {
+			langIDs = append(langIDs, key.LangID)
+		}
+		lowered = append(lowered, strings.ToLower(values[key]))
+	}
+	var rows []struct {
+		WordID uint
+		LangID uint
+		Value  string
+	}
+	err := tx.Raw(`
+		SELECT word_id, lang_id, LOWER(translation) AS value FROM langhelpercopy.user_words
+		WHERE is_primary AND lang_id IN (?) AND LOWER(translation) IN (?)
+			AND word_id IN (SELECT id FROM langhelpercopy.words WHERE deleted_at IS NULL)
+	`, langIDs, lowered).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Итоговое состояние: переводы из базы, кроме ячеек, которые меняет пакет
+	owners := make(map[valueKey][]uint)
+	for _, row := range rows {
+		if containsKey(candidates, gridCellKey{row.WordID, row.LangID}) {
+			continue
+		}
+		k := valueKey{row.LangID, row.Value}
+		owners[k] = append(owners[k], row.WordID)
+	}
+	for _, key := range candidates {
+		k := valueKey{key.LangID, strings.ToLower(values[key])}
+		owners[k] = append(owners[k], key.WordID)
+	}
+	for _, key := range candidates {
+		for _, wordID := range owners[valueKey{key.LangID, strings.ToLower(values[key])}] {
+			if wordID != key.WordID {
+				duplicates[key] = wordID
+				break
+			}
+		}
+	}
+	return duplicates, nil
+}
+
+func containsKey(keys []gridCellKey, key gridCellKey) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func removeKey(keys []gridCellKey, key gridCellKey) []gridCellKey {
+	for i, k := range keys {
+		if k == key {
+			return append(keys[:i], keys[i+1:]...)
+		}
+	}
+	return keys
+}
+
+// WordGridUndoHandler отменяет последний сохранённый пакет правок, если слова
+// с тех пор не менялись
+func WordGridUndoHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := config.Store.Get(r, config.SessionName)
+	if err != nil || session.Values["authenticated"] != true {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, ok := session.Values["user_id"].(uint)
+	if !ok {
+		http.Error(w, "Invalid user session", http.StatusInternalServerError)
+		return
+	}
+
+	var result GridBatchResult
+	err = database.UnitOfWork(database.GetDB(), func(tx *gorm.DB) error {
+		var err error
+		result, err = undoGridBatch(tx, userID)
+		return err
+	})
+	switch {
+	case errors.Is(err, errGridNothingToUndo):
+		writeGridResult(w, http.StatusNotFound, GridBatchResult{Error: "Nothing to undo"})
+	case errors.Is(err, errGridUndoConflict):
+		writeGridResult(w, http.StatusConflict, GridBatchResult{Error: "Some of these words were edited after the save, so it can't be undone. Reload to see the latest version."})
+	case err != nil:
+		log.Printf("Failed to undo word grid changes: %v", err)
+		writeGridResult(w, http.StatusInternalServerError, GridBatchResult{Error: "Failed to undo changes"})
+	default:
+		writeGridResult(w, http.StatusOK, result)
+	}
+}
+
+// undoGridBatch возвращает прежние значения ячеек последнего пакета и удаляет его
+func undoGridBatch(tx *gorm.DB, userID uint) (GridBatchResult, error) {
+	var result GridBatchResult
+
+	var batch models.WordEditBatch
+	err := tx.Raw("SELECT * FROM langhelpercopy.word_edit_batches WHERE user_id = ? FOR UPDATE", userID).Scan(&batch).Error
+	if err != nil {
+		return result, err
+	}
+	if batch.ID == 0 {
+		return result, errGridNothingToUndo
+	}
+	changes, err := models.ParseWordCellChanges(batch.Changes)
+	if err != nil {
+		return result, err
+	}
+
+	var wordIDs []uint
+	for _, c := range changes {
+		if !containsID(wordIDs, c.WordID) {
+			wordIDs = append(wordIDs, c.WordID)
+		}
+	}
+	versions, err := loadWordVersions(tx, wordIDs, true)
+	if err != nil {
+		return result, err
+	}
+	current, err := loadPrimaryTranslations(tx, wordIDs)
+	if err != nil {
+		return result, err
+	}
+	for _, c := range changes {
+		if versions[c.WordID] != c.Version || current[gridCellKey{c.WordID, c.LangID}] != c.New {
+			return result, errGridUndoConflict
+		}
+	}
+
+	for _, c := range changes {
+		if c.Old == "" {
+			err = tx.Exec("DELETE FROM langhelpercopy.user_words WHERE word_id = ? AND lang_id = ? AND is_primary", c.WordID, c.LangID).Error
+		} else {
+			err = tx.Exec(`
+				UPDATE langhelpercopy.user_words SET translation = ?, updated_at = NOW(), version = version + 1
+				WHERE word_id = ? AND lang_id = ? AND is_primary
+			`, c.Old, c.WordID, c.LangID).Error
+		}
+		if err != nil {
+			return result, err
+		}
+		if err := recordTranslationChange(tx, userID, c.WordID, c.LangID, c.New, c.Old); err != nil {
+			return result, err
+		}
+	}
+	newVersions, err := bumpWordVersions(tx, wordIDs)
+	if err != nil {
+		return result, err
+	}
+	if err := tx.Exec("DELETE FROM langhelpercopy.word_edit_batches WHERE id = ?", batch.ID).Error; err != nil {
+		return result, err
+	}
+
+	for _, c := range changes {
+		result.Cells = append(result.Cells, GridCellResult{
+			WordID:  c.WordID,
+			LangID:  c.LangID,
+			Status:  gridCellSaved,
+			Value:   c.Old,
+			Version: newVersions[c.WordID],
+		})
+	}
+	return result, nil
+}
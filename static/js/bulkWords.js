@@ -0,0 +1,47 @@
+document.addEventListener("DOMContentLoaded", function () {
+  document.querySelectorAll(".bulk-form").forEach(form => {
+    const boxes = () => document.querySelectorAll(`input.bulk-select[form='${form.id}']`);
+    const selected = () => Array.from(boxes()).filter(box => box.checked).length;
+    const count = form.querySelector(".bulk-count");
+    const action = form.querySelector("select.bulk-action");
+
+    function updateCount() {
+      if (count) count.textContent = `${selected()} selected`;
+    }
+
+    // Показываются только поля выбранного действия
+    function updateFields() {
+      if (!action) return;
+      form.querySelectorAll(".bulk-field").forEach(field => {
+        field.hidden = !field.dataset.actions.split(" ").includes(action.value);
+      });
+    }
+
+    boxes().forEach(box => box.addEventListener("change", updateCount));
+
+    // Флажок в заголовке выбирает все слова формы
+    document.querySelectorAll(`input.bulk-select-all[data-form='${form.id}']`).forEach(all => {
+      all.addEventListener("change", function () {
+        boxes().forEach(box => box.checked = all.checked);
+        updateCount();
+      });
+    });
+
+    if (action) action.addEventListener("change", updateFields);
+
+    form.addEventListener("submit", function (e) {
+      const n = selected();
+      if (n === 0) {
+        e.preventDefault();
+        alert("Select at least one word.");
+        return;
+      }
+      if (action && action.value === "delete" && !confirm(`Move ${n} word(s) to the Trash?`)) {
+        e.preventDefault();
+      }
+    });
+
+    updateCount();
+    updateFields();
+  });
+});
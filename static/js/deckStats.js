@@ -0,0 +1,128 @@
+document.addEventListener('DOMContentLoaded', function() {
+    const container = document.getElementById('deck-charts');
+    if (!container) {
+        return;
+    }
+
+    const SVG = 'http://www.w3.org/2000/svg';
+    const WIDTH = 300;
+    const HEIGHT = 140;
+    const PADDING = 20;
+
+    function createSvg() {
+        const svg = document.createElementNS(SVG, 'svg');
+        svg.setAttribute('viewBox', `0 0 ${WIDTH} ${HEIGHT}`);
+        svg.setAttribute('preserveAspectRatio', 'none');
+        return svg;
+    }
+
+    function addLabel(svg, x, y, text, anchor) {
+        const label = document.createElementNS(SVG, 'text');
+        label.setAttribute('x', x);
+        label.setAttribute('y', y);
+        label.setAttribute('class', 'axis-label');
+        label.setAttribute('text-anchor', anchor || 'start');
+        label.textContent = text;
+        svg.appendChild(label);
+    }
+
+    function showEmpty(element, text) {
+        element.innerHTML = '';
+        const p = document.createElement('p');
+        p.className = 'chart-empty';
+        p.textContent = text;
+        element.appendChild(p);
+    }
+
+    // Столбчатая диаграмма: points - [{label, value}]
+    function barChart(element, points, color) {
+        const max = Math.max(0, ...points.map(p => p.value));
+        if (max === 0) {
+            showEmpty(element, 'No data yet');
+            return;
+        }
+        const svg = createSvg();
+        const step = (WIDTH - PADDING) / points.length;
+        const scale = (HEIGHT - 2 * PADDING) / max;
+
+        points.forEach((point, i) => {
+            const height = point.value * scale;
+            const bar = document.createElementNS(SVG, 'rect');
+            bar.setAttribute('x', PADDING + i * step + 1);
+            bar.setAttribute('y', HEIGHT - PADDING - height);
+            bar.setAttribute('width', Math.max(1, step - 2));
+            bar.setAttribute('height', height);
+            bar.setAttribute('fill', color);
+            const title = document.createElementNS(SVG, 'title');
+            title.textContent = `${point.label}: ${point.value}`;
+            bar.appendChild(title);
+            svg.appendChild(bar);
+        });
+
+        addLabel(svg, 0, PADDING, String(max));
+        addLabel(svg, PADDING, HEIGHT - 5, points[0].label);
+        addLabel(svg, WIDTH, HEIGHT - 5, points[points.length - 1].label, 'end');
+        element.innerHTML = '';
+        element.appendChild(svg);
+    }
+
+    // Линия точности в процентах; дни без ответов пропускаются
+    function lineChart(element, points, color) {
+        const known = points.map((p, i) => ({ ...p, index: i })).filter(p => p.value !== null);
+        if (known.length === 0) {
+            showEmpty(element, 'No answers in the last 30 days');
+            return;
+        }
+        const svg = createSvg();
+        const step = (WIDTH - PADDING) / Math.max(1, points.length - 1);
+        const scale = (HEIGHT - 2 * PADDING) / 100;
+        const coords = known.map(p => [PADDING + p.index * step, HEIGHT - PADDING - p.value * scale]);
+
+        const line = document.createElementNS(SVG, 'polyline');
+        line.setAttribute('points', coords.map(c => c.join(',')).join(' '));
+        line.setAttribute('fill', 'none');
+        line.setAttribute('stroke', color);
+        line.setAttribute('stroke-width', '2');
+        svg.appendChild(line);
+
+        known.forEach((point, i) => {
+            const dot = document.createElementNS(SVG, 'circle');
+            dot.setAttribute('cx', coords[i][0]);
+            dot.setAttribute('cy', coords[i][1]);
+            dot.setAttribute('r', 2.5);
+            dot.setAttribute('fill', color);
+            const title = document.createElementNS(SVG, 'title');
+            title.textContent = `${point.label}: ${point.value}%`;
+            dot.appendChild(title);
+            svg.appendChild(dot);
+        });
+
+        addLabel(svg, 0, PADDING, '100%');
+        addLabel(svg, 0, HEIGHT - PADDING, '0%');
+        addLabel(svg, PADDING, HEIGHT - 5, points[0].label);
+        addLabel(svg, WIDTH, HEIGHT - 5, points[points.length - 1].label, 'end');
+        element.innerHTML = '';
+        element.appendChild(svg);
+    }
+
+    fetch(`/deck/${container.dataset.deckId}/stats?format=json`)
+        .then(response => response.ok ? response.json() : Promise.reject())
+        .then(data => {
+            const history = data.history || [];
+            const forecast = data.forecast || [];
+            const shortDate = date => date.slice(5);
+
+            barChart(document.getElementById('chart-reviews'),
+                history.map(d => ({ label: shortDate(d.date), value: d.reviews })), '#3498db');
+            lineChart(document.getElementById('chart-accuracy'),
+                history.map(d => ({
+                    label: shortDate(d.date),
+                    value: d.reviews > 0 ? Math.round(d.correct * 100 / d.reviews) : null,
+                })), '#27ae60');
+            barChart(document.getElementById('chart-forecast'),
+                forecast.map(d => ({ label: shortDate(d.date), value: d.due })), '#e67e22');
+        })
+        .catch(() => {
+            container.querySelectorAll('.chart').forEach(chart => showEmpty(chart, 'Failed to load chart'));
+        });
+});
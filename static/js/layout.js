@@ -20,4 +20,81 @@ document.addEventListener('DOMContentLoaded', function() {
             submenu.classList.toggle('active');
         });
     });
-});
\ No newline at end of file
+
+    // Выполнение дневной цели в шапке
+    const goal = document.getElementById('header-goal');
+    if (goal) {
+        fetch('/goal/progress')
+            .then(response => response.ok ? response.json() : null)
+            .then(progress => {
+                if (!progress) {
+                    return;
+                }
+                const unit = progress.type === 'minutes' ? 'min' : 'cards';
+                const percent = progress.target > 0
+                    ? Math.min(100, Math.floor(progress.done * 100 / progress.target))
+                    : 100;
+                document.getElementById('header-goal-text').textContent =
+                    `🔥 ${progress.streak} · ${progress.done}/${progress.target} ${unit}`;
+                document.getElementById('header-goal-fill').style.width = `${percent}%`;
+                goal.classList.toggle('goal-met', progress.met);
+                goal.title = `Daily goal · streak freezes: ${progress.freezes}`;
+                goal.hidden = false;
+            })
+            .catch(() => {});
+    }
+
+    // Поиск в шапке с подсказками
+    const searchInput = document.getElementById('header-search-input');
+    const suggestions = document.getElementById('header-search-suggestions');
+
+    if (searchInput && suggestions) {
+        let timer = null;
+
+        searchInput.addEventListener('input', () => {
+            clearTimeout(timer);
+            const query = searchInput.value.trim();
+            if (query.length < 2) {
+                suggestions.classList.remove('active');
+                suggestions.innerHTML = '';
+                return;
+            }
+            timer = setTimeout(() => loadSuggestions(query), 200);
+        });
+
+        document.addEventListener('click', (e) => {
+            if (!e.target.closest('#header-search')) {
+                suggestions.classList.remove('active');
+            }
+        });
+
+        function loadSuggestions(query) {
+            fetch(`/search?format=json&q=${encodeURIComponent(query)}`)
+                .then(response => response.ok ? response.json() : [])
+                .then(results => {
+                    suggestions.innerHTML = '';
+                    results.slice(0, 8).forEach(result => {
+                        const li = document.createElement('li');
+                        const link = document.createElement('a');
+                        const decks = result.decks || [];
+                        link.href = decks.length > 0 ? `/deck/${decks[0].id}` : '/mywords';
+                        link.textContent = (result.translations || [])
+                            .map(t => t.translation)
+                            .join(' / ');
+
+                        if (decks.length > 0) {
+                            const deckNames = document.createElement('span');
+                            deckNames.className = 'suggestion-decks';
+                            deckNames.textContent = decks.map(d => d.title).join(', ');
+                            link.appendChild(deckNames);
+                        }
+
+                        li.appendChild(link);
+                        suggestions.appendChild(li);
+                    });
+                    suggestions.classList.toggle('active', results.length > 0);
+                })
+                .catch(() => suggestions.classList.remove('active'));
+        }
+    }
+});
@@ -7,11 +7,37 @@ class DeckManager {
   initElements() {
     this.showDeckFormBtn = document.getElementById('showDeckForm');
     this.newDeckForm = document.getElementById('newDeckForm');
+    this.smartToggle = document.getElementById('smartToggle');
+    this.smartRuleFields = document.getElementById('smartRuleFields');
   }
 
   bindEvents() {
     // Обработчик кнопки показа/скрытия формы создания колоды
     this.showDeckFormBtn.addEventListener('click', () => this.toggleForm());
+
+    // Поля правила показываются только для умной колоды
+    this.smartToggle.addEventListener('change', () => {
+      this.smartRuleFields.style.display = this.smartToggle.checked ? 'block' : 'none';
+    });
+
+    // Показ/скрытие формы переименования колоды
+    document.querySelectorAll('.rename-toggle').forEach(button => {
+      button.addEventListener('click', () => {
+        const form = document.getElementById(`rename-form-${button.dataset.deckId}`);
+        form.style.display = form.style.display === 'none' ? 'flex' : 'none';
+      });
+    });
+
+    // Для объединения нужно выбрать хотя бы две колоды
+    const mergeForm = document.getElementById('mergeForm');
+    if (mergeForm) {
+      mergeForm.addEventListener('submit', (e) => {
+        if (mergeForm.querySelectorAll('input[name="deck_ids"]:checked').length < 2) {
+          e.preventDefault();
+          alert('Select at least two decks to merge.');
+        }
+      });
+    }
   }
 
   toggleForm() {
@@ -4,7 +4,8 @@ document.addEventListener("DOMContentLoaded", function () {
     document.getElementById("addWordForm").style.display = "block";
     document.getElementById("formTitle").textContent = "Add New Word";
     document.getElementById("wordIdField").value = "";
-    document.querySelectorAll(".translation-input").forEach(input => input.value = "");
+    document.getElementById("wordVersionField").value = "";
+    document.querySelectorAll(".translation-input, .meta-input").forEach(input => input.value = "");
   });
 
   // Отмена редактирования/добавления
@@ -12,7 +13,8 @@ document.addEventListener("DOMContentLoaded", function () {
     document.getElementById("addWordForm").style.display = "none";
     document.getElementById("formTitle").textContent = "Add New Word";
     document.getElementById("wordIdField").value = "";
-    document.querySelectorAll(".translation-input").forEach(input => input.value = "");
+    document.getElementById("wordVersionField").value = "";
+    document.querySelectorAll(".translation-input, .meta-input").forEach(input => input.value = "");
   });
 
   // Редактирование существующего слова
@@ -23,9 +25,15 @@ document.addEventListener("DOMContentLoaded", function () {
 
       document.getElementById("formTitle").textContent = "Edit Word";
       document.getElementById("wordIdField").value = wordId;
+      document.getElementById("wordVersionField").value = row.dataset.version;
 
       // Очистка формы
-      document.querySelectorAll(".translation-input").forEach(input => input.value = "");
+      document.querySelectorAll(".translation-input, .meta-input").forEach(input => input.value = "");
+
+      // Метаданные слова
+      document.getElementById("partOfSpeechField").value = row.dataset.partOfSpeech || "";
+      document.getElementById("wordNotesField").value = row.dataset.notes || "";
+      document.getElementById("wordTagsField").value = row.dataset.tags || "";
 
       // Заполнить форму переводами из таблицы
       row.querySelectorAll("td[data-lang-id]").forEach(cell => {
@@ -34,6 +42,15 @@ document.addEventListener("DOMContentLoaded", function () {
 
         const input = document.querySelector(`input[name='translation_${langId}']`);
         if (input) input.value = translation;
+
+        const altInput = document.querySelector(`input[name='alternatives_${langId}']`);
+        if (altInput) altInput.value = cell.dataset.alternatives || "";
+
+        // Метаданные перевода
+        ["gender", "pronunciation", "notes", "examples"].forEach(field => {
+          const metaInput = document.querySelector(`[name='${field}_${langId}']`);
+          if (metaInput) metaInput.value = cell.dataset[field] || "";
+        });
       });
 
       document.getElementById("addWordForm").style.display = "block";
@@ -44,7 +61,7 @@ document.addEventListener("DOMContentLoaded", function () {
   document.querySelectorAll(".delete-btn").forEach(button => {
     button.addEventListener("click", function () {
       const wordId = this.dataset.wordId;
-      if (confirm("Are you sure you want to delete this word?")) {
+      if (confirm("Move this word to the Trash?")) {
         window.location.href = `/mywords/delete/${wordId}`;
       }
     });
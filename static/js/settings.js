@@ -0,0 +1,14 @@
+document.addEventListener('DOMContentLoaded', function() {
+    // Подставляет часовой пояс браузера
+    const detect = document.getElementById('detect-timezone');
+    const timezone = document.getElementById('timezone');
+
+    if (detect && timezone) {
+        detect.addEventListener('click', () => {
+            const zone = Intl.DateTimeFormat().resolvedOptions().timeZone;
+            if (zone) {
+                timezone.value = zone;
+            }
+        });
+    }
+});
@@ -0,0 +1,105 @@
+// Горячие клавиши учебной сессии: цифры выбирают вариант, Enter или → - следующая карточка
+document.addEventListener('keydown', (event) => {
+  if (event.ctrlKey || event.metaKey || event.altKey) {
+    return;
+  }
+  if (event.target.closest('input, textarea, select')) {
+    return;
+  }
+
+  const answerForm = document.getElementById('study-answer-form');
+  if (answerForm) {
+    const option = answerForm.querySelector(`.study-option[data-key="${event.key}"]`);
+    if (option) {
+      event.preventDefault();
+      option.click();
+    }
+    return;
+  }
+
+  const nextForm = document.getElementById('study-next-form');
+  if (nextForm && (event.key === 'Enter' || event.key === 'ArrowRight')) {
+    event.preventDefault();
+    nextForm.requestSubmit();
+  }
+});
+
+// Таймер экзамена. Время проверяется на сервере, поэтому по истечении
+// страница просто перезагружается и сервер засчитывает пропуск.
+const timer = document.getElementById('study-timer');
+if (timer) {
+  const deadlines = [
+    { ms: Number(timer.dataset.deadline), el: timer.querySelector('.timer-total') },
+    { ms: Number(timer.dataset.cardDeadline), el: timer.querySelector('.timer-card') },
+  ].filter(d => d.ms > 0 && d.el);
+
+  const format = (ms) => {
+    const total = Math.max(0, Math.ceil(ms / 1000));
+    const minutes = Math.floor(total / 60);
+    const seconds = String(total % 60).padStart(2, '0');
+    return `${minutes}:${seconds}`;
+  };
+
+  let reloading = false;
+  const tick = () => {
+    const now = Date.now();
+    deadlines.forEach(d => {
+      const left = d.ms - now;
+      d.el.textContent = format(left);
+      d.el.classList.toggle('timer-expiring', left < 10000);
+      if (left <= 0 && !reloading) {
+        reloading = true;
+        setTimeout(() => window.location.reload(), 2500);
+      }
+    });
+  };
+  tick();
+  setInterval(tick, 250);
+}
+
+// Игра на пары: щелчок по слову слева, затем по переводу справа выбирает пару в списке
+const matchForm = document.getElementById('study-match-form');
+if (matchForm) {
+  let selectedPrompt = null;
+
+  const refreshPaired = () => {
+    const used = new Set(Array.from(matchForm.querySelectorAll('.match-select')).map(s => s.value));
+    matchForm.querySelectorAll('.match-option').forEach(option => {
+      option.classList.toggle('paired', used.has(option.dataset.value));
+    });
+    matchForm.querySelectorAll('.match-prompt').forEach(prompt => {
+      const select = document.getElementById(`pair-${prompt.dataset.cardId}`);
+      prompt.classList.toggle('paired', select.value !== '');
+    });
+  };
+
+  matchForm.querySelectorAll('.match-prompt').forEach(prompt => {
+    prompt.addEventListener('click', () => {
+      matchForm.querySelectorAll('.match-prompt').forEach(p => p.classList.remove('selected'));
+      selectedPrompt = prompt;
+      prompt.classList.add('selected');
+    });
+  });
+
+  matchForm.querySelectorAll('.match-option').forEach(option => {
+    option.addEventListener('click', () => {
+      if (!selectedPrompt) {
+        return;
+      }
+      // Один перевод может быть только в одной паре
+      matchForm.querySelectorAll('.match-select').forEach(select => {
+        if (select.value === option.dataset.value) {
+          select.value = '';
+        }
+      });
+      document.getElementById(`pair-${selectedPrompt.dataset.cardId}`).value = option.dataset.value;
+      selectedPrompt.classList.remove('selected');
+      selectedPrompt = null;
+      refreshPaired();
+    });
+  });
+
+  matchForm.querySelectorAll('.match-select').forEach(select => {
+    select.addEventListener('change', refreshPaired);
+  });
+}
@@ -0,0 +1,135 @@
+document.addEventListener("DOMContentLoaded", function () {
+  const saveBtn = document.getElementById("gridSave");
+  const undoBtn = document.getElementById("gridUndo");
+  const status = document.getElementById("gridStatus");
+  const cells = Array.from(document.querySelectorAll(".grid-cell"));
+
+  function setStatus(text, isError) {
+    status.textContent = text;
+    status.classList.toggle("error", !!isError);
+  }
+
+  function dirtyCells() {
+    return cells.filter(cell => cell.value.trim() !== cell.dataset.original);
+  }
+
+  function updateDirty(cell) {
+    cell.classList.toggle("dirty", cell.value.trim() !== cell.dataset.original);
+    cell.classList.remove("saved");
+    const n = dirtyCells().length;
+    saveBtn.disabled = n === 0;
+    saveBtn.textContent = n ? `Save (${n})` : "Save";
+  }
+
+  function findCell(wordId, langId) {
+    return document.querySelector(`tr[data-word-id='${wordId}'] .grid-cell[data-lang-id='${langId}']`);
+  }
+
+  // Применение ответа сервера к ячейкам
+  function applyResult(result) {
+    let saved = 0, failed = 0;
+    (result.cells || []).forEach(res => {
+      const cell = findCell(res.word_id, res.lang_id);
+      if (!cell) return;
+      const row = cell.closest("tr");
+      row.dataset.version = res.version;
+      cell.classList.remove("invalid", "conflict");
+      cell.title = "";
+      if (res.status === "saved") {
+        cell.dataset.original = res.value;
+        cell.value = res.value;
+        cell.classList.remove("dirty");
+        cell.classList.add("saved");
+        saved++;
+      } else {
+        cell.classList.add(res.status);
+        cell.title = res.error;
+        failed++;
+      }
+    });
+    undoBtn.disabled = !result.can_undo;
+    cells.forEach(updateDirty);
+    return { saved, failed };
+  }
+
+  async function post(url, body) {
+    const response = await fetch(url, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: body ? JSON.stringify(body) : null,
+    });
+    let result = {};
+    try {
+      result = await response.json();
+    } catch (e) {
+      result = { error: "Unexpected server response" };
+    }
+    return { ok: response.ok, result };
+  }
+
+  cells.forEach(cell => {
+    cell.addEventListener("input", () => updateDirty(cell));
+    cell.addEventListener("keydown", e => {
+      if (e.key === "Escape") {
+        cell.value = cell.dataset.original;
+        cell.classList.remove("invalid", "conflict");
+        updateDirty(cell);
+      } else if (e.key === "Enter") {
+        e.preventDefault();
+        // Переход к той же колонке в следующей строке
+        const next = cell.closest("tr").nextElementSibling;
+        const target = next && next.querySelector(`.grid-cell[data-lang-id='${cell.dataset.langId}']`);
+        if (target) target.focus();
+      }
+    });
+  });
+
+  saveBtn.addEventListener("click", async function () {
+    const rows = new Map();
+    dirtyCells().forEach(cell => {
+      const tr = cell.closest("tr");
+      const wordId = Number(tr.dataset.wordId);
+      if (!rows.has(wordId)) {
+        rows.set(wordId, { word_id: wordId, version: Number(tr.dataset.version), cells: [] });
+      }
+      rows.get(wordId).cells.push({ lang_id: Number(cell.dataset.langId), value: cell.value });
+    });
+    if (rows.size === 0) return;
+
+    saveBtn.disabled = true;
+    setStatus("Saving...");
+    const { ok, result } = await post("/mywords/grid/save", { rows: Array.from(rows.values()) });
+    if (!ok) {
+      setStatus(result.error || "Failed to save changes", true);
+      cells.forEach(updateDirty);
+      return;
+    }
+    const { saved, failed } = applyResult(result);
+    if (failed) {
+      setStatus(`${saved} cell(s) saved, ${failed} not saved. Hover a highlighted cell to see why.`, true);
+    } else {
+      setStatus(`${saved} cell(s) saved.`);
+    }
+  });
+
+  undoBtn.addEventListener("click", async function () {
+    if (dirtyCells().length && !confirm("Undo the last save? Unsaved changes in other cells are kept.")) return;
+    undoBtn.disabled = true;
+    const { ok, result } = await post("/mywords/grid/undo");
+    if (!ok) {
+      setStatus(result.error || "Failed to undo", true);
+      undoBtn.disabled = result.error === "Nothing to undo";
+      return;
+    }
+    const { saved } = applyResult(result);
+    setStatus(`Last save undone, ${saved} cell(s) restored.`);
+  });
+
+  // Предупреждение о несохранённых правках при уходе со страницы
+  window.addEventListener("beforeunload", e => {
+    if (dirtyCells().length) {
+      e.preventDefault();
+      e.returnValue = "";
+    }
+  });
+});